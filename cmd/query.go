@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/config"
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/index"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+// explain prints the query plan instead of the matching lines
+var explain bool
+
+// maxLineSize is the longest line the query command reads
+const maxLineSize = 1024 * 1024
+
+// queryCmd runs a query over log files without the TUI
+var queryCmd = &cobra.Command{
+	Use:   "query <query> [files...]",
+	Short: "Run a query over log files and print the matches",
+	Long: `Run a query over log files, or standard input when no files are given,
+and print the matching lines or pipeline results.
+
+With --explain, print the parsed query instead: whether each node can use
+the search index or must scan, how many lines it matches and how long it
+takes, and warnings such as fields that no line has.
+
+Examples:
+  traceace query 'level:ERROR status:>=500' /var/log/app.log
+  traceace query '* | stats count() by service' app.log
+  traceace query --explain 'user:alice AND ~"time(out|d)"' app.log`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runQuery,
+}
+
+func init() {
+	queryCmd.Flags().BoolVar(&explain, "explain", false, "explain the query plan and profile it on the files")
+	rootCmd.AddCommand(queryCmd)
+}
+
+// runQuery filters the files and prints the matches or the explanation
+func runQuery(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	pl, err := pipeline.Parse(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid query: %v\n", err)
+		os.Exit(1)
+	}
+
+	sep, err := parseDelimiter(delimiter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid delimiter: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Parse as the TUI does, so queries match the same fields
+	p := parser.New()
+	p.SetMaxDecodeDepth(cfg.DecodeDepth())
+	if sep != 0 {
+		sources := args[1:]
+		if len(sources) == 0 {
+			sources = []string{"stdin"}
+		}
+		for _, source := range sources {
+			p.RegisterDelimitedSource(source, sep)
+		}
+	}
+	ix := index.New(cfg.General.MaxIndexSize, cfg.General.IndexNGrams)
+	lines, err := readLogLines(p, ix, args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read logs: %v\n", err)
+		os.Exit(1)
+	}
+
+	f := filter.New(p)
+	options := models.FilterOptions{Query: pl.Filter}
+	if explain {
+		var planner filter.Planner
+		if ix.Enabled() {
+			planner = ix
+		}
+		ex, err := f.Explain(options, lines, planner)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid query: %v\n", err)
+			os.Exit(1)
+		}
+		if planner == nil {
+			ex.Warnings = append(ex.Warnings, "the search index is disabled or exceeded max_index_size")
+		}
+		fmt.Print(ex.String())
+		if pl.HasStages() {
+			fmt.Printf("\nPipeline: | %s\n", pl.String())
+		}
+		return
+	}
+
+	if err := f.SetFilter(options); err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid query: %v\n", err)
+		os.Exit(1)
+	}
+
+	matched := lines
+	if f.HasFilter() {
+		matched = nil
+		for _, line := range lines {
+			matched = append(matched, f.Feed(line)...)
+		}
+		matched = append(matched, f.Expire(time.Now())...)
+	}
+
+	if !pl.HasStages() {
+		for _, line := range matched {
+			fmt.Println(line.Raw)
+		}
+		return
+	}
+
+	result, err := pl.Run(matched, f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Pipeline error: %v\n", err)
+		os.Exit(1)
+	}
+	printResult(result, f)
+}
+
+// readLogLines reads and parses every line of the files, or of standard
+// input when there are none, adding each to the index
+func readLogLines(p *parser.LogParser, ix *index.Index, files []string) ([]*models.LogLine, error) {
+	var lines []*models.LogLine
+	read := func(r io.Reader, source string) error {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		var offset int64
+		var last time.Time
+		for lineNum := 1; scanner.Scan(); lineNum++ {
+			line := &models.LogLine{
+				ID:      fmt.Sprintf("%s:%d", source, lineNum),
+				Source:  source,
+				Raw:     scanner.Text(),
+				LineNum: lineNum,
+				Offset:  offset,
+			}
+			offset += int64(len(scanner.Bytes())) + 1
+			p.ParseLogLine(line)
+			// Lines without a timestamp, such as stack traces, take the
+			// previous one's, as in the TUI
+			if line.Timestamp.IsZero() {
+				line.Timestamp = last
+			} else {
+				last = line.Timestamp
+			}
+			ix.Add(line)
+			lines = append(lines, line)
+		}
+		return scanner.Err()
+	}
+
+	if len(files) == 0 {
+		return lines, read(os.Stdin, "stdin")
+	}
+	for _, file := range files {
+		fh, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		err = read(fh, file)
+		fh.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	return lines, nil
+}
+
+// printResult prints pipeline rows as a table, or raw when it has no columns
+func printResult(result *pipeline.Result, f *filter.FilterEngine) {
+	if len(result.Columns) == 0 {
+		for _, row := range result.Rows {
+			fmt.Println(row.Raw)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(result.Columns, "\t"))
+	for _, row := range result.Rows {
+		cells := make([]string, len(result.Columns))
+		for i, column := range result.Columns {
+			cells[i] = strings.Join(f.FieldValues(row, column), ";")
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+}
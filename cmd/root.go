@@ -21,6 +21,7 @@ var (
 	fromBeginning bool
 	contextLines  int
 	savedQuery    string
+	delimiter     string
 	verbose       bool
 	debug         bool
 )
@@ -57,6 +58,7 @@ func init() {
 	rootCmd.Flags().BoolVarP(&fromBeginning, "from-beginning", "F", false, "read entire file from beginning")
 	rootCmd.Flags().IntVarP(&contextLines, "context", "C", 0, "number of context lines around matches")
 	rootCmd.Flags().StringVar(&savedQuery, "query", "", "start with a saved query")
+	rootCmd.PersistentFlags().StringVar(&delimiter, "delimiter", "", "read the files as delimited rows under a header line (e.g. ';' or tab)")
 	rootCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
 	rootCmd.Flags().BoolVar(&debug, "debug", false, "debug mode")
 }
@@ -75,8 +77,14 @@ func runTraceAce(cmd *cobra.Command, args []string) {
 		cfg.UI.Theme = theme
 	}
 	
-	if contextLines > 0 {
-		cfg.UI.ContextLines = contextLines
+	if cmd.Flags().Changed("context") {
+		cfg.UI.ContextLines = contextLines // -C 0 turns off configured context
+	}
+
+	sep, err := parseDelimiter(delimiter)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid delimiter: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Set up context for graceful shutdown
@@ -100,6 +108,10 @@ func runTraceAce(cmd *cobra.Command, args []string) {
 
 	// Add files to be tailed
 	for _, file := range args {
+		if sep != 0 {
+			model.ReadDelimited(file, sep)
+		}
+		
 		var addErr error
 		if fromBeginning {
 			addErr = model.TailFromStart(file)
@@ -135,6 +147,22 @@ func runTraceAce(cmd *cobra.Command, args []string) {
 	model.Stop()
 }
 
+// parseDelimiter reads the --delimiter flag: a single character, or "tab".
+// No delimiter returns 0.
+func parseDelimiter(s string) (rune, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if s == "tab" || s == `\t` {
+		return '\t', nil
+	}
+	runes := []rune(s)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\n' || runes[0] == '\r' {
+		return 0, fmt.Errorf("%q is not a single field separator", s)
+	}
+	return runes[0], nil
+}
+
 // versionCmd represents the version command
 var versionCmd = &cobra.Command{
 	Use:   "version",
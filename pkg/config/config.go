@@ -16,6 +16,7 @@ type Config struct {
 	SavedQueries   []models.SavedQuery     `mapstructure:"saved_queries" yaml:"saved_queries"`
 	Keybindings    map[string]string       `mapstructure:"keybindings" yaml:"keybindings"`
 	General        GeneralConfig           `mapstructure:"general" yaml:"general"`
+	Parser         ParserConfig            `mapstructure:"parser" yaml:"parser"`
 }
 
 // UIConfig represents UI-specific configuration
@@ -25,6 +26,16 @@ type UIConfig struct {
 	MaxBufferLines  int    `mapstructure:"max_buffer_lines" yaml:"max_buffer_lines"`
 	RefreshRate     int    `mapstructure:"refresh_rate_ms" yaml:"refresh_rate_ms"`
 	ShowLineNumbers bool   `mapstructure:"show_line_numbers" yaml:"show_line_numbers"`
+	ShowHistogram   bool   `mapstructure:"show_histogram" yaml:"show_histogram"`
+	Columns         []ColumnSet `mapstructure:"columns" yaml:"columns"`
+}
+
+// ColumnSet is the column view of one source: the fields shown and their
+// widths, 0 to fit the values
+type ColumnSet struct {
+	Source string   `mapstructure:"source" yaml:"source"`
+	Fields []string `mapstructure:"fields" yaml:"fields"`
+	Widths []int    `mapstructure:"widths" yaml:"widths"`
 }
 
 // HighlightRule represents a syntax highlighting rule
@@ -40,7 +51,16 @@ type GeneralConfig struct {
 	LogLevel           string `mapstructure:"log_level" yaml:"log_level"`
 	EnableTelemetry    bool   `mapstructure:"enable_telemetry" yaml:"enable_telemetry"`
 	MaxIndexSize       int64  `mapstructure:"max_index_size" yaml:"max_index_size"`
+	IndexNGrams        bool   `mapstructure:"index_ngrams" yaml:"index_ngrams"`
 	FileRotationCheck  int    `mapstructure:"file_rotation_check_ms" yaml:"file_rotation_check_ms"`
+	MergeWindow        int    `mapstructure:"merge_window_ms" yaml:"merge_window_ms"`
+	CorrelationFields  []string `mapstructure:"correlation_fields" yaml:"correlation_fields"`
+}
+
+// ParserConfig represents log parsing settings
+type ParserConfig struct {
+	DecodeNested   bool `mapstructure:"decode_nested" yaml:"decode_nested"`
+	MaxDecodeDepth int  `mapstructure:"max_decode_depth" yaml:"max_decode_depth"`
 }
 
 // DefaultConfig returns a configuration with sensible defaults
@@ -52,6 +72,7 @@ func DefaultConfig() *Config {
 			MaxBufferLines:  1000000,  // 1M lines for large file support
 			RefreshRate:     50,       // Faster refresh for smoother scrolling
 			ShowLineNumbers: true,
+			ShowHistogram:   true,
 		},
 		HighlightRules: []HighlightRule{
 			{
@@ -102,34 +123,61 @@ func DefaultConfig() *Config {
 				Name:        "warnings_and_errors",
 				Query:       "level:(ERROR|WARN)",
 				Description: "Show warnings and errors",
-				IsRegex:     true,
+				IsRegex:     false,
 			},
 		},
 		Keybindings: map[string]string{
 			"search":           "/",
+			"command_palette":  ":",
 			"escape":           "esc",
 			"next_match":       "n",
 			"prev_match":       "N",
+			"clear_filter":     "c",
+			"inspect":          "enter",
 			"pause_resume":     "space",
 			"bookmark":         "b",
 			"export":           "e",
+			"same_field":       "=",
+			"merge_view":       "m",
+			"column_view":      "v",
+			"column_prev":      "[",
+			"column_next":      "]",
+			"column_narrow":    "<",
+			"column_widen":     ">",
+			"column_sort":      "o",
+			"column_remove":    "D",
+			"facets":           "f",
+			"histogram":        "h",
+			"toggle_histogram": "H",
+			"context":          "C",
+			"more_context":     "+",
+			"less_context":     "-",
 			"toggle_view":      "t",
 			"help":             "?",
 			"quit":             "q",
-			"scroll_up":        "k",
-			"scroll_down":      "j",
+			"scroll_up":        "k, up",
+			"scroll_down":      "j, down",
 			"page_up":          "ctrl+u",
 			"page_down":        "ctrl+d",
 			"goto_top":         "g",
 			"goto_bottom":      "G",
+			"goto":             "ctrl+g",
 			"next_tab":         "tab",
 			"prev_tab":         "shift+tab",
+			"close_tab":        "x",
+			"sources":          "s",
 		},
 		General: GeneralConfig{
 			LogLevel:           "info",
 			EnableTelemetry:    false,
 			MaxIndexSize:       100 * 1024 * 1024, // 100MB
 			FileRotationCheck:  1000,               // 1 second
+			MergeWindow:        2000,               // 2 seconds
+			CorrelationFields:  []string{"trace_id", "request_id", "correlation_id", "traceId", "requestId"},
+		},
+		Parser: ParserConfig{
+			DecodeNested:   true,
+			MaxDecodeDepth: 3,
 		},
 	}
 }
@@ -207,6 +255,7 @@ func Save(config *Config) error {
 	viper.Set("saved_queries", config.SavedQueries)
 	viper.Set("keybindings", config.Keybindings)
 	viper.Set("general", config.General)
+	viper.Set("parser", config.Parser)
 	
 	// Write to file
 	if err := viper.WriteConfigAs(configFile); err != nil {
@@ -242,6 +291,35 @@ func (c *Config) RemoveSavedQuery(name string) error {
 	return nil
 }
 
+// ColumnsFor returns the saved column set of a source, matched by path or
+// by file name
+func (c *Config) ColumnsFor(source string) (ColumnSet, bool) {
+	for _, set := range c.UI.Columns {
+		if set.Source == source {
+			return set, true
+		}
+	}
+	for _, set := range c.UI.Columns {
+		if filepath.Base(set.Source) == filepath.Base(source) {
+			return set, true
+		}
+	}
+	return ColumnSet{}, false
+}
+
+// SetColumns saves the column set of a source
+func (c *Config) SetColumns(set ColumnSet) error {
+	for i, existing := range c.UI.Columns {
+		if existing.Source == set.Source {
+			c.UI.Columns[i] = set
+			return Save(c)
+		}
+	}
+	
+	c.UI.Columns = append(c.UI.Columns, set)
+	return Save(c)
+}
+
 // GetKeybinding returns the key binding for a given action
 func (c *Config) GetKeybinding(action string) string {
 	if binding, exists := c.Keybindings[action]; exists {
@@ -251,3 +329,11 @@ func (c *Config) GetKeybinding(action string) string {
 	defaults := DefaultConfig()
 	return defaults.Keybindings[action]
 }
+
+// DecodeDepth returns the effective nested decoding depth for the parser
+func (c *Config) DecodeDepth() int {
+	if !c.Parser.DecodeNested {
+		return 0
+	}
+	return c.Parser.MaxDecodeDepth
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
 )
 
 // Exporter handles exporting log data to various formats
@@ -37,6 +38,7 @@ type ExportOptions struct {
 	IncludeTokens bool            `json:"include_tokens"`
 	TimeRange    *models.TimeRange `json:"time_range,omitempty"`
 	Metadata     map[string]string `json:"metadata"`
+	Fields       []string          `json:"fields,omitempty"` // parsed field paths exported as extra CSV columns
 }
 
 // New creates a new Exporter
@@ -200,6 +202,9 @@ func (e *Exporter) exportCSV(writer io.Writer, lines []*models.LogLine, options
 
 	// Write header
 	header := []string{"timestamp", "source", "level", "raw"}
+	for _, field := range options.Fields {
+		header = append(header, e.escapeCSV(field))
+	}
 	if options.IncludeParsed {
 		header = append(header, "parsed_json")
 	}
@@ -214,6 +219,10 @@ func (e *Exporter) exportCSV(writer io.Writer, lines []*models.LogLine, options
 			e.escapeCSV(line.Raw),
 		}
 
+		for _, field := range options.Fields {
+			row = append(row, e.escapeCSV(e.fieldValue(line, field)))
+		}
+
 		if options.IncludeParsed && line.Parsed != nil {
 			parsedJSON, _ := json.Marshal(line.Parsed)
 			row = append(row, e.escapeCSV(string(parsedJSON)))
@@ -339,6 +348,16 @@ func (e *Exporter) formatLineForText(line *models.LogLine) string {
 	return strings.Join(parts, " ")
 }
 
+// fieldValue resolves a parsed field path for export, joining wildcard matches
+func (e *Exporter) fieldValue(line *models.LogLine, field string) string {
+	values := parser.LookupField(line.Parsed, field)
+	parts := make([]string, len(values))
+	for i, val := range values {
+		parts[i] = parser.FormatValue(val)
+	}
+	return strings.Join(parts, ";")
+}
+
 // escapeCSV escapes a string for CSV output
 func (e *Exporter) escapeCSV(s string) string {
 	// If the string contains comma, quote, or newline, wrap it in quotes
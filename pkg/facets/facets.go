@@ -0,0 +1,165 @@
+// Package facets counts the values of parsed fields over a set of log
+// lines that grows and shrinks as lines are added and evicted
+package facets
+
+import (
+	"sort"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+// FieldStat describes one field of the counted lines
+type FieldStat struct {
+	Name        string
+	Lines       int  // Lines that have the field
+	Cardinality int  // Distinct values tracked
+	Overflow    bool // More distinct values were seen than are tracked
+}
+
+// ValueCount is a value of a field and the number of lines that have it
+type ValueCount struct {
+	Value string
+	Count int
+}
+
+// field holds the counts of one field path
+type field struct {
+	lines    int
+	values   map[string]int
+	overflow bool
+}
+
+// Counter counts, for every field path in the parsed data of a set of
+// lines, how many lines have each value. Nested maps are flattened into
+// dotted paths and array elements are counted under path[*], so every
+// name is a field path the query language accepts. A Counter is not safe
+// for concurrent use.
+type Counter struct {
+	maxValues int
+	lines     int
+	fields    map[string]*field
+}
+
+// New creates a counter that tracks at most maxValues distinct values per
+// field; rarer values past that are not counted
+func New(maxValues int) *Counter {
+	return &Counter{
+		maxValues: maxValues,
+		fields:    make(map[string]*field),
+	}
+}
+
+// Add counts the fields of a line
+func (c *Counter) Add(line *models.LogLine) {
+	c.lines++
+	for path, values := range flatten(line.Parsed) {
+		f, ok := c.fields[path]
+		if !ok {
+			f = &field{values: make(map[string]int)}
+			c.fields[path] = f
+		}
+		f.lines++
+		for value := range values {
+			if _, tracked := f.values[value]; !tracked && len(f.values) >= c.maxValues {
+				f.overflow = true
+				continue
+			}
+			f.values[value]++
+		}
+	}
+}
+
+// Remove uncounts the fields of a line that was added before
+func (c *Counter) Remove(line *models.LogLine) {
+	c.lines--
+	for path, values := range flatten(line.Parsed) {
+		f, ok := c.fields[path]
+		if !ok {
+			continue
+		}
+		f.lines--
+		if f.lines <= 0 {
+			delete(c.fields, path)
+			continue
+		}
+		for value := range values {
+			if count, tracked := f.values[value]; tracked {
+				if count <= 1 {
+					delete(f.values, value)
+				} else {
+					f.values[value] = count - 1
+				}
+			}
+		}
+	}
+}
+
+// Reset forgets every counted line
+func (c *Counter) Reset() {
+	c.lines = 0
+	c.fields = make(map[string]*field)
+}
+
+// Lines returns the number of counted lines
+func (c *Counter) Lines() int {
+	return c.lines
+}
+
+// Fields returns every field seen in the counted lines, sorted by name
+func (c *Counter) Fields() []FieldStat {
+	stats := make([]FieldStat, 0, len(c.fields))
+	for name, f := range c.fields {
+		stats = append(stats, FieldStat{Name: name, Lines: f.lines, Cardinality: len(f.values), Overflow: f.overflow})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Top returns the n most frequent values of a field, most frequent first
+func (c *Counter) Top(name string, n int) []ValueCount {
+	f, ok := c.fields[name]
+	if !ok {
+		return nil
+	}
+
+	top := make([]ValueCount, 0, len(f.values))
+	for value, count := range f.values {
+		top = append(top, ValueCount{Value: value, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Value < top[j].Value
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// flatten returns the distinct scalar values of a line under each path
+func flatten(parsed map[string]interface{}) map[string]map[string]bool {
+	out := make(map[string]map[string]bool)
+	var walk func(path string, value interface{})
+	walk = func(path string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				walk(parser.AppendKey(path, key), child)
+			}
+		case []interface{}:
+			for _, elem := range v {
+				walk(path+"[*]", elem)
+			}
+		default:
+			if out[path] == nil {
+				out[path] = make(map[string]bool)
+			}
+			out[path][parser.FormatValue(v)] = true
+		}
+	}
+	walk("", parsed)
+	return out
+}
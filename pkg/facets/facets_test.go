@@ -0,0 +1,69 @@
+package facets
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+// parseLines parses raw lines for counting
+func parseLines(raws ...string) []*models.LogLine {
+	p := parser.New()
+	lines := make([]*models.LogLine, len(raws))
+	for i, raw := range raws {
+		lines[i] = &models.LogLine{Raw: raw}
+		p.ParseLogLine(lines[i])
+	}
+	return lines
+}
+
+func TestCounter(t *testing.T) {
+	lines := parseLines(
+		`{"user":"alice","http":{"status":503},"tags":["api","prod"]}`,
+		`{"user":"bob","http":{"status":200},"tags":["api"]}`,
+		`{"user":"alice","http":{"status":200}}`,
+		`plain text`,
+	)
+
+	c := New(100)
+	for _, line := range lines {
+		c.Add(line)
+	}
+
+	if c.Lines() != 4 {
+		t.Errorf("Expected 4 lines, got %d", c.Lines())
+	}
+	if got := fmt.Sprint(c.Fields()); got != "[{http.status 3 2 false} {tags[*] 2 2 false} {user 3 2 false}]" {
+		t.Errorf("Unexpected fields %s", got)
+	}
+	if got := fmt.Sprint(c.Top("user", 5)); got != "[{alice 2} {bob 1}]" {
+		t.Errorf("Unexpected top users %s", got)
+	}
+	if got := fmt.Sprint(c.Top("tags[*]", 1)); got != "[{api 2}]" {
+		t.Errorf("Unexpected top tags %s", got)
+	}
+
+	// Evicting lines takes their counts back out
+	c.Remove(lines[0])
+	c.Remove(lines[1])
+	if got := fmt.Sprint(c.Fields()); got != "[{http.status 1 1 false} {user 1 1 false}]" {
+		t.Errorf("Unexpected fields after removal %s", got)
+	}
+}
+
+func TestCounterOverflow(t *testing.T) {
+	c := New(2)
+	for _, line := range parseLines(`{"id":"a"}`, `{"id":"b"}`, `{"id":"c"}`, `{"id":"a"}`) {
+		c.Add(line)
+	}
+
+	stats := c.Fields()
+	if len(stats) != 1 || stats[0].Lines != 4 || stats[0].Cardinality != 2 || !stats[0].Overflow {
+		t.Errorf("Unexpected stats %v", stats)
+	}
+	if got := fmt.Sprint(c.Top("id", 5)); got != "[{a 2} {b 1}]" {
+		t.Errorf("Unexpected top ids %s", got)
+	}
+}
@@ -2,12 +2,14 @@ package filter
 
 import (
 	"fmt"
+	"net/netip"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 	
 	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
 )
 
 // QueryExpression represents a parsed query expression tree
@@ -63,15 +65,35 @@ type FieldExpression struct {
 	Operator string
 	Value    string
 	Pattern  *regexp.Regexp
+	Network  *netip.Prefix // CIDR values such as 10.0.0.0/8
 }
 
 func (e *FieldExpression) Evaluate(line *models.LogLine, f *FilterEngine) bool {
-	fieldValue := f.extractFieldValue(line, e.Field)
-	return f.matchFieldExpression(fieldValue, e)
+	values := f.extractFieldValues(line, e.Field)
+	if len(values) == 0 {
+		return f.matchFieldExpression("", e)
+	}
+	
+	// Negated operators must hold for every value, the rest for any value
+	if e.Operator == ":!=" {
+		for _, value := range values {
+			if !f.matchFieldExpression(value, e) {
+				return false
+			}
+		}
+		return true
+	}
+	
+	for _, value := range values {
+		if f.matchFieldExpression(value, e) {
+			return true
+		}
+	}
+	return false
 }
 
 func (e *FieldExpression) String() string {
-	return fmt.Sprintf("%s%s%s", e.Field, e.Operator, e.Value)
+	return fmt.Sprintf("%s%s%s", e.Field, e.Operator, quoteValue(e.Value))
 }
 
 // TextExpression represents simple text searches
@@ -99,397 +121,200 @@ func (e *TextExpression) Evaluate(line *models.LogLine, f *FilterEngine) bool {
 
 func (e *TextExpression) String() string {
 	if e.IsRegex {
-		return fmt.Sprintf("~\"%s\"", e.Text)
+		return "~" + quoteValue(e.Text)
 	}
-	return fmt.Sprintf("\"%s\"", e.Text)
-}
-
-// TimeRangeExpression represents time-based filtering
-type TimeRangeExpression struct {
-	Start time.Time
-	End   time.Time
-}
-
-func (e *TimeRangeExpression) Evaluate(line *models.LogLine, f *FilterEngine) bool {
-	if line.Timestamp.IsZero() {
-		return false
+	if e.CaseSensitive {
+		return quoteString(e.Text)
 	}
-	return !line.Timestamp.Before(e.Start) && !line.Timestamp.After(e.End)
+	return quoteValue(e.Text)
 }
 
-func (e *TimeRangeExpression) String() string {
-	return fmt.Sprintf("time:[%s TO %s]", e.Start.Format("15:04:05"), e.End.Format("15:04:05"))
-}
+// MatchAllExpression matches every line
+type MatchAllExpression struct{}
 
-// AdvancedQueryParser parses complex filter expressions
-type AdvancedQueryParser struct {
-	input string
-	pos   int
+func (e *MatchAllExpression) Evaluate(line *models.LogLine, f *FilterEngine) bool {
+	return true
 }
 
-// ParseAdvancedQuery parses an advanced query string into an expression tree
-func (f *FilterEngine) ParseAdvancedQuery(query string) (QueryExpression, error) {
-	parser := &AdvancedQueryParser{
-		input: strings.TrimSpace(query),
-		pos:   0,
-	}
-	
-	return parser.parseExpression()
+func (e *MatchAllExpression) String() string {
+	return "*"
 }
 
-// parseExpression parses the main expression (handles OR at top level)
-func (p *AdvancedQueryParser) parseExpression() (QueryExpression, error) {
-	left, err := p.parseAndExpression()
-	if err != nil {
-		return nil, err
-	}
-	
-	for p.pos < len(p.input) {
-		p.skipWhitespace()
-		if !p.matchKeyword("OR") {
-			break
-		}
-		
-		right, err := p.parseAndExpression()
-		if err != nil {
-			return nil, err
-		}
-		
-		left = &OrExpression{Left: left, Right: right}
-	}
-	
-	return left, nil
+// ExistsExpression matches lines where a field has a non-empty value
+type ExistsExpression struct {
+	Field string
 }
 
-// parseAndExpression parses AND expressions
-func (p *AdvancedQueryParser) parseAndExpression() (QueryExpression, error) {
-	left, err := p.parseUnaryExpression()
-	if err != nil {
-		return nil, err
-	}
-	
-	for p.pos < len(p.input) {
-		p.skipWhitespace()
-		
-		// Check for explicit AND or implicit AND (space)
-		isExplicitAnd := p.matchKeyword("AND")
-		if !isExplicitAnd {
-			// Check if next token looks like another expression
-			oldPos := p.pos
-			if !p.lookaheadForExpression() {
-				p.pos = oldPos
-				break
-			}
-			p.pos = oldPos
+func (e *ExistsExpression) Evaluate(line *models.LogLine, f *FilterEngine) bool {
+	for _, value := range f.extractFieldValues(line, e.Field) {
+		if value != "" {
+			return true
 		}
-		
-		right, err := p.parseUnaryExpression()
-		if err != nil {
-			return nil, err
-		}
-		
-		left = &AndExpression{Left: left, Right: right}
 	}
-	
-	return left, nil
+	return false
 }
 
-// parseUnaryExpression parses NOT expressions and primary expressions
-func (p *AdvancedQueryParser) parseUnaryExpression() (QueryExpression, error) {
-	p.skipWhitespace()
-	
-	if p.matchKeyword("NOT") {
-		expr, err := p.parseUnaryExpression()
-		if err != nil {
-			return nil, err
-		}
-		return &NotExpression{Expression: expr}, nil
-	}
-	
-	return p.parsePrimaryExpression()
+func (e *ExistsExpression) String() string {
+	return e.Field + ":*"
 }
 
-// parsePrimaryExpression parses field queries, text queries, and parentheses
-func (p *AdvancedQueryParser) parsePrimaryExpression() (QueryExpression, error) {
-	p.skipWhitespace()
-	
-	if p.pos >= len(p.input) {
-		return nil, fmt.Errorf("unexpected end of query")
-	}
-	
-	// Handle parentheses
-	if p.input[p.pos] == '(' {
-		p.pos++ // consume '('
-		expr, err := p.parseExpression()
-		if err != nil {
-			return nil, err
-		}
-		
-		p.skipWhitespace()
-		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
-			return nil, fmt.Errorf("missing closing parenthesis")
-		}
-		p.pos++ // consume ')'
-		return expr, nil
-	}
-	
-	// Parse field or text expression
-	token := p.parseToken()
-	if token == "" {
-		return nil, fmt.Errorf("empty expression")
-	}
-	
-	// Check for field query (contains ':')
-	if strings.Contains(token, ":") {
-		return p.parseFieldExpression(token)
-	}
-	
-	// Check for time range query
-	if strings.HasPrefix(token, "time:[") {
-		return p.parseTimeRangeExpression(token)
-	}
-	
-	// Handle text expression
-	return p.parseTextExpression(token)
+// TimeRangeExpression represents time-based filtering. A nil bound leaves
+// that side open; relative bounds are resolved as each line is evaluated.
+type TimeRangeExpression struct {
+	Start          *TimeBound
+	End            *TimeBound
+	ExclusiveStart bool
+	ExclusiveEnd   bool
+	Label          string // Query text for shorthand forms such as @today
 }
 
-// parseFieldExpression parses field-based expressions
-func (p *AdvancedQueryParser) parseFieldExpression(token string) (*FieldExpression, error) {
-	// Find operator
-	operators := []string{":!=", ":~", ":>", ":<", ":>=", ":<=", ":"}
-	var field, operator, value string
-	
-	for _, op := range operators {
-		if idx := strings.Index(token, op); idx != -1 {
-			field = token[:idx]
-			operator = op
-			value = token[idx+len(op):]
-			break
-		}
-	}
-	
-	if field == "" || operator == "" {
-		return nil, fmt.Errorf("invalid field expression: %s", token)
+func (e *TimeRangeExpression) Evaluate(line *models.LogLine, f *FilterEngine) bool {
+	if line.Timestamp.IsZero() {
+		return false
 	}
 	
-	expr := &FieldExpression{
-		Field:    field,
-		Operator: operator,
-		Value:    value,
+	at := now()
+	if e.Start != nil {
+		start := e.Start.Resolve(at)
+		if line.Timestamp.Before(start) || (e.ExclusiveStart && line.Timestamp.Equal(start)) {
+			return false
+		}
 	}
-	
-	// Compile regex if needed
-	if operator == ":~" || (strings.Contains(value, "|") && strings.Contains(value, "(")) {
-		pattern, err := regexp.Compile(value)
-		if err != nil {
-			return nil, fmt.Errorf("invalid regex in field expression: %w", err)
+	if e.End != nil {
+		end := e.End.Resolve(at)
+		if line.Timestamp.After(end) || (e.ExclusiveEnd && line.Timestamp.Equal(end)) {
+			return false
 		}
-		expr.Pattern = pattern
 	}
-	
-	return expr, nil
+	return true
 }
 
-// parseTimeRangeExpression parses time range expressions
-func (p *AdvancedQueryParser) parseTimeRangeExpression(token string) (*TimeRangeExpression, error) {
-	// Format: time:[HH:MM:SS TO HH:MM:SS] or time:[YYYY-MM-DD HH:MM:SS TO YYYY-MM-DD HH:MM:SS]
-	if !strings.HasPrefix(token, "time:[") || !strings.HasSuffix(token, "]") {
-		return nil, fmt.Errorf("invalid time range format: %s", token)
-	}
-	
-	timeSpec := token[6 : len(token)-1] // Remove "time:[" and "]"
-	parts := strings.Split(timeSpec, " TO ")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("time range must have format: time:[start TO end]")
-	}
-	
-	start, err := p.parseTimeValue(strings.TrimSpace(parts[0]))
-	if err != nil {
-		return nil, fmt.Errorf("invalid start time: %w", err)
-	}
-	
-	end, err := p.parseTimeValue(strings.TrimSpace(parts[1]))
-	if err != nil {
-		return nil, fmt.Errorf("invalid end time: %w", err)
+func (e *TimeRangeExpression) String() string {
+	switch {
+	case e.Label != "":
+		return e.Label
+	case e.Start != nil && e.End != nil:
+		return fmt.Sprintf("time:[%s TO %s]", e.Start, e.End)
+	case e.Start != nil && e.ExclusiveStart:
+		return "time:>" + quoteValue(e.Start.String())
+	case e.Start != nil:
+		return "time:>=" + quoteValue(e.Start.String())
+	case e.End != nil && e.ExclusiveEnd:
+		return "time:<" + quoteValue(e.End.String())
+	case e.End != nil:
+		return "time:<=" + quoteValue(e.End.String())
+	default:
+		return "time:*"
 	}
-	
-	return &TimeRangeExpression{Start: start, End: end}, nil
 }
 
-// parseTimeValue parses various time formats
-func (p *AdvancedQueryParser) parseTimeValue(timeStr string) (time.Time, error) {
-	formats := []string{
-		"15:04:05",
-		"2006-01-02 15:04:05",
-		"2006-01-02T15:04:05",
-		"2006-01-02T15:04:05Z",
-		time.RFC3339,
-	}
-	
-	now := time.Now()
-	
-	for _, format := range formats {
-		if t, err := time.Parse(format, timeStr); err == nil {
-			// If only time is specified, use today's date
-			if format == "15:04:05" {
-				return time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
-			}
-			return t, nil
-		}
-	}
-	
-	return time.Time{}, fmt.Errorf("unable to parse time: %s", timeStr)
+// IsRelative reports whether either bound moves with the current time
+func (e *TimeRangeExpression) IsRelative() bool {
+	return (e.Start != nil && e.Start.IsRelative()) || (e.End != nil && e.End.IsRelative())
 }
 
-// parseTextExpression parses text-based expressions
-func (p *AdvancedQueryParser) parseTextExpression(token string) (*TextExpression, error) {
-	expr := &TextExpression{
-		Text:          token,
-		CaseSensitive: false,
-		IsRegex:       false,
+// walkExpression calls fn for expr and each of its descendants, depth
+// first, until fn returns false
+func walkExpression(expr QueryExpression, fn func(QueryExpression) bool) bool {
+	if expr == nil {
+		return true
 	}
-	
-	// Check for regex prefix
-	if strings.HasPrefix(token, "~") {
-		expr.IsRegex = true
-		expr.Text = token[1:]
-		
-		pattern, err := regexp.Compile("(?i)" + expr.Text)
-		if err != nil {
-			return nil, fmt.Errorf("invalid regex: %w", err)
-		}
-		expr.Pattern = pattern
+	if !fn(expr) {
+		return false
 	}
 	
-	// Remove quotes if present
-	if strings.HasPrefix(expr.Text, "\"") && strings.HasSuffix(expr.Text, "\"") {
-		expr.Text = expr.Text[1 : len(expr.Text)-1]
-		expr.CaseSensitive = true
+	switch e := expr.(type) {
+	case *AndExpression:
+		return walkExpression(e.Left, fn) && walkExpression(e.Right, fn)
+	case *OrExpression:
+		return walkExpression(e.Left, fn) && walkExpression(e.Right, fn)
+	case *NotExpression:
+		return walkExpression(e.Expression, fn)
 	}
-	
-	return expr, nil
+	return true
 }
 
-// Utility functions
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
 
-func (p *AdvancedQueryParser) skipWhitespace() {
-	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
-		p.pos++
-	}
+// FieldValues returns every value a field resolves to on a line, with the
+// same aliases and path syntax as field queries
+func (f *FilterEngine) FieldValues(line *models.LogLine, field string) []string {
+	return f.extractFieldValues(line, field)
 }
 
-func (p *AdvancedQueryParser) matchKeyword(keyword string) bool {
-	p.skipWhitespace()
-	if p.pos+len(keyword) > len(p.input) {
-		return false
-	}
-	
-	if strings.ToUpper(p.input[p.pos:p.pos+len(keyword)]) == keyword {
-		// Check that it's a complete word
-		end := p.pos + len(keyword)
-		if end < len(p.input) && isAlphaNumeric(p.input[end]) {
-			return false
-		}
-		p.pos += len(keyword)
-		return true
-	}
-	
-	return false
+// FieldValue returns the first value of a field, or "" when it is absent
+func (f *FilterEngine) FieldValue(line *models.LogLine, field string) string {
+	return f.extractFieldValue(line, field)
 }
 
-func (p *AdvancedQueryParser) parseToken() string {
-	p.skipWhitespace()
-	start := p.pos
-	
-	if p.pos >= len(p.input) {
+// extractFieldValue extracts the first value of a field from a log line
+func (f *FilterEngine) extractFieldValue(line *models.LogLine, field string) string {
+	values := f.extractFieldValues(line, field)
+	if len(values) == 0 {
 		return ""
 	}
-	
-	// Handle quoted strings
-	if p.input[p.pos] == '"' {
-		p.pos++ // consume opening quote
-		for p.pos < len(p.input) && p.input[p.pos] != '"' {
-			p.pos++
-		}
-		if p.pos < len(p.input) {
-			p.pos++ // consume closing quote
+	return values[0]
+}
+
+// extractFieldValues extracts every value a field resolves to. Built-in
+// fields yield one value; parsed fields may yield several through wildcards.
+func (f *FilterEngine) extractFieldValues(line *models.LogLine, field string) []string {
+	// Aliases such as src, msg or file give way to a parsed field of the
+	// same name (CEF src=, klog file=); canonical names always win
+	_, shadowed := line.Parsed[field]
+	if shadowed {
+		switch strings.ToLower(field) {
+		case "level", "source", "timestamp":
+			shadowed = false
 		}
-		return p.input[start:p.pos]
 	}
 	
-	// Handle time range expressions
-	if strings.HasPrefix(p.input[p.pos:], "time:[") {
-		depth := 0
-		for p.pos < len(p.input) {
-			if p.input[p.pos] == '[' {
-				depth++
-			} else if p.input[p.pos] == ']' {
-				depth--
-				if depth == 0 {
-					p.pos++
-					break
-				}
-			}
-			p.pos++
+	if value, ok := f.extractBuiltinField(line, field); ok && !shadowed {
+		if value != "" {
+			return []string{value}
+		}
+		// An unset attribute falls back to a parsed field of that name
+		if _, parsed := line.Parsed[field]; !parsed {
+			return nil
 		}
-		return p.input[start:p.pos]
 	}
 	
-	// Handle regular tokens
-	for p.pos < len(p.input) && !isWhitespace(p.input[p.pos]) && p.input[p.pos] != '(' && p.input[p.pos] != ')' {
-		p.pos++
+	// Check parsed fields for structured logs (JSON/YAML)
+	raw := f.parser.GetParsedFieldValues(line, field)
+	if len(raw) == 0 {
+		return nil
 	}
-	
-	return p.input[start:p.pos]
-}
-
-func (p *AdvancedQueryParser) lookaheadForExpression() bool {
-	oldPos := p.pos
-	defer func() { p.pos = oldPos }()
-	
-	token := p.parseToken()
-	return token != "" && (strings.Contains(token, ":") || 
-		strings.HasPrefix(token, "time:[") || 
-		strings.HasPrefix(token, "~") ||
-		strings.HasPrefix(token, "\""))
-}
-
-func isWhitespace(ch byte) bool {
-	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
-}
-
-func isAlphaNumeric(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_'
+	values := make([]string, len(raw))
+	for i, val := range raw {
+		values[i] = parser.FormatValue(val)
+	}
+	return values
 }
 
-// extractFieldValue extracts field value from a log line
-func (f *FilterEngine) extractFieldValue(line *models.LogLine, field string) string {
+// extractBuiltinField extracts the value of a built-in line attribute
+func (f *FilterEngine) extractBuiltinField(line *models.LogLine, field string) (string, bool) {
 	switch strings.ToLower(field) {
 	case "level", "severity", "lvl":
-		return line.Level
+		return line.Level, true
 	case "source", "file", "src":
-		return line.Source
+		return line.Source, true
 	case "message", "msg", "text", "raw":
-		return line.Raw
+		return line.Raw, true
 	case "timestamp", "time", "ts":
 		if !line.Timestamp.IsZero() {
-			return line.Timestamp.Format(time.RFC3339)
+			return line.Timestamp.Format(time.RFC3339), true
 		}
-		return ""
+		return "", true
 	case "id":
-		return line.ID
+		return line.ID, true
 	case "line", "linenum":
-		return strconv.Itoa(line.LineNum)
+		return strconv.Itoa(line.LineNum), true
 	case "offset":
-		return strconv.FormatInt(line.Offset, 10)
+		return strconv.FormatInt(line.Offset, 10), true
 	default:
-		// Check parsed fields for structured logs (JSON/YAML)
-		if line.Parsed != nil {
-			if val := f.parser.GetParsedField(line, field); val != nil {
-				return fmt.Sprintf("%v", val)
-			}
-		}
-		return ""
+		return "", false
 	}
 }
 
@@ -497,9 +322,9 @@ func (f *FilterEngine) extractFieldValue(line *models.LogLine, field string) str
 func (f *FilterEngine) matchFieldExpression(fieldValue string, expr *FieldExpression) bool {
 	switch expr.Operator {
 	case ":":
-		return strings.EqualFold(fieldValue, expr.Value)
+		return matchEquals(fieldValue, expr)
 	case ":!=":
-		return !strings.EqualFold(fieldValue, expr.Value)
+		return !matchEquals(fieldValue, expr)
 	case ":~":
 		if expr.Pattern != nil {
 			return expr.Pattern.MatchString(fieldValue)
@@ -512,23 +337,51 @@ func (f *FilterEngine) matchFieldExpression(fieldValue string, expr *FieldExpres
 	}
 }
 
+// matchEquals checks a value against a network, wildcard or literal
+func matchEquals(fieldValue string, expr *FieldExpression) bool {
+	switch {
+	case expr.Network != nil:
+		addr, ok := parseIP(fieldValue)
+		return ok && expr.Network.Contains(addr)
+	case expr.Pattern != nil:
+		return expr.Pattern.MatchString(fieldValue)
+	default:
+		return strings.EqualFold(fieldValue, expr.Value)
+	}
+}
+
+// parseIP parses an IP address, also accepting ip:port and [v6]:port forms
+func parseIP(s string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), true
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
 // matchComparison handles comparison operations
 func (f *FilterEngine) matchComparison(fieldValue, queryValue, operator string) bool {
-	// Try numeric comparison first
-	fieldNum, err1 := strconv.ParseFloat(fieldValue, 64)
-	queryNum, err2 := strconv.ParseFloat(queryValue, 64)
-	
-	if err1 == nil && err2 == nil {
+	// Compare numbers, durations and sizes by value
+	if cmp, ok := compareQuantities(fieldValue, queryValue); ok {
 		switch operator {
 		case ":>":
-			return fieldNum > queryNum
+			return cmp > 0
 		case ":<":
-			return fieldNum < queryNum
+			return cmp < 0
 		case ":>=":
-			return fieldNum >= queryNum
+			return cmp >= 0
 		case ":<=":
-			return fieldNum <= queryNum
+			return cmp <= 0
 		}
+		return false
+	}
+	
+	// Quantities of different dimensions (250ms vs 10MB) never match, nor
+	// does a value that is not a number, such as NaN, match a number
+	if _, ok := parseQuantity(queryValue); ok {
+		return false
 	}
 	
 	// Fallback to string comparison
@@ -546,27 +399,13 @@ func (f *FilterEngine) matchComparison(fieldValue, queryValue, operator string)
 	return false
 }
 
-// SetAdvancedFilter sets an advanced filter using the expression tree
+// SetAdvancedFilter parses a query string and sets it as the filter. It is
+// shorthand for SetFilter with only a query.
 func (f *FilterEngine) SetAdvancedFilter(query string) error {
-	if query == "" {
+	if strings.TrimSpace(query) == "" {
 		f.Clear()
 		return nil
 	}
 	
-	expr, err := f.ParseAdvancedQuery(query)
-	if err != nil {
-		return fmt.Errorf("failed to parse advanced query: %w", err)
-	}
-	
-	// Store the compiled expression
-	f.compiledQuery = &CompiledQuery{
-		KeywordQuery: query, // Store original query for summary
-	}
-	f.advancedExpression = expr
-	
-	return nil
+	return f.SetFilter(models.FilterOptions{Query: query})
 }
-
-// FilterEngine methods for advanced filtering
-
-
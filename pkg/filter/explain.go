@@ -0,0 +1,234 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// Planner narrows a search to candidate lines, such as a search index
+type Planner interface {
+	Candidates(expr QueryExpression) ([]uint32, bool)
+}
+
+// Explanation describes how a query is evaluated and how it performs on a
+// set of lines
+type Explanation struct {
+	Query    string
+	Root     *ExplainNode
+	Lines    int
+	Matches  int
+	Duration time.Duration
+	Warnings []string
+}
+
+// ExplainNode is one node of an explained query with its strategy and its
+// own matches and time on the lines
+type ExplainNode struct {
+	Label      string
+	Strategy   string // "index" or "scan"
+	Candidates int    // Lines the index selects, when indexed
+	Matches    int
+	Duration   time.Duration
+	Children   []*ExplainNode
+}
+
+// Explain compiles options without installing them and evaluates every
+// node of the query on lines. The planner, which may be nil, decides which
+// nodes an index could answer.
+func (f *FilterEngine) Explain(options models.FilterOptions, lines []*models.LogLine, planner Planner) (*Explanation, error) {
+	probe := New(f.parser)
+	if err := probe.SetFilter(options); err != nil {
+		return nil, err
+	}
+	if !probe.HasFilter() {
+		return nil, fmt.Errorf("nothing to explain: the query is empty")
+	}
+
+	ex := &Explanation{Query: options.Query, Lines: len(lines)}
+	var exprs []QueryExpression
+	if seq := probe.sequence; seq != nil {
+		ex.Root = &ExplainNode{Label: seq.String(), Strategy: "scan"}
+		for _, step := range seq.Steps {
+			ex.Root.Children = append(ex.Root.Children, probe.explainNode(step, lines, planner))
+			exprs = append(exprs, step)
+		}
+
+		start := time.Now()
+		for _, line := range lines {
+			ex.Root.Matches += len(probe.Feed(line))
+		}
+		ex.Root.Matches += len(probe.Expire(now()))
+		ex.Root.Duration = time.Since(start)
+		ex.Warnings = append(ex.Warnings, probe.missingFields(seq.By, lines)...)
+	} else {
+		ex.Root = probe.explainNode(probe.expression, lines, planner)
+		exprs = append(exprs, probe.expression)
+	}
+	ex.Matches = ex.Root.Matches
+	ex.Duration = ex.Root.Duration
+
+	ex.Warnings = append(ex.Warnings, probe.explainWarnings(exprs, lines)...)
+	if planner != nil && ex.Root.Strategy == "scan" && probe.sequence == nil {
+		ex.Warnings = append(ex.Warnings, "the index cannot narrow this query; every line is scanned")
+	}
+	return ex, nil
+}
+
+// explainNode measures an expression and its operands on lines
+func (f *FilterEngine) explainNode(expr QueryExpression, lines []*models.LogLine, planner Planner) *ExplainNode {
+	node := &ExplainNode{Label: expr.String(), Strategy: "scan"}
+	switch e := expr.(type) {
+	case *AndExpression:
+		node.Label = "AND"
+		node.Children = []*ExplainNode{f.explainNode(e.Left, lines, planner), f.explainNode(e.Right, lines, planner)}
+	case *OrExpression:
+		node.Label = "OR"
+		node.Children = []*ExplainNode{f.explainNode(e.Left, lines, planner), f.explainNode(e.Right, lines, planner)}
+	case *NotExpression:
+		node.Label = "NOT"
+		node.Children = []*ExplainNode{f.explainNode(e.Expression, lines, planner)}
+	}
+
+	if planner != nil {
+		if candidates, ok := planner.Candidates(expr); ok {
+			node.Strategy = "index"
+			node.Candidates = len(candidates)
+		}
+	}
+
+	start := time.Now()
+	for _, line := range lines {
+		if expr.Evaluate(line, f) {
+			node.Matches++
+		}
+	}
+	node.Duration = time.Since(start)
+	return node
+}
+
+// explainWarnings points out parts of a query that cannot match the lines
+func (f *FilterEngine) explainWarnings(exprs []QueryExpression, lines []*models.LogLine) []string {
+	if len(lines) == 0 {
+		return []string{"no lines to measure the query against"}
+	}
+
+	var fields []string
+	var warnings []string
+	timed := false
+	for _, expr := range exprs {
+		walkExpression(expr, func(e QueryExpression) bool {
+			switch e := e.(type) {
+			case *FieldExpression:
+				fields = append(fields, e.Field)
+			case *ExistsExpression:
+				fields = append(fields, e.Field)
+			case *TimeRangeExpression:
+				timed = true
+			}
+			return true
+		})
+	}
+	warnings = append(warnings, f.missingFields(fields, lines)...)
+
+	if timed && !hasTimestamps(lines) {
+		warnings = append(warnings, "no line has a timestamp, so time ranges match nothing")
+	}
+	return warnings
+}
+
+// missingFields warns about parsed fields that no line has
+func (f *FilterEngine) missingFields(fields []string, lines []*models.LogLine) []string {
+	var warnings []string
+	seen := make(map[string]bool)
+	for _, field := range fields {
+		if seen[field] {
+			continue
+		}
+		seen[field] = true
+
+		if _, builtin := f.extractBuiltinField(&models.LogLine{}, field); builtin {
+			continue
+		}
+		present := false
+		for _, line := range lines {
+			if len(f.extractFieldValues(line, field)) > 0 {
+				present = true
+				break
+			}
+		}
+		if !present {
+			warnings = append(warnings, fmt.Sprintf("field `%s` not present in any parsed line", field))
+		}
+	}
+	return warnings
+}
+
+// hasTimestamps reports whether any line has a timestamp
+func hasTimestamps(lines []*models.LogLine) bool {
+	for _, line := range lines {
+		if !line.Timestamp.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the explanation as an indented tree with a line per node
+func (ex *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Query: %s\n\n", ex.Query)
+
+	var rows [][2]string
+	var walk func(node *ExplainNode, prefix, branch string)
+	walk = func(node *ExplainNode, prefix, branch string) {
+		rows = append(rows, [2]string{prefix + branch + node.Label, ex.describe(node)})
+		childPrefix := prefix
+		switch branch {
+		case "├─ ":
+			childPrefix += "│  "
+		case "└─ ":
+			childPrefix += "   "
+		}
+		for i, child := range node.Children {
+			if i == len(node.Children)-1 {
+				walk(child, childPrefix, "└─ ")
+			} else {
+				walk(child, childPrefix, "├─ ")
+			}
+		}
+	}
+	walk(ex.Root, "", "")
+
+	width := 0
+	for _, row := range rows {
+		width = max(width, len([]rune(row[0])))
+	}
+	for _, row := range rows {
+		fmt.Fprintf(&b, "%s%s  %s\n", row[0], strings.Repeat(" ", width-len([]rune(row[0]))), row[1])
+	}
+
+	fmt.Fprintf(&b, "\nMatches: %d of %d lines in %v\n", ex.Matches, ex.Lines, ex.Duration.Round(time.Microsecond))
+	if len(ex.Warnings) > 0 {
+		b.WriteString("\nWarnings:\n")
+		for _, warning := range ex.Warnings {
+			fmt.Fprintf(&b, "  ! %s\n", warning)
+		}
+	}
+	return b.String()
+}
+
+// describe renders a node's strategy, selectivity and time
+func (ex *Explanation) describe(node *ExplainNode) string {
+	strategy := node.Strategy
+	if node.Strategy == "index" {
+		strategy = fmt.Sprintf("index (%d lines)", node.Candidates)
+	}
+	selectivity := 0.0
+	if ex.Lines > 0 {
+		selectivity = float64(node.Matches) * 100 / float64(ex.Lines)
+	}
+	return fmt.Sprintf("%-24s %8d  %6.2f%%  %v", strategy, node.Matches, selectivity, node.Duration.Round(time.Microsecond))
+}
@@ -0,0 +1,401 @@
+package filter
+
+import (
+	"strings"
+)
+
+// tokenKind identifies the kind of a query token
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokWord             // bare word, escapes removed
+	tokString           // "quoted string", escapes removed
+	tokRegex            // ~word or ~"quoted" text regex
+	tokField            // field name followed by ':' (the ':' is consumed)
+	tokLParen           // (
+	tokRParen           // )
+	tokAnd              // AND, &&
+	tokOr               // OR, ||
+	tokNot              // NOT, !
+)
+
+// String returns a readable name for error messages
+func (k tokenKind) String() string {
+	switch k {
+	case tokEOF:
+		return "end of query"
+	case tokWord:
+		return "word"
+	case tokString:
+		return "quoted string"
+	case tokRegex:
+		return "regex"
+	case tokField:
+		return "field"
+	case tokLParen:
+		return "'('"
+	case tokRParen:
+		return "')'"
+	case tokAnd:
+		return "AND"
+	case tokOr:
+		return "OR"
+	case tokNot:
+		return "NOT"
+	default:
+		return "token"
+	}
+}
+
+// token is a lexical token with its byte position in the query
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	end  int
+}
+
+// valueKind identifies the shape of a field value
+type valueKind int
+
+const (
+	valueBare   valueKind = iota // level:ERROR
+	valueQuoted                  // msg:"connection lost"
+	valueGroup                   // level:(ERROR|WARN), status:IN(500,503)
+	valueRange                   // status:[400 TO 499]
+)
+
+// fieldValue is the operator and value following a field token
+type fieldValue struct {
+	op   string // "", "=", "!=", "!", "~", ">", ">=", "<", "<="
+	kind valueKind
+	text string // value with quotes/escapes removed; raw inner text for groups and ranges
+	pos  int    // position of the value (after the operator)
+}
+
+// lexer tokenizes query strings. It is modal: the parser asks for a field
+// value explicitly after a field token, since values follow different
+// rules (operators, ranges, unbalanced characters) than terms.
+type lexer struct {
+	input  string
+	pos    int
+	peeked *token
+}
+
+// newLexer creates a lexer for the query
+func newLexer(input string) *lexer {
+	return &lexer{input: input}
+}
+
+// peek returns the next token without consuming it
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		tok, err := l.scan()
+		if err != nil {
+			return tok, err
+		}
+		l.peeked = &tok
+	}
+	return *l.peeked, nil
+}
+
+// next consumes and returns the next token
+func (l *lexer) next() (token, error) {
+	tok, err := l.peek()
+	l.peeked = nil
+	return tok, err
+}
+
+// skipWhitespace advances past spaces and tabs
+func (l *lexer) skipWhitespace() {
+	for l.pos < len(l.input) && isWhitespace(l.input[l.pos]) {
+		l.pos++
+	}
+}
+
+// scan reads the next token in term mode
+func (l *lexer) scan() (token, error) {
+	l.skipWhitespace()
+	start := l.pos
+
+	if l.pos >= len(l.input) {
+		return token{kind: tokEOF, pos: start, end: start}, nil
+	}
+
+	ch := l.input[l.pos]
+	switch {
+	case ch == '(':
+		l.pos++
+		return token{kind: tokLParen, text: "(", pos: start, end: l.pos}, nil
+
+	case ch == ')':
+		l.pos++
+		return token{kind: tokRParen, text: ")", pos: start, end: l.pos}, nil
+
+	case strings.HasPrefix(l.input[l.pos:], "&&"):
+		l.pos += 2
+		return token{kind: tokAnd, text: "&&", pos: start, end: l.pos}, nil
+
+	case strings.HasPrefix(l.input[l.pos:], "||"):
+		l.pos += 2
+		return token{kind: tokOr, text: "||", pos: start, end: l.pos}, nil
+
+	case ch == '!' && l.pos+1 < len(l.input) && !isWhitespace(l.input[l.pos+1]) && l.input[l.pos+1] != '=':
+		l.pos++
+		return token{kind: tokNot, text: "!", pos: start, end: l.pos}, nil
+
+	case ch == '"':
+		text, err := l.scanQuoted()
+		if err != nil {
+			return token{}, err
+		}
+		// A quoted string directly followed by ':' names a field
+		if l.pos < len(l.input) && l.input[l.pos] == ':' {
+			l.pos++
+			return token{kind: tokField, text: l.input[start : l.pos-1], pos: start, end: l.pos}, nil
+		}
+		return token{kind: tokString, text: text, pos: start, end: l.pos}, nil
+
+	case ch == '~':
+		l.pos++
+		if l.pos < len(l.input) && l.input[l.pos] == '"' {
+			text, err := l.scanQuoted()
+			if err != nil {
+				return token{}, err
+			}
+			return token{kind: tokRegex, text: text, pos: start, end: l.pos}, nil
+		}
+		text := l.scanBare(false)
+		if text == "" {
+			return token{}, &QueryError{Query: l.input, Pos: start, Message: "missing regex after '~'"}
+		}
+		return token{kind: tokRegex, text: text, pos: start, end: l.pos}, nil
+	}
+
+	return l.scanWordOrField(start)
+}
+
+// scanWordOrField reads a bare word, or a field name when an unescaped ':'
+// is reached. Brackets in field paths (errors[0], ['a.b']) may hold any
+// character.
+func (l *lexer) scanWordOrField(start int) (token, error) {
+	var word strings.Builder
+	brackets := 0
+
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+
+		if brackets > 0 {
+			if ch == ']' {
+				brackets--
+			}
+			word.WriteByte(ch)
+			l.pos++
+			continue
+		}
+
+		if isWhitespace(ch) || ch == '(' || ch == ')' || ch == '"' {
+			break
+		}
+
+		if ch == '\\' && l.pos+1 < len(l.input) {
+			word.WriteByte(ch)
+			word.WriteByte(l.input[l.pos+1])
+			l.pos += 2
+			continue
+		}
+
+		if ch == '[' {
+			brackets++
+		}
+
+		if ch == ':' && word.Len() > 0 {
+			l.pos++
+			return token{kind: tokField, text: word.String(), pos: start, end: l.pos}, nil
+		}
+
+		word.WriteByte(ch)
+		l.pos++
+	}
+
+	if brackets > 0 {
+		return token{}, &QueryError{Query: l.input, Pos: start, Message: "missing ']'"}
+	}
+
+	raw := word.String()
+	switch raw {
+	case "AND":
+		return token{kind: tokAnd, text: raw, pos: start, end: l.pos}, nil
+	case "OR":
+		return token{kind: tokOr, text: raw, pos: start, end: l.pos}, nil
+	case "NOT":
+		return token{kind: tokNot, text: raw, pos: start, end: l.pos}, nil
+	}
+
+	return token{kind: tokWord, text: unescape(raw), pos: start, end: l.pos}, nil
+}
+
+// scanQuoted reads a double-quoted string starting at the current position
+func (l *lexer) scanQuoted() (string, error) {
+	start := l.pos
+	l.pos++ // opening quote
+
+	var text strings.Builder
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+		if ch == '\\' && l.pos+1 < len(l.input) {
+			text.WriteByte(l.input[l.pos+1])
+			l.pos += 2
+			continue
+		}
+		if ch == '"' {
+			l.pos++
+			return text.String(), nil
+		}
+		text.WriteByte(ch)
+		l.pos++
+	}
+
+	return "", &QueryError{Query: l.input, Pos: start, Message: "unterminated quoted string"}
+}
+
+// scanBare reads a bare value up to whitespace or an unbalanced ')'.
+// Balanced parentheses stay part of the value. Escapes are removed when
+// unescapeValue is set and kept otherwise (regex source).
+func (l *lexer) scanBare(unescapeValue bool) string {
+	start := l.pos
+	depth := 0
+
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+		if ch == '\\' && l.pos+1 < len(l.input) {
+			l.pos += 2
+			continue
+		}
+		if isWhitespace(ch) {
+			break
+		}
+		if ch == '(' {
+			depth++
+		} else if ch == ')' {
+			if depth == 0 {
+				break
+			}
+			depth--
+		}
+		l.pos++
+	}
+
+	raw := l.input[start:l.pos]
+	if unescapeValue {
+		return unescape(raw)
+	}
+	return raw
+}
+
+// scanEnclosed reads a balanced (...) or [...] group and returns its inner
+// text, respecting quoted strings inside the group
+func (l *lexer) scanEnclosed(open, close byte) (string, error) {
+	start := l.pos
+	depth := 0
+	inQuotes := false
+
+	for l.pos < len(l.input) {
+		ch := l.input[l.pos]
+		switch {
+		case ch == '\\' && l.pos+1 < len(l.input):
+			l.pos += 2
+			continue
+		case ch == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case ch == open:
+			depth++
+		case ch == close:
+			depth--
+			if depth == 0 {
+				l.pos++
+				return l.input[start+1 : l.pos-1], nil
+			}
+		}
+		l.pos++
+	}
+
+	return "", &QueryError{Query: l.input, Pos: start, Message: "missing '" + string(close) + "'"}
+}
+
+// scanFieldValue reads the operator and value that follow a field token
+func (l *lexer) scanFieldValue() (fieldValue, error) {
+	if l.peeked != nil {
+		// Field values are only requested directly after a field token
+		l.pos = l.peeked.pos
+		l.peeked = nil
+	}
+
+	var fv fieldValue
+	for _, op := range []string{"!=", ">=", "<=", "!", "~", ">", "<", "="} {
+		if strings.HasPrefix(l.input[l.pos:], op) {
+			fv.op = op
+			l.pos += len(op)
+			break
+		}
+	}
+
+	fv.pos = l.pos
+	if l.pos >= len(l.input) || isWhitespace(l.input[l.pos]) || l.input[l.pos] == ')' {
+		return fv, &QueryError{Query: l.input, Pos: l.pos, Message: "missing value"}
+	}
+
+	// IN(a,b,c) is an alias for the (a|b|c) value list
+	if strings.HasPrefix(l.input[l.pos:], "IN(") {
+		l.pos += len("IN")
+	}
+
+	switch l.input[l.pos] {
+	case '"':
+		text, err := l.scanQuoted()
+		if err != nil {
+			return fv, err
+		}
+		fv.kind = valueQuoted
+		fv.text = text
+
+	case '(':
+		text, err := l.scanEnclosed('(', ')')
+		if err != nil {
+			return fv, err
+		}
+		fv.kind = valueGroup
+		fv.text = text
+
+	case '[':
+		text, err := l.scanEnclosed('[', ']')
+		if err != nil {
+			return fv, err
+		}
+		fv.kind = valueRange
+		fv.text = text
+
+	default:
+		fv.kind = valueBare
+		fv.text = l.scanBare(fv.op != "~")
+	}
+
+	return fv, nil
+}
+
+// unescape removes backslash escapes from a bare word
+func unescape(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
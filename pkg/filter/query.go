@@ -0,0 +1,547 @@
+package filter
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The query language, from lowest to highest precedence:
+//
+//	query   = or
+//	or      = and { ("OR" | "||") and }
+//	and     = unary { ["AND" | "&&"] unary }      adjacent terms are ANDed
+//	unary   = ("NOT" | "!") unary | primary
+//	primary = "(" or ")" | field | text
+//	field   = name ":" [op] value
+//	op      = "=" | "!=" | "!" | "~" | ">" | ">=" | "<" | "<="
+//	value   = word | "quoted" | ["IN"] "(" alt { ("|" | "," | "OR") alt } ")" | "[" lower "TO" upper "]"
+//	text    = word | "quoted" | "~" word | "~" "quoted"
+//
+// A lone * matches every line. Keywords are upper case; a lower-case "and" is an ordinary word. Bare
+// words match case-insensitively as substrings, quoted phrases match
+// case-sensitively, and "~" introduces a case-insensitive regex. A bare
+// field value containing * or ? is an anchored wildcard, a lone * tests
+// that the field exists (as does _exists_:field), an address prefix such
+// as 10.0.0.0/8 or fd00::/8 matches IPs in that network, "=" forces an
+// exact match, and "~" a regex. A backslash escapes the next character in
+// bare words and quoted strings. Field names use the path syntax from
+// parser.ParsePath and may be quoted: "http.status_code":500.
+
+// QueryError reports a syntax error and the position it occurred at
+type QueryError struct {
+	Query   string
+	Pos     int // byte offset into Query
+	Message string
+}
+
+// Error formats the error with a 1-based column
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s at column %d", e.Message, e.Pos+1)
+}
+
+// queryParser is a recursive descent parser over the query lexer
+type queryParser struct {
+	lex           *lexer
+	caseSensitive bool
+}
+
+// ParseQuery parses a query string into an expression tree. An empty
+// query yields a nil expression.
+func ParseQuery(query string) (QueryExpression, error) {
+	return parseQuery(query, false)
+}
+
+// parseQuery parses a query; caseSensitive applies to bare text terms
+func parseQuery(query string, caseSensitive bool) (QueryExpression, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+
+	p := &queryParser{lex: newLexer(query), caseSensitive: caseSensitive}
+
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := p.lex.next()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind != tokEOF {
+		return nil, p.errorf(tok.pos, "unexpected %s", describe(tok))
+	}
+
+	return expr, nil
+}
+
+// errorf creates a positioned query error
+func (p *queryParser) errorf(pos int, format string, args ...interface{}) error {
+	return &QueryError{Query: p.lex.input, Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// parseOr parses OR-separated expressions
+func (p *queryParser) parseOr() (QueryExpression, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, err := p.lex.peek()
+		if err != nil {
+			return nil, err
+		}
+		if tok.kind != tokOr {
+			return left, nil
+		}
+		p.lex.next()
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &OrExpression{Left: left, Right: right}
+	}
+}
+
+// parseAnd parses explicit and implicit AND sequences
+func (p *queryParser) parseAnd() (QueryExpression, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok, err := p.lex.peek()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok.kind {
+		case tokAnd:
+			p.lex.next()
+		case tokWord, tokString, tokRegex, tokField, tokLParen, tokNot:
+			// Implicit AND between adjacent terms
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &AndExpression{Left: left, Right: right}
+	}
+}
+
+// parseUnary parses NOT prefixes
+func (p *queryParser) parseUnary() (QueryExpression, error) {
+	tok, err := p.lex.peek()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok.kind == tokNot {
+		p.lex.next()
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpression{Expression: expr}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+// parsePrimary parses groups, field terms and text terms
+func (p *queryParser) parsePrimary() (QueryExpression, error) {
+	tok, err := p.lex.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok.kind {
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		closing, err := p.lex.next()
+		if err != nil {
+			return nil, err
+		}
+		if closing.kind != tokRParen {
+			return nil, p.errorf(closing.pos, "missing ')' for '(' at column %d", tok.pos+1)
+		}
+		return expr, nil
+
+	case tokField:
+		return p.parseField(tok)
+
+	case tokWord:
+		if tok.text == "*" {
+			return &MatchAllExpression{}, nil
+		}
+		if day, ok := dayRange(tok.text); ok {
+			return day, nil
+		}
+		return &TextExpression{Text: tok.text, CaseSensitive: p.caseSensitive}, nil
+
+	case tokString:
+		return &TextExpression{Text: tok.text, CaseSensitive: true}, nil
+
+	case tokRegex:
+		pattern, err := p.compileRegex(tok.text, tok.pos)
+		if err != nil {
+			return nil, err
+		}
+		return &TextExpression{Text: tok.text, IsRegex: true, Pattern: pattern}, nil
+
+	case tokEOF:
+		return nil, p.errorf(tok.pos, "unexpected end of query")
+
+	default:
+		return nil, p.errorf(tok.pos, "unexpected %s", describe(tok))
+	}
+}
+
+// parseField parses the operator and value of a field term
+func (p *queryParser) parseField(tok token) (QueryExpression, error) {
+	field := tok.text
+	if field == "" {
+		return nil, p.errorf(tok.pos, "missing field name")
+	}
+
+	fv, err := p.lex.scanFieldValue()
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(field) {
+	case "since", "until":
+		return p.parseTimeLimit(field, fv)
+	case "_exists_":
+		if fv.op != "" || fv.kind == valueRange || fv.kind == valueGroup {
+			return nil, p.errorf(fv.pos, "_exists_ takes a field name")
+		}
+		return &ExistsExpression{Field: fv.text}, nil
+	}
+	if isTimeField(field) && fv.kind != valueRange && fv.kind != valueGroup {
+		if expr, err := p.parseTimeComparison(fv); expr != nil || err != nil {
+			return expr, err
+		}
+	}
+
+	switch fv.kind {
+	case valueRange:
+		if fv.op != "" {
+			return nil, p.errorf(fv.pos, "operator %q cannot be used with a range", fv.op)
+		}
+		return p.parseRange(field, fv)
+
+	case valueGroup:
+		if fv.op == "~" {
+			return p.fieldTerm(field, fv.op, "("+fv.text+")", valueBare, fv.pos)
+		}
+		switch fv.op {
+		case "", "=", "!", "!=":
+		default:
+			return nil, p.errorf(fv.pos, "operator %q cannot be used with a value list", fv.op)
+		}
+		return p.parseAlternatives(field, fv)
+	}
+
+	return p.fieldTerm(field, fv.op, fv.text, fv.kind, fv.pos)
+}
+
+// fieldTerm builds a single field expression
+func (p *queryParser) fieldTerm(field, op, value string, kind valueKind, pos int) (QueryExpression, error) {
+	if kind == valueBare && value == "*" {
+		switch op {
+		case "":
+			return &ExistsExpression{Field: field}, nil
+		case "!", "!=":
+			return &NotExpression{Expression: &ExistsExpression{Field: field}}, nil
+		}
+	}
+
+	expr := &FieldExpression{Field: field, Value: value}
+
+	switch op {
+	case "":
+		expr.Operator = ":"
+	case "!", "!=":
+		expr.Operator = ":!="
+	default:
+		expr.Operator = ":" + op
+	}
+
+	switch {
+	case op == "~":
+		pattern, err := p.compileRegex(value, pos)
+		if err != nil {
+			return nil, err
+		}
+		expr.Pattern = pattern
+
+	case kind == valueBare && (expr.Operator == ":" || expr.Operator == ":!="):
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefix = prefix.Masked()
+			expr.Network = &prefix
+		} else if strings.ContainsAny(value, "*?") {
+			expr.Pattern = compileWildcard(value)
+		}
+	}
+
+	return expr, nil
+}
+
+// parseAlternatives expands field:(a|b|c) into an OR of field terms; a
+// negated list must match none of the values
+func (p *queryParser) parseAlternatives(field string, fv fieldValue) (QueryExpression, error) {
+	values := splitAlternatives(fv.text)
+	if len(values) == 0 {
+		return nil, p.errorf(fv.pos, "empty value list")
+	}
+
+	op := fv.op
+	negate := op == "!" || op == "!="
+	if negate {
+		op = ""
+	}
+
+	var expr QueryExpression
+	for _, alt := range values {
+		kind := valueBare
+		var text string
+		if len(alt) >= 2 && alt[0] == '"' && alt[len(alt)-1] == '"' {
+			kind = valueQuoted
+			text = unescape(alt[1 : len(alt)-1])
+		} else {
+			text = unescape(alt)
+		}
+
+		term, err := p.fieldTerm(field, op, text, kind, fv.pos)
+		if err != nil {
+			return nil, err
+		}
+		if expr == nil {
+			expr = term
+		} else {
+			expr = &OrExpression{Left: expr, Right: term}
+		}
+	}
+
+	if negate {
+		return &NotExpression{Expression: expr}, nil
+	}
+	return expr, nil
+}
+
+// parseRange parses field:[lower TO upper]; time fields become a
+// TimeRangeExpression, other fields an inclusive comparison pair
+func (p *queryParser) parseRange(field string, fv fieldValue) (QueryExpression, error) {
+	bounds := strings.SplitN(fv.text, " TO ", 2)
+	if len(bounds) != 2 {
+		return nil, p.errorf(fv.pos, "range must have the form [lower TO upper]")
+	}
+	lower := strings.Trim(strings.TrimSpace(bounds[0]), "\"")
+	upper := strings.Trim(strings.TrimSpace(bounds[1]), "\"")
+	if lower == "" || upper == "" {
+		return nil, p.errorf(fv.pos, "range must have the form [lower TO upper]")
+	}
+
+	if isTimeField(field) {
+		start, err := parseTimeBound(lower)
+		if err != nil {
+			return nil, p.errorf(fv.pos, "invalid start time: %v", err)
+		}
+		end, err := parseTimeBound(upper)
+		if err != nil {
+			return nil, p.errorf(fv.pos, "invalid end time: %v", err)
+		}
+		return &TimeRangeExpression{Start: &start, End: &end}, nil
+	}
+
+	return &AndExpression{
+		Left:  &FieldExpression{Field: field, Operator: ":>=", Value: lower},
+		Right: &FieldExpression{Field: field, Operator: ":<=", Value: upper},
+	}, nil
+}
+
+// parseTimeLimit parses since:<time> and until:<time>
+func (p *queryParser) parseTimeLimit(field string, fv fieldValue) (QueryExpression, error) {
+	if fv.op != "" || fv.kind == valueRange || fv.kind == valueGroup {
+		return nil, p.errorf(fv.pos, "%s takes a single time", strings.ToLower(field))
+	}
+
+	bound, err := parseTimeBound(fv.text)
+	if err != nil {
+		return nil, p.errorf(fv.pos, "%v", err)
+	}
+
+	label := strings.ToLower(field) + ":" + quoteValue(fv.text)
+	if strings.EqualFold(field, "since") {
+		return &TimeRangeExpression{Start: &bound, Label: label}, nil
+	}
+	return &TimeRangeExpression{End: &bound, Label: label}, nil
+}
+
+// parseTimeComparison turns time:>-15m, time:<=now-1h, time:@today and
+// time:2024-01-15 into time ranges. It returns nil for values it leaves to
+// plain field matching.
+func (p *queryParser) parseTimeComparison(fv fieldValue) (QueryExpression, error) {
+	switch fv.op {
+	case ">", ">=", "<", "<=":
+		bound, err := parseTimeBound(fv.text)
+		if err != nil {
+			return nil, p.errorf(fv.pos, "%v", err)
+		}
+		if strings.HasPrefix(fv.op, ">") {
+			return &TimeRangeExpression{Start: &bound, ExclusiveStart: fv.op == ">"}, nil
+		}
+		return &TimeRangeExpression{End: &bound, ExclusiveEnd: fv.op == "<"}, nil
+
+	case "":
+		if day, ok := dayRange(fv.text); ok {
+			return day, nil
+		}
+		if t, err := time.ParseInLocation("2006-01-02", fv.text, time.Local); err == nil {
+			start := TimeBound{Absolute: t, Raw: fv.text}
+			end := TimeBound{Absolute: t.AddDate(0, 0, 1)}
+			return &TimeRangeExpression{Start: &start, End: &end, ExclusiveEnd: true, Label: "time:" + fv.text}, nil
+		}
+	}
+	return nil, nil
+}
+
+// dayRange expands @today and @yesterday into calendar day ranges
+func dayRange(word string) (QueryExpression, bool) {
+	var anchor TimeAnchor
+	switch strings.ToLower(word) {
+	case "@today":
+		anchor = AnchorToday
+	case "@yesterday":
+		anchor = AnchorYesterday
+	default:
+		return nil, false
+	}
+
+	start := TimeBound{Anchor: anchor}
+	end := TimeBound{Anchor: AnchorToday}
+	if anchor == AnchorToday {
+		end.Offset = 24 * time.Hour
+	}
+	return &TimeRangeExpression{Start: &start, End: &end, ExclusiveEnd: true, Label: strings.ToLower(word)}, true
+}
+
+// compileRegex compiles a case-insensitive regex, reporting errors at pos
+func (p *queryParser) compileRegex(source string, pos int) (*regexp.Regexp, error) {
+	pattern, err := regexp.Compile("(?i)" + source)
+	if err != nil {
+		return nil, p.errorf(pos, "invalid regex: %v", err)
+	}
+	return pattern, nil
+}
+
+// compileWildcard turns a * / ? glob into an anchored case-insensitive regex
+func compileWildcard(glob string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// splitAlternatives splits a value list on '|', ',' or " OR ", keeping
+// quoted values intact
+func splitAlternatives(s string) []string {
+	var values []string
+	var current strings.Builder
+	inQuotes := false
+
+	flush := func() {
+		if v := strings.TrimSpace(current.String()); v != "" {
+			values = append(values, v)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\' && i+1 < len(s):
+			current.WriteByte(ch)
+			current.WriteByte(s[i+1])
+			i++
+		case ch == '"':
+			inQuotes = !inQuotes
+			current.WriteByte(ch)
+		case inQuotes:
+			current.WriteByte(ch)
+		case ch == '|' || ch == ',':
+			flush()
+		case strings.HasPrefix(s[i:], " OR "):
+			flush()
+			i += 3
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	flush()
+
+	return values
+}
+
+// isTimeField reports whether a field name refers to the line timestamp
+func isTimeField(field string) bool {
+	switch strings.ToLower(field) {
+	case "time", "timestamp", "ts", "@timestamp":
+		return true
+	}
+	return false
+}
+
+// describe names a token for error messages
+func describe(tok token) string {
+	switch tok.kind {
+	case tokWord, tokString, tokRegex, tokField:
+		return fmt.Sprintf("%s %q", tok.kind, tok.text)
+	default:
+		return tok.kind.String()
+	}
+}
+
+// FieldTerm returns the query term matching a field exactly equal to value
+func FieldTerm(field, value string) string {
+	return field + ":=" + quoteValue(value)
+}
+
+// quoteValue quotes a value when it would not survive as a bare word: the
+// lexer ends a bare value at whitespace or ')', and reads one starting with
+// '"', '(' or '[' as a quoted string, value list or range
+func quoteValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n\r\"()\\") && value[0] != '[' {
+		return value
+	}
+	return quoteString(value)
+}
+
+// quoteString quotes a value using the query escaping rules
+func quoteString(value string) string {
+	escaped := strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(value)
+	return "\"" + escaped + "\""
+}
@@ -0,0 +1,359 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+func newTestEngine(t *testing.T, query string) (*FilterEngine, *parser.LogParser) {
+	t.Helper()
+
+	p := parser.New()
+	f := New(p)
+	if err := f.SetFilter(models.FilterOptions{Query: query}); err != nil {
+		t.Fatalf("SetFilter(%q) failed: %v", query, err)
+	}
+	return f, p
+}
+
+func TestQueryPrecedence(t *testing.T) {
+	expr, err := ParseQuery(`a OR b c AND NOT d`)
+	if err != nil {
+		t.Fatalf("ParseQuery failed: %v", err)
+	}
+
+	want := `(a OR ((b AND c) AND NOT d))`
+	if got := expr.String(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestQueryMatching(t *testing.T) {
+	lines := map[string]string{
+		"error": `{"level":"ERROR","status":503,"path":"/api/v1/orders","msg":"upstream timeout"}`,
+		"warn":  `{"level":"WARN","status":404,"path":"/health","msg":"not found"}`,
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`level:ERROR`, []string{"error"}},
+		{`level:(ERROR|WARN)`, []string{"error", "warn"}},
+		{`level:!(ERROR, WARN)`, nil},
+		{`status:>=500`, []string{"error"}},
+		{`status:[400 TO 499]`, []string{"warn"}},
+		{`path:/api/*`, []string{"error"}},
+		{`path:=/api/*`, nil},
+		{`msg:~"time(out|d)"`, []string{"error"}},
+		{`TIMEOUT`, []string{"error"}},
+		{`"TIMEOUT"`, nil},
+		{`(level:WARN OR status:>500) !found`, []string{"error"}},
+		{`"upstream timeout" && level:error`, []string{"error"}},
+	}
+
+	for _, tt := range tests {
+		f, p := newTestEngine(t, tt.query)
+		var got []string
+		for _, name := range []string{"error", "warn"} {
+			line := &models.LogLine{Raw: lines[name]}
+			p.ParseLogLine(line)
+			if f.Match(line) {
+				got = append(got, name)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestQueryErrorPositions(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`level:ERROR AND`, 15},
+		{`(level:ERROR`, 12},
+		{`msg:"unterminated`, 4},
+		{`level: x`, 6},
+		{`msg:~"(" OR a`, 5},
+		{`a ) b`, 2},
+		{`OR a`, 0},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseQuery(tt.query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("%s: expected QueryError, got %v", tt.query, err)
+			continue
+		}
+		if queryErr.Pos != tt.pos {
+			t.Errorf("%s: expected error at %d, got %d (%v)", tt.query, tt.pos, queryErr.Pos, err)
+		}
+	}
+}
+
+func TestRelativeTimeQueries(t *testing.T) {
+	fixed := time.Date(2024, 3, 10, 0, 30, 0, 0, time.Local)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		query string
+		at    time.Time
+		want  bool
+	}{
+		{`time:>-15m`, fixed.Add(-10 * time.Minute), true},
+		{`time:>-15m`, fixed.Add(-20 * time.Minute), false},
+		{`time:[now-2h TO now-1h]`, fixed.Add(-90 * time.Minute), true},
+		{`time:[now-2h TO now-1h]`, fixed.Add(-30 * time.Minute), false},
+		{`@today`, fixed.Add(-20 * time.Minute), true},
+		{`@today`, fixed.Add(-40 * time.Minute), false},
+		{`@yesterday`, fixed.Add(-40 * time.Minute), true},
+		{`since:-1h until:-5m`, fixed.Add(-10 * time.Minute), true},
+		{`since:-1h until:-5m`, fixed.Add(-1 * time.Minute), false},
+		{`time:2024-03-09`, fixed.Add(-time.Hour), true},
+	}
+
+	for _, tt := range tests {
+		f, _ := newTestEngine(t, tt.query)
+		line := &models.LogLine{Raw: "x", Timestamp: tt.at}
+		if got := f.Match(line); got != tt.want {
+			t.Errorf("%s at %s: expected %v, got %v", tt.query, tt.at.Format(time.RFC3339), tt.want, got)
+		}
+		if !f.IsRelative() && tt.query != `time:2024-03-09` {
+			t.Errorf("%s: expected a relative filter", tt.query)
+		}
+	}
+}
+
+func TestUnitComparisons(t *testing.T) {
+	tests := []struct {
+		query string
+		raw   string
+		want  bool
+	}{
+		{`latency:>250ms`, `{"latency":"812ms"}`, true},
+		{`latency:>250ms`, `{"latency":"0.1s"}`, false},
+		{`latency:>250ms`, `{"latency":812}`, true},
+		{`duration:>=1.5s`, `{"duration":"1500ms"}`, true},
+		{`duration:>=1.5s`, `{"duration":"1m2s"}`, true},
+		{`size:>10MB`, `{"size":"512KB"}`, false},
+		{`size:>10MB`, `{"size":"1.5GiB"}`, true},
+		{`size:>10MB`, `{"size":"20s"}`, false},
+		{`status:>=500`, `{"status":503}`, true},
+		{`x:>=5`, `{"x":"nan"}`, false},
+		{`x:>=5`, `{"x":"NaN"}`, false},
+		{`x:<5`, `{"x":"-Inf"}`, false},
+		{`x:>=5`, `{"x":"infinity"}`, false},
+	}
+
+	for _, tt := range tests {
+		f, p := newTestEngine(t, tt.query)
+		line := &models.LogLine{Raw: tt.raw}
+		p.ParseLogLine(line)
+		if got := f.Match(line); got != tt.want {
+			t.Errorf("%s against %s: expected %v, got %v", tt.query, tt.raw, tt.want, got)
+		}
+	}
+}
+
+func TestNetworkListAndExistsOperators(t *testing.T) {
+	tests := []struct {
+		query string
+		raw   string
+		want  bool
+	}{
+		{`ip:10.0.0.0/8`, `{"ip":"10.20.30.40"}`, true},
+		{`ip:10.0.0.0/8`, `{"ip":"11.0.0.1"}`, false},
+		{`ip:10.0.0.0/8`, `{"ip":"10.1.2.3:8443"}`, true},
+		{`ip:!192.168.0.0/16`, `{"ip":"192.168.4.2"}`, false},
+		{`ip:!192.168.0.0/16`, `{"ip":"172.16.0.1"}`, true},
+		{`ip:2001:db8::/32`, `{"ip":"2001:db8:0:1::7"}`, true},
+		{`ip:2001:db8::/32`, `{"ip":"::ffff:10.0.0.1"}`, false},
+		{`status:IN(500,502,503)`, `{"status":502}`, true},
+		{`status:IN(500,502,503)`, `{"status":504}`, false},
+		{`status:!IN(500, 502)`, `{"status":200}`, true},
+		{`user_id:*`, `{"user_id":"u-1"}`, true},
+		{`user_id:*`, `{"other":1}`, false},
+		{`_exists_:user_id`, `{"user_id":7}`, true},
+		{`user_id:!*`, `{"other":1}`, true},
+		{`path:/api/*/orders`, `{"path":"/api/v2/orders"}`, true},
+		{`path:/api/*/orders`, `{"path":"/api/v2/orders/7"}`, false},
+		{`path:/api/v?/orders`, `{"path":"/api/v2/orders"}`, true},
+	}
+
+	for _, tt := range tests {
+		f, p := newTestEngine(t, tt.query)
+		line := &models.LogLine{Raw: tt.raw}
+		p.ParseLogLine(line)
+		if got := f.Match(line); got != tt.want {
+			t.Errorf("%s against %s: expected %v, got %v", tt.query, tt.raw, tt.want, got)
+		}
+	}
+}
+
+func TestFieldTerm(t *testing.T) {
+	for _, value := range []string{"ERROR", "[1]", "[1 TO 5]", "(a|b)", "IN(1,2)", "a b", `say "hi"`, `C:\\tmp`, "*", "=5", "", "tab\there"} {
+		query := FieldTerm("msg", value)
+		f, p := newTestEngine(t, query)
+		line := &models.LogLine{Raw: fmt.Sprintf(`{"msg":%q}`, value)}
+		p.ParseLogLine(line)
+		if !f.Match(line) {
+			t.Errorf("%s does not match %q", query, value)
+		}
+
+		other := &models.LogLine{Raw: `{"msg":"other"}`}
+		p.ParseLogLine(other)
+		if f.Match(other) {
+			t.Errorf("%s matches %q", query, "other")
+		}
+	}
+}
+
+func TestSequenceAndMissing(t *testing.T) {
+	raws := []string{
+		`{"timestamp":"2024-01-15T10:00:00Z","order_id":"1","event":"authorized"}`,
+		`{"timestamp":"2024-01-15T10:00:10Z","order_id":"2","event":"authorized"}`,
+		`{"timestamp":"2024-01-15T10:01:00Z","order_id":"1","event":"captured"}`,
+		`{"timestamp":"2024-01-15T10:03:00Z","order_id":"3","event":"authorized"}`,
+		`{"timestamp":"2024-01-15T10:06:00Z","order_id":"2","event":"captured"}`,
+	}
+
+	feed := func(query string) []string {
+		f, p := newTestEngine(t, query)
+		var ids []string
+		collect := func(lines []*models.LogLine) {
+			for _, line := range lines {
+				ids = append(ids, f.FieldValue(line, "order_id")+":"+f.FieldValue(line, "event"))
+			}
+		}
+		for _, raw := range raws {
+			line := &models.LogLine{Raw: raw}
+			p.ParseLogLine(line)
+			collect(f.Feed(line))
+		}
+		collect(f.Expire(time.Date(2024, 1, 15, 11, 0, 0, 0, time.UTC)))
+		return ids
+	}
+
+	got := fmt.Sprint(feed(`sequence by order_id [event:authorized] [event:captured] within 5m`))
+	if want := "[1:authorized 1:captured]"; got != want {
+		t.Errorf("sequence: expected %s, got %s", want, got)
+	}
+
+	got = fmt.Sprint(feed(`missing by order_id [event:authorized] [event:captured] within 5m`))
+	if want := "[2:authorized 3:authorized]"; got != want {
+		t.Errorf("missing: expected %s, got %s", want, got)
+	}
+
+	f := New(parser.New())
+	err := f.SetFilter(models.FilterOptions{Query: `missing by order_id [event:authorized] [event:]`})
+	var queryErr *QueryError
+	if !errors.As(err, &queryErr) || queryErr.Pos != 46 {
+		t.Errorf("Expected error at 46, got %v", err)
+	}
+	if err := f.SetFilter(models.FilterOptions{Query: `missing by order_id [event:authorized]`}); err == nil {
+		t.Errorf("Expected an error for a sequence with one step")
+	}
+
+	// Without [steps] after the fields the words are searched as text
+	for _, query := range []string{`missing by order_id`, `sequence by the way`, `Missing by 2 days`} {
+		text, p := newTestEngine(t, query)
+		line := &models.LogLine{Raw: "2024-01-15 10:00:00 WARN " + query}
+		p.ParseLogLine(line)
+		if text.IsStateful() || !text.Match(line) {
+			t.Errorf("Expected %q to be a text search", query)
+		}
+	}
+}
+
+// allLines is a planner that can narrow field terms only
+type allLines []uint32
+
+func (a allLines) Candidates(expr QueryExpression) ([]uint32, bool) {
+	_, ok := expr.(*FieldExpression)
+	return a, ok
+}
+
+func TestExplain(t *testing.T) {
+	p := parser.New()
+	var lines []*models.LogLine
+	for _, raw := range []string{
+		`{"level":"ERROR","status":503,"msg":"upstream timeout"}`,
+		`{"level":"WARN","status":404,"msg":"not found"}`,
+	} {
+		line := &models.LogLine{Raw: raw}
+		p.ParseLogLine(line)
+		lines = append(lines, line)
+	}
+
+	f := New(p)
+	ex, err := f.Explain(models.FilterOptions{Query: `status:>=400 AND NOT timeout OR latency:>5`}, lines, allLines{0, 1})
+	if err != nil {
+		t.Fatalf("Explain failed: %v", err)
+	}
+	if f.HasFilter() {
+		t.Errorf("Explain must not install the query")
+	}
+
+	root := ex.Root
+	if root.Label != "OR" || root.Strategy != "scan" || root.Matches != 1 || len(root.Children) != 2 {
+		t.Fatalf("Unexpected root %+v", root)
+	}
+	and := root.Children[0]
+	if and.Label != "AND" || and.Strategy != "scan" || and.Children[0].Strategy != "index" || and.Children[0].Matches != 2 || and.Children[1].Strategy != "scan" {
+		t.Errorf("Unexpected AND node %+v", and)
+	}
+	want := "[field `latency` not present in any parsed line the index cannot narrow this query; every line is scanned]"
+	if fmt.Sprint(ex.Warnings) != want {
+		t.Errorf("Unexpected warnings %v", ex.Warnings)
+	}
+	if !strings.Contains(ex.String(), "└─ latency:>5") {
+		t.Errorf("Expected the tree in the report, got:\n%s", ex)
+	}
+
+	if _, err := f.Explain(models.FilterOptions{}, lines, nil); err == nil {
+		t.Errorf("Expected an error explaining an empty query")
+	}
+}
+
+func TestMatchSpans(t *testing.T) {
+	raw := `{"level":"ERROR","status":503,"path":"/api/v1/orders","msg":"upstream timeout, timeout again"}`
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`timeout`, []string{"timeout", "timeout"}},
+		{`~"time(out)? again"`, []string{"timeout again"}},
+		{`status:>500 upstream`, []string{"503", "upstream"}},
+		{`path:/api/* OR missing`, []string{"/api/v1/orders"}},
+		{`level:error NOT orders`, nil},
+		{`level:ERROR NOT health`, []string{"ERROR"}},
+		{`msg:~"up[a-z]+"`, []string{"upstream"}},
+		{`"upstream timeout" timeout`, []string{"upstream timeout", "timeout"}},
+	}
+
+	for _, tt := range tests {
+		f, p := newTestEngine(t, tt.query)
+		line := &models.LogLine{Raw: raw}
+		p.ParseLogLine(line)
+
+		var got []string
+		for _, span := range f.MatchSpans(line) {
+			got = append(got, raw[span.Start:span.End])
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: expected spans %q, got %q", tt.query, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,291 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// maxPendingSequences bounds how many keys a sequence matcher tracks at
+// once; the oldest unfinished sequence is dropped beyond it
+const maxPendingSequences = 100000
+
+// SequenceMatcher finds lines that follow each other per key, e.g.
+//
+//	sequence by order_id [event:authorized] [event:captured] within 5m
+//	missing by order_id [event:authorized] [event:captured] within 5m
+//
+// Unlike a QueryExpression it keeps state between lines: a sequence
+// yields its lines once the last step arrives, and missing yields the
+// lines of a sequence whose window ran out before it completed.
+type SequenceMatcher struct {
+	Missing bool              // Report unfinished sequences instead of finished ones
+	By      []string          // Fields that key a sequence
+	Steps   []QueryExpression // Expressions each line of the sequence must match, in order
+	Within  time.Duration     // Window from the first step; zero means no limit
+
+	pending map[string]*pendingSequence
+	queue   []*pendingSequence // In start order, for expiry
+}
+
+// pendingSequence is a sequence that has matched some of its steps
+type pendingSequence struct {
+	key   string
+	start time.Time
+	step  int // Next step to match
+	lines []*models.LogLine
+	done  bool // Finished or expired; left in the queue until it reaches the front
+}
+
+// sequencePrefix matches the start of a sequence query up to its first
+// step: the keyword, "by", and the field list
+var sequencePrefix = regexp.MustCompile(`(?i)^\s*(sequence|missing)\s+by\s+[^\s,\[]+(,\s*[^\s,\[]+)*\s*\[`)
+
+// isSequenceQuery reports whether a query starts with "sequence by" or
+// "missing by" and a field list followed by a [step]; anything else, such
+// as a search for the text "missing by design", is an ordinary search
+func isSequenceQuery(query string) bool {
+	return sequencePrefix.MatchString(query)
+}
+
+// parseSequence parses:
+//
+//	("sequence" | "missing") "by" field {, field} "[" query "]" "[" query "]" {"[" query "]"} ["within" duration]
+func parseSequence(query string, caseSensitive bool) (*SequenceMatcher, error) {
+	pos := 0
+	errorf := func(at int, format string, args ...interface{}) error {
+		return &QueryError{Query: query, Pos: at, Message: fmt.Sprintf(format, args...)}
+	}
+	word := func() (string, int) {
+		for pos < len(query) && (query[pos] == ' ' || query[pos] == '\t') {
+			pos++
+		}
+		start := pos
+		for pos < len(query) && !strings.ContainsRune(" \t,[", rune(query[pos])) {
+			pos++
+		}
+		return query[start:pos], start
+	}
+
+	keyword, _ := word()
+	s := &SequenceMatcher{Missing: strings.EqualFold(keyword, "missing")}
+
+	if by, at := word(); !strings.EqualFold(by, "by") {
+		return nil, errorf(at, "expected 'by' after %s", keyword)
+	}
+	for {
+		field, at := word()
+		if field == "" {
+			return nil, errorf(at, "expected a field after 'by'")
+		}
+		s.By = append(s.By, field)
+		if pos < len(query) && query[pos] == ',' {
+			pos++
+			continue
+		}
+		break
+	}
+
+	for {
+		for pos < len(query) && (query[pos] == ' ' || query[pos] == '\t') {
+			pos++
+		}
+		if pos >= len(query) || query[pos] != '[' {
+			break
+		}
+		end := closingBracket(query, pos)
+		if end < 0 {
+			return nil, errorf(pos, "missing ']'")
+		}
+
+		step, err := parseQuery(query[pos+1:end], caseSensitive)
+		var queryErr *QueryError
+		if errors.As(err, &queryErr) {
+			return nil, errorf(pos+1+queryErr.Pos, "%s", queryErr.Message)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if step == nil {
+			return nil, errorf(pos, "empty step")
+		}
+		s.Steps = append(s.Steps, step)
+		pos = end + 1
+	}
+	if len(s.Steps) < 2 {
+		return nil, errorf(pos, "%s needs at least two [steps]", keyword)
+	}
+
+	if next, at := word(); strings.EqualFold(next, "within") {
+		text, at := word()
+		within, err := parseDuration(text)
+		if err != nil || within <= 0 {
+			return nil, errorf(at, "expected a duration such as 5m after 'within'")
+		}
+		s.Within = within
+	} else if next != "" {
+		return nil, errorf(at, "unexpected %q", next)
+	}
+	if rest, at := word(); rest != "" {
+		return nil, errorf(at, "unexpected %q", rest)
+	}
+
+	if s.Missing && s.Within == 0 {
+		return nil, errorf(len(query), "missing needs a 'within' window")
+	}
+
+	s.Reset()
+	return s, nil
+}
+
+// closingBracket finds the ']' closing the '[' at open, skipping quoted
+// strings and nested brackets such as ranges and array indexes
+func closingBracket(query string, open int) int {
+	depth := 0
+	inQuotes := false
+	for i := open; i < len(query); i++ {
+		switch ch := query[i]; {
+		case ch == '\\':
+			i++
+		case ch == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Reset forgets every pending sequence
+func (s *SequenceMatcher) Reset() {
+	s.pending = make(map[string]*pendingSequence)
+	s.queue = nil
+}
+
+// MatchesStep reports whether a line matches any step, without state
+func (s *SequenceMatcher) MatchesStep(line *models.LogLine, f *FilterEngine) bool {
+	for _, step := range s.Steps {
+		if step.Evaluate(line, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// Feed advances the sequences with the next line. It returns the lines of
+// sequences that finished (sequence) or ran out of time (missing).
+func (s *SequenceMatcher) Feed(line *models.LogLine, f *FilterEngine) []*models.LogLine {
+	at := line.Timestamp
+	if at.IsZero() {
+		at = now()
+	}
+	out := s.Expire(at)
+
+	key := make([]string, len(s.By))
+	present := false
+	for i, field := range s.By {
+		key[i] = f.FieldValue(line, field)
+		present = present || key[i] != ""
+	}
+	if !present {
+		return out
+	}
+	id := strings.Join(key, "\x00")
+
+	// A sequence whose window closed out of order is ended here instead
+	if p, ok := s.pending[id]; ok && s.Within > 0 && at.After(p.start.Add(s.Within)) {
+		p.done = true
+		delete(s.pending, id)
+		if s.Missing {
+			out = append(out, p.lines...)
+		}
+	}
+
+	if p, ok := s.pending[id]; ok {
+		if s.Steps[p.step].Evaluate(line, f) {
+			p.lines = append(p.lines, line)
+			p.step++
+			if p.step == len(s.Steps) {
+				p.done = true
+				delete(s.pending, id)
+				if !s.Missing {
+					out = append(out, p.lines...)
+				}
+			}
+		}
+		return out
+	}
+
+	if s.Steps[0].Evaluate(line, f) {
+		p := &pendingSequence{key: id, start: at, step: 1, lines: []*models.LogLine{line}}
+		s.pending[id] = p
+		s.queue = append(s.queue, p)
+		if len(s.pending) > maxPendingSequences {
+			s.drop()
+		}
+	}
+	return out
+}
+
+// Expire ends the sequences whose window closed before at, returning the
+// lines of unfinished ones for missing
+func (s *SequenceMatcher) Expire(at time.Time) []*models.LogLine {
+	var out []*models.LogLine
+	for len(s.queue) > 0 {
+		p := s.queue[0]
+		if !p.done {
+			if s.Within == 0 || !at.After(p.start.Add(s.Within)) {
+				break
+			}
+			p.done = true
+			delete(s.pending, p.key)
+			if s.Missing {
+				out = append(out, p.lines...)
+			}
+		}
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+	}
+	return out
+}
+
+// drop discards the oldest pending sequence
+func (s *SequenceMatcher) drop() {
+	for len(s.queue) > 0 {
+		p := s.queue[0]
+		s.queue = s.queue[1:]
+		if !p.done {
+			p.done = true
+			delete(s.pending, p.key)
+			return
+		}
+	}
+}
+
+// String returns the sequence in query syntax
+func (s *SequenceMatcher) String() string {
+	var b strings.Builder
+	if s.Missing {
+		b.WriteString("missing by ")
+	} else {
+		b.WriteString("sequence by ")
+	}
+	b.WriteString(strings.Join(s.By, ","))
+	for _, step := range s.Steps {
+		b.WriteString(" [" + step.String() + "]")
+	}
+	if s.Within > 0 {
+		b.WriteString(" within " + s.Within.String())
+	}
+	return b.String()
+}
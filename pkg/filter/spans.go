@@ -0,0 +1,184 @@
+package filter
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// MatchSpans returns where the terms of the current filter matched a line's
+// raw text, sorted and with overlaps merged. It returns nil for lines the
+// filter does not match. Terms under NOT, OR branches that did not match,
+// and terms with no place in the text (time ranges, field existence) add
+// nothing.
+func (f *FilterEngine) MatchSpans(line *models.LogLine) []models.Span {
+	var spans []models.Span
+	switch {
+	case f.sequence != nil:
+		for _, step := range f.sequence.Steps {
+			if step.Evaluate(line, f) {
+				spans = f.collectSpans(step, line, spans)
+			}
+		}
+	case f.expression != nil && f.expression.Evaluate(line, f):
+		spans = f.collectSpans(f.expression, line, spans)
+	}
+	return mergeSpans(spans)
+}
+
+// collectSpans appends the spans of the terms of expr that hold for a line
+func (f *FilterEngine) collectSpans(expr QueryExpression, line *models.LogLine, spans []models.Span) []models.Span {
+	switch e := expr.(type) {
+	case *AndExpression:
+		spans = f.collectSpans(e.Left, line, spans)
+		return f.collectSpans(e.Right, line, spans)
+	case *OrExpression:
+		for _, side := range []QueryExpression{e.Left, e.Right} {
+			if side.Evaluate(line, f) {
+				spans = f.collectSpans(side, line, spans)
+			}
+		}
+	case *TextExpression:
+		return append(spans, e.spans(line.Raw)...)
+	case *FieldExpression:
+		return append(spans, f.fieldSpans(e, line)...)
+	}
+	return spans
+}
+
+// spans finds every occurrence of the text or regex in a line
+func (e *TextExpression) spans(text string) []models.Span {
+	switch {
+	case e.IsRegex && e.Pattern != nil:
+		return regexSpans(e.Pattern, text)
+	case e.CaseSensitive:
+		return indexAll(text, e.Text, false)
+	default:
+		return indexAll(text, e.Text, true)
+	}
+}
+
+// fieldSpans locates the values of a field that satisfy a field term. A
+// value is looked for after the field's key, then anywhere in the line;
+// values that never appear in the text, such as a normalized level or the
+// source path, have no span.
+func (f *FilterEngine) fieldSpans(e *FieldExpression, line *models.LogLine) []models.Span {
+	if e.Operator == ":!=" {
+		return nil
+	}
+
+	var spans []models.Span
+	for _, value := range f.extractFieldValues(line, e.Field) {
+		if value == "" || !f.matchFieldExpression(value, e) {
+			continue
+		}
+
+		// The message fields hold the whole line; only a pattern narrows it
+		if value == line.Raw {
+			if e.Pattern != nil {
+				spans = append(spans, regexSpans(e.Pattern, line.Raw)...)
+			}
+			continue
+		}
+
+		from := 0
+		if key := fieldKey(e.Field); key != "" {
+			if i := strings.Index(line.Raw, key); i >= 0 {
+				from = i + len(key)
+			}
+		}
+		span, ok := indexFrom(line.Raw, value, from)
+		if !ok {
+			span, ok = indexFrom(line.Raw, value, 0)
+		}
+		if !ok {
+			continue
+		}
+
+		// A regex term marks only what it matched within the value
+		if e.Operator == ":~" && e.Pattern != nil {
+			for _, sub := range regexSpans(e.Pattern, line.Raw[span.Start:span.End]) {
+				spans = append(spans, models.Span{Start: span.Start + sub.Start, End: span.Start + sub.End})
+			}
+			continue
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// fieldKey returns the last key of a field path, as written in the line
+func fieldKey(field string) string {
+	if i := strings.LastIndexAny(field, ".["); i >= 0 {
+		field = field[i+1:]
+	}
+	return strings.Trim(field, `"]*`)
+}
+
+// indexFrom finds the first occurrence of value at or after from, ignoring
+// case
+func indexFrom(text, value string, from int) (models.Span, bool) {
+	spans := indexAll(text[from:], value, true)
+	if len(spans) == 0 {
+		return models.Span{}, false
+	}
+	return models.Span{Start: from + spans[0].Start, End: from + spans[0].End}, true
+}
+
+// indexAll finds the non-overlapping occurrences of sub in text
+func indexAll(text, sub string, foldCase bool) []models.Span {
+	if sub == "" {
+		return nil
+	}
+	if foldCase {
+		lower := strings.ToLower(text)
+		if len(lower) != len(text) {
+			// Lowercasing moved the byte offsets; fall back to a regex
+			return regexSpans(regexp.MustCompile("(?i)"+regexp.QuoteMeta(sub)), text)
+		}
+		text, sub = lower, strings.ToLower(sub)
+	}
+
+	var spans []models.Span
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], sub)
+		if i < 0 {
+			return spans
+		}
+		start := offset + i
+		spans = append(spans, models.Span{Start: start, End: start + len(sub)})
+		offset = start + len(sub)
+	}
+}
+
+// regexSpans finds the non-empty matches of a pattern
+func regexSpans(pattern *regexp.Regexp, text string) []models.Span {
+	var spans []models.Span
+	for _, loc := range pattern.FindAllStringIndex(text, -1) {
+		if loc[1] > loc[0] {
+			spans = append(spans, models.Span{Start: loc[0], End: loc[1]})
+		}
+	}
+	return spans
+}
+
+// mergeSpans sorts spans and merges the ones that overlap
+func mergeSpans(spans []models.Span) []models.Span {
+	if len(spans) < 2 {
+		return spans
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	merged := spans[:1]
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span.Start < last.End {
+			last.End = max(last.End, span.End)
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// now is the clock used to resolve relative times; tests replace it
+var now = time.Now
+
+// TimeAnchor is the reference point of a relative time bound
+type TimeAnchor int
+
+const (
+	AnchorAbsolute  TimeAnchor = iota // Absolute holds the time
+	AnchorNow                         // now, -15m, now-2h
+	AnchorToday                       // @today, 14:30:00
+	AnchorYesterday                   // @yesterday
+)
+
+// TimeBound is a point in time that may be relative to the moment a line is
+// evaluated, so relative filters keep moving with a live tail
+type TimeBound struct {
+	Anchor   TimeAnchor
+	Absolute time.Time
+	Offset   time.Duration
+	Raw      string
+}
+
+// Resolve returns the concrete time of the bound at the given instant
+func (b TimeBound) Resolve(at time.Time) time.Time {
+	switch b.Anchor {
+	case AnchorNow:
+		return at.Add(b.Offset)
+	case AnchorToday:
+		return startOfDay(at).Add(b.Offset)
+	case AnchorYesterday:
+		return startOfDay(at).AddDate(0, 0, -1).Add(b.Offset)
+	default:
+		return b.Absolute
+	}
+}
+
+// IsRelative reports whether the bound depends on the current time
+func (b TimeBound) IsRelative() bool {
+	return b.Anchor != AnchorAbsolute
+}
+
+// String returns the bound as written in the query
+func (b TimeBound) String() string {
+	if b.Raw != "" {
+		return b.Raw
+	}
+	return b.Absolute.Format(time.RFC3339)
+}
+
+// startOfDay truncates a time to local midnight
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// absoluteTimeFormats are the absolute layouts accepted in time queries
+var absoluteTimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTimeBound parses a time as written in time queries
+func ParseTimeBound(s string) (TimeBound, error) {
+	return parseTimeBound(s)
+}
+
+// parseTimeBound parses an absolute time, a time of day (anchored to the
+// current day when evaluated), or a relative expression: now, -15m,
+// now-2h, @today, @yesterday+9h
+func parseTimeBound(s string) (TimeBound, error) {
+	s = strings.TrimSpace(s)
+	bound := TimeBound{Raw: s}
+
+	anchor, rest := s, ""
+	if i := strings.IndexAny(s[min(1, len(s)):], "+-"); i >= 0 {
+		anchor, rest = s[:i+1], s[i+1:]
+	}
+
+	switch strings.ToLower(anchor) {
+	case "now", "@now":
+		bound.Anchor = AnchorNow
+	case "@today":
+		bound.Anchor = AnchorToday
+	case "@yesterday":
+		bound.Anchor = AnchorYesterday
+	default:
+		if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+			bound.Anchor = AnchorNow
+			rest = s
+			break
+		}
+		return parseAbsoluteTime(s)
+	}
+
+	if rest != "" {
+		offset, err := parseSignedDuration(rest)
+		if err != nil {
+			return TimeBound{}, err
+		}
+		bound.Offset = offset
+	}
+
+	return bound, nil
+}
+
+// parseAbsoluteTime parses absolute layouts and bare times of day
+func parseAbsoluteTime(s string) (TimeBound, error) {
+	for _, layout := range absoluteTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return TimeBound{Absolute: t, Raw: s}, nil
+		}
+	}
+
+	for _, layout := range []string{"15:04:05", "15:04"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+			return TimeBound{Anchor: AnchorToday, Offset: offset, Raw: s}, nil
+		}
+	}
+
+	return TimeBound{}, fmt.Errorf("unable to parse time: %s", s)
+}
+
+// parseSignedDuration parses +1h, -15m, -1d12h or -2w
+func parseSignedDuration(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	default:
+		return 0, fmt.Errorf("relative time must start with + or -: %s", s)
+	}
+
+	d, err := parseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	return sign * d, nil
+}
+
+// parseDuration extends time.ParseDuration with leading day and week units
+func parseDuration(s string) (time.Duration, error) {
+	var total time.Duration
+
+	for _, unit := range []struct {
+		suffix string
+		size   time.Duration
+	}{{"w", 7 * 24 * time.Hour}, {"d", 24 * time.Hour}} {
+		i := strings.Index(s, unit.suffix)
+		if i <= 0 {
+			continue
+		}
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", s)
+		}
+		total += time.Duration(n * float64(unit.size))
+		s = s[i+1:]
+	}
+
+	if s == "" {
+		return total, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return total + d, nil
+}
@@ -0,0 +1,185 @@
+package filter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// unitKind is the dimension of a quantity
+type unitKind int
+
+const (
+	unitNone     unitKind = iota // plain number
+	unitDuration                 // normalized to seconds
+	unitBytes                    // normalized to bytes
+)
+
+// quantity is a number with an optional unit, e.g. 250ms, 1.5s or 10MB
+type quantity struct {
+	value float64 // in the unit's base (seconds or bytes)
+	kind  unitKind
+	scale float64 // multiplier of the written unit, used for bare numbers
+}
+
+// byteUnits are binary multiples; KB and KiB are both 1024 bytes
+var byteUnits = map[string]float64{
+	"b":   1,
+	"kb":  1 << 10,
+	"kib": 1 << 10,
+	"mb":  1 << 20,
+	"mib": 1 << 20,
+	"gb":  1 << 30,
+	"gib": 1 << 30,
+	"tb":  1 << 40,
+	"tib": 1 << 40,
+}
+
+// durationUnits map duration suffixes to seconds
+var durationUnits = map[string]float64{
+	"ns": 1e-9,
+	"us": 1e-6,
+	"µs": 1e-6,
+	"ms": 1e-3,
+	"s":  1,
+	"m":  60,
+	"h":  3600,
+	"d":  86400,
+}
+
+// parseQuantity parses a number with an optional duration or byte unit.
+// Compound durations such as 1m30s are accepted as well. NaN and infinite
+// values are not numbers to compare.
+func parseQuantity(s string) (quantity, bool) {
+	q, ok := parseUnits(s)
+	if !ok || math.IsNaN(q.value) || math.IsInf(q.value, 0) {
+		return quantity{}, false
+	}
+	return q, true
+}
+
+// parseUnits splits a quantity into its number and unit
+func parseUnits(s string) (quantity, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return quantity{}, false
+	}
+
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return quantity{value: n, kind: unitNone, scale: 1}, true
+	}
+
+	split := strings.IndexFunc(s, func(r rune) bool {
+		return !(unicode.IsDigit(r) || r == '.' || r == '-' || r == '+')
+	})
+	if split <= 0 {
+		return quantity{}, false
+	}
+
+	n, err := strconv.ParseFloat(s[:split], 64)
+	if err != nil {
+		return quantity{}, false
+	}
+	unit := strings.TrimSpace(s[split:])
+
+	if scale, ok := byteUnits[strings.ToLower(unit)]; ok {
+		return quantity{value: n * scale, kind: unitBytes, scale: scale}, true
+	}
+
+	if scale, ok := durationUnits[unit]; ok {
+		return quantity{value: n * scale, kind: unitDuration, scale: scale}, true
+	}
+
+	// Compound Go durations (1m30s, 1h2m3.5s) are scaled by their last unit
+	if d, err := time.ParseDuration(s); err == nil {
+		last := strings.LastIndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) })
+		scale, ok := durationUnits[s[last+1:]]
+		if !ok {
+			scale = 1
+		}
+		return quantity{value: d.Seconds(), kind: unitDuration, scale: scale}, true
+	}
+
+	return quantity{}, false
+}
+
+// compareQuantities compares a field value with a query value. A plain
+// number on either side takes the unit of the other side, so latency:>250ms
+// matches a logged 812 and status:>500 matches only plain numbers.
+func compareQuantities(field, query string) (int, bool) {
+	fq, ok := parseQuantity(field)
+	if !ok {
+		return 0, false
+	}
+	qq, ok := parseQuantity(query)
+	if !ok {
+		return 0, false
+	}
+
+	switch {
+	case fq.kind == qq.kind:
+	case fq.kind == unitNone:
+		fq = quantity{value: fq.value * qq.scale, kind: qq.kind}
+	case qq.kind == unitNone:
+		qq = quantity{value: qq.value * fq.scale, kind: fq.kind}
+	default:
+		return 0, false // durations and sizes do not compare
+	}
+
+	switch {
+	case fq.value < qq.value:
+		return -1, true
+	case fq.value > qq.value:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// Units of ParseQuantity results
+const (
+	UnitSeconds = "s"
+	UnitBytes   = "B"
+)
+
+// ParseQuantity parses a number with an optional duration or byte unit into
+// seconds or bytes. The unit is UnitSeconds, UnitBytes or "" for a plain
+// number.
+func ParseQuantity(s string) (float64, string, bool) {
+	q, ok := parseQuantity(s)
+	if !ok {
+		return 0, "", false
+	}
+	switch q.kind {
+	case unitDuration:
+		return q.value, UnitSeconds, true
+	case unitBytes:
+		return q.value, UnitBytes, true
+	}
+	return q.value, "", true
+}
+
+// FormatQuantity formats a value in seconds or bytes with a readable unit,
+// e.g. 812ms or 1.5MB, that ParseQuantity reads back
+func FormatQuantity(value float64, unit string) string {
+	switch unit {
+	case UnitSeconds:
+		d := time.Duration(value * float64(time.Second))
+		if d >= time.Microsecond || d <= -time.Microsecond {
+			d = d.Round(time.Microsecond)
+		}
+		return d.String()
+	case UnitBytes:
+		scale, suffix := 1.0, "B"
+		for _, u := range []string{"KB", "MB", "GB", "TB"} {
+			if math.Abs(value) < byteUnits[strings.ToLower(u)] {
+				break
+			}
+			scale, suffix = byteUnits[strings.ToLower(u)], u
+		}
+		return strconv.FormatFloat(math.Round(value/scale*100)/100, 'f', -1, 64) + suffix
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -3,6 +3,7 @@ package highlighter
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
@@ -65,6 +66,8 @@ var (
 			"keyword":     lipgloss.Color("#c586c0"),
 			"json":        lipgloss.Color("#6a9955"),
 			"error_text":  lipgloss.Color("#f44747"),
+			"match":       lipgloss.Color("#ffd866"),
+			"match_focus": lipgloss.Color("#ff8c00"),
 		},
 	}
 
@@ -91,6 +94,8 @@ var (
 			"keyword":     lipgloss.Color("#8250df"),
 			"json":        lipgloss.Color("#1f883d"),
 			"error_text":  lipgloss.Color("#d1242f"),
+			"match":       lipgloss.Color("#fff8c5"),
+			"match_focus": lipgloss.Color("#ffb77c"),
 		},
 	}
 
@@ -117,6 +122,8 @@ var (
 			"keyword":     lipgloss.Color("#ffffff"),
 			"json":        lipgloss.Color("#ffffff"),
 			"error_text":  lipgloss.Color("#ffffff"),
+			"match":       lipgloss.Color("#c0c0c0"),
+			"match_focus": lipgloss.Color("#ffffff"),
 		},
 	}
 )
@@ -238,12 +245,31 @@ func (h *Highlighter) addBuiltinRules() {
 
 // Highlight processes a log line and applies syntax highlighting
 func (h *Highlighter) Highlight(line *models.LogLine) string {
+	return h.HighlightMatches(line, nil, -1)
+}
+
+// HighlightMatches highlights a line like Highlight, drawing the spans a
+// filter matched over the rule colors; the span at index current, if any,
+// is drawn as the current match
+func (h *Highlighter) HighlightMatches(line *models.LogLine, spans []models.Span, current int) string {
 	if line == nil || line.Raw == "" {
 		return ""
 	}
 
-	// Start with the raw text
-	result := line.Raw
+	tokens := h.tokenize(line.Raw)
+
+	// Store tokens in the line
+	line.Tokens = tokens
+
+	if len(spans) > 0 {
+		tokens = overlayMatches(line.Raw, tokens, spans, current)
+	}
+	return h.applyStyles(line.Raw, tokens)
+}
+
+// tokenize finds the tokens of the highlight rules in a text, sorted and
+// without overlaps
+func (h *Highlighter) tokenize(result string) []models.Token {
 	tokens := []models.Token{}
 
 	// Apply all rules
@@ -279,15 +305,43 @@ func (h *Highlighter) Highlight(line *models.LogLine) string {
 	}
 
 	// Remove overlapping tokens (keep the first one found for each position)
-	tokens = h.removeOverlappingTokens(tokens)
+	return h.removeOverlappingTokens(tokens)
+}
 
-	// Apply styling
-	styledResult := h.applyStyles(result, tokens)
+// overlayMatches cuts the match spans out of the rule tokens and adds them
+// as match tokens, in order of position
+func overlayMatches(text string, tokens []models.Token, spans []models.Span, current int) []models.Token {
+	var out []models.Token
+	for _, token := range tokens {
+		start := token.Start
+		for _, span := range spans {
+			if span.End <= start || span.Start >= token.End {
+				continue
+			}
+			if span.Start > start {
+				out = append(out, models.Token{Text: text[start:span.Start], TokenType: token.TokenType, Start: start, End: span.Start})
+			}
+			start = max(start, span.End)
+		}
+		if start < token.End {
+			out = append(out, models.Token{Text: text[start:token.End], TokenType: token.TokenType, Start: start, End: token.End})
+		}
+	}
 
-	// Store tokens in the line
-	line.Tokens = tokens
+	for i, span := range spans {
+		end := min(span.End, len(text))
+		if span.Start >= end {
+			continue
+		}
+		tokenType := models.TokenMatch
+		if i == current {
+			tokenType = models.TokenFocusMatch
+		}
+		out = append(out, models.Token{Text: text[span.Start:end], TokenType: tokenType, Start: span.Start, End: end})
+	}
 
-	return styledResult
+	sort.Slice(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
 }
 
 // applyStyles applies styles to the text based on tokens
@@ -323,6 +377,15 @@ func (h *Highlighter) applyStyles(text string, tokens []models.Token) string {
 
 // getTokenStyle returns the appropriate style for a token
 func (h *Highlighter) getTokenStyle(token models.Token) lipgloss.Style {
+	// Matches stand out from the rule colors
+	switch token.TokenType {
+	case models.TokenMatch, models.TokenFocusMatch:
+		return lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#000000")).
+			Background(h.getStaticColor(string(token.TokenType))(token.Text)).
+			Bold(token.TokenType == models.TokenFocusMatch)
+	}
+
 	// Find the rule that created this token
 	for _, rule := range h.rules {
 		if rule.TokenType == token.TokenType {
@@ -442,44 +505,72 @@ func (h *Highlighter) getConfigStyle(colorName, styleName string) StyleFunc {
 	}
 }
 
-// RenderStructured renders structured data (JSON/YAML) with highlighting
+// RenderStructured renders structured data (JSON/YAML) with highlighting,
+// keys sorted and arrays one element per line
 func (h *Highlighter) RenderStructured(data map[string]interface{}, indent int) string {
 	var result strings.Builder
 	indentStr := strings.Repeat("  ", indent)
 	
-	for key, value := range data {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	
+	for _, key := range keys {
 		result.WriteString(indentStr)
-		
-		// Highlight key
-		keyStyle := lipgloss.NewStyle().Foreground(h.theme.Colors["keyword"]).Bold(true)
-		result.WriteString(keyStyle.Render(key))
+		result.WriteString(h.RenderKey(key))
 		result.WriteString(": ")
-		
-		// Highlight value based on type
-		switch v := value.(type) {
-		case string:
-			stringStyle := lipgloss.NewStyle().Foreground(h.theme.Colors["string"])
-			result.WriteString(stringStyle.Render(fmt.Sprintf("\"%s\"", v)))
-		case float64, int, int64:
-			numberStyle := lipgloss.NewStyle().Foreground(h.theme.Colors["number"])
-			result.WriteString(numberStyle.Render(fmt.Sprintf("%v", v)))
-		case bool:
-			keywordStyle := lipgloss.NewStyle().Foreground(h.theme.Colors["keyword"])
-			result.WriteString(keywordStyle.Render(fmt.Sprintf("%t", v)))
-		case map[string]interface{}:
-			result.WriteString("{\n")
-			result.WriteString(h.RenderStructured(v, indent+1))
-			result.WriteString(indentStr + "}")
-		default:
-			result.WriteString(fmt.Sprintf("%v", v))
-		}
-		
+		h.renderNested(&result, data[key], indent)
 		result.WriteString("\n")
 	}
 	
 	return result.String()
 }
 
+// renderNested writes a value, expanding maps and arrays below the key
+func (h *Highlighter) renderNested(result *strings.Builder, value interface{}, indent int) {
+	indentStr := strings.Repeat("  ", indent)
+	
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result.WriteString("{\n")
+		result.WriteString(h.RenderStructured(v, indent+1))
+		result.WriteString(indentStr + "}")
+	case []interface{}:
+		result.WriteString("[\n")
+		for _, elem := range v {
+			result.WriteString(indentStr + "  ")
+			h.renderNested(result, elem, indent+1)
+			result.WriteString("\n")
+		}
+		result.WriteString(indentStr + "]")
+	default:
+		result.WriteString(h.RenderValue(v))
+	}
+}
+
+// RenderKey highlights a field name of structured data
+func (h *Highlighter) RenderKey(key string) string {
+	return lipgloss.NewStyle().Foreground(h.theme.Colors["keyword"]).Bold(true).Render(key)
+}
+
+// RenderValue highlights a scalar value of structured data by its type
+func (h *Highlighter) RenderValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return lipgloss.NewStyle().Foreground(h.theme.Colors["string"]).Render(fmt.Sprintf("%q", v))
+	case float64, int, int64:
+		return lipgloss.NewStyle().Foreground(h.theme.Colors["number"]).Render(fmt.Sprintf("%v", v))
+	case bool:
+		return lipgloss.NewStyle().Foreground(h.theme.Colors["keyword"]).Render(fmt.Sprintf("%t", v))
+	case nil:
+		return lipgloss.NewStyle().Foreground(h.theme.Colors["keyword"]).Render("null")
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
 // SetTheme changes the current theme
 func (h *Highlighter) SetTheme(themeName string) {
 	switch themeName {
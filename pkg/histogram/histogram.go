@@ -0,0 +1,192 @@
+// Package histogram counts log lines per time bucket and level, coarsening
+// the buckets as the time span grows so they fit a fixed width
+package histogram
+
+import (
+	"sort"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// Level groups the levels a bar is stacked by
+type Level int
+
+const (
+	LevelError Level = iota // ERROR and FATAL
+	LevelWarn
+	LevelInfo
+	LevelOther // DEBUG, TRACE and lines without a level
+	levelCount
+)
+
+// bucketSizes are the bucket sizes tried in order; each is a multiple of
+// the one before so buckets can be merged when the span grows
+var bucketSizes = []time.Duration{
+	time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 10 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+	7 * 24 * time.Hour, 28 * 24 * time.Hour, 364 * 24 * time.Hour,
+}
+
+// Bucket is the number of lines of each level in one time bucket
+type Bucket struct {
+	Start  time.Time
+	Counts [levelCount]int
+}
+
+// Total returns the number of lines in the bucket
+func (b Bucket) Total() int {
+	total := 0
+	for _, count := range b.Counts {
+		total += count
+	}
+	return total
+}
+
+// Histogram counts lines per time bucket and level. Lines without a
+// timestamp are not counted. A Histogram is not safe for concurrent use.
+type Histogram struct {
+	width   int
+	size    int // Index into bucketSizes
+	buckets map[int64]*Bucket
+}
+
+// New creates a histogram of at most width buckets
+func New(width int) *Histogram {
+	return &Histogram{
+		width:   max(1, width),
+		buckets: make(map[int64]*Bucket),
+	}
+}
+
+// LevelOf returns the level group of a line
+func LevelOf(line *models.LogLine) Level {
+	switch models.LogLevel(line.Level) {
+	case models.LevelError, models.LevelFatal:
+		return LevelError
+	case models.LevelWarn:
+		return LevelWarn
+	case models.LevelInfo:
+		return LevelInfo
+	default:
+		return LevelOther
+	}
+}
+
+// Add counts a line, widening the buckets when its time falls outside the
+// span the width allows
+func (h *Histogram) Add(line *models.LogLine) {
+	if line.Timestamp.IsZero() {
+		return
+	}
+	key := h.key(line.Timestamp)
+	b, ok := h.buckets[key]
+	if !ok {
+		b = &Bucket{Start: time.Unix(0, key)}
+		h.buckets[key] = b
+	}
+	b.Counts[LevelOf(line)]++
+	h.fit()
+}
+
+// Remove uncounts a line that was added before
+func (h *Histogram) Remove(line *models.LogLine) {
+	if line.Timestamp.IsZero() {
+		return
+	}
+	key := h.key(line.Timestamp)
+	b, ok := h.buckets[key]
+	if !ok {
+		return
+	}
+	if level := LevelOf(line); b.Counts[level] > 0 {
+		b.Counts[level]--
+	}
+	if b.Total() == 0 {
+		delete(h.buckets, key)
+	}
+}
+
+// Reset forgets every counted line and returns to the finest buckets
+func (h *Histogram) Reset() {
+	h.size = 0
+	h.buckets = make(map[int64]*Bucket)
+}
+
+// BucketSize returns the current duration of a bucket
+func (h *Histogram) BucketSize() time.Duration {
+	return bucketSizes[h.size]
+}
+
+// Len returns the number of buckets holding lines
+func (h *Histogram) Len() int {
+	return len(h.buckets)
+}
+
+// Buckets returns every bucket from the first counted one to the last,
+// including empty ones, oldest first. A span too wide for the largest
+// buckets is cut to the newest width of them.
+func (h *Histogram) Buckets() []Bucket {
+	if len(h.buckets) == 0 {
+		return nil
+	}
+	first, last := h.span()
+	step := int64(h.BucketSize())
+	first = max(first, last-int64(h.width-1)*step)
+	out := make([]Bucket, 0, (last-first)/step+1)
+	for key := first; key <= last; key += step {
+		if b, ok := h.buckets[key]; ok {
+			out = append(out, *b)
+		} else {
+			out = append(out, Bucket{Start: time.Unix(0, key)})
+		}
+	}
+	return out
+}
+
+// key returns the start of the bucket holding a time, in Unix nanoseconds
+func (h *Histogram) key(t time.Time) int64 {
+	size := int64(h.BucketSize())
+	ns := t.UnixNano()
+	key := ns - ns%size
+	if ns < 0 && ns%size != 0 {
+		key -= size
+	}
+	return key
+}
+
+// span returns the first and last bucket keys
+func (h *Histogram) span() (int64, int64) {
+	keys := make([]int64, 0, len(h.buckets))
+	for key := range h.buckets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys[0], keys[len(keys)-1]
+}
+
+// fit merges buckets into larger ones until the span fits the width
+func (h *Histogram) fit() {
+	for h.size < len(bucketSizes)-1 {
+		first, last := h.span()
+		if (last-first)/int64(h.BucketSize())+1 <= int64(h.width) {
+			return
+		}
+
+		h.size++
+		merged := make(map[int64]*Bucket, len(h.buckets))
+		for _, b := range h.buckets {
+			key := h.key(b.Start)
+			m, ok := merged[key]
+			if !ok {
+				m = &Bucket{Start: time.Unix(0, key)}
+				merged[key] = m
+			}
+			for level, count := range b.Counts {
+				m.Counts[level] += count
+			}
+		}
+		h.buckets = merged
+	}
+}
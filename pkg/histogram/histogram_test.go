@@ -0,0 +1,95 @@
+package histogram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+var base = time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)
+
+// lineAt returns a line of a level at an offset from base
+func lineAt(offset time.Duration, level string) *models.LogLine {
+	return &models.LogLine{Timestamp: base.Add(offset), Level: level}
+}
+
+func TestHistogram(t *testing.T) {
+	h := New(10)
+	h.Add(lineAt(0, "ERROR"))
+	h.Add(lineAt(500*time.Millisecond, "INFO"))
+	h.Add(lineAt(3*time.Second, "WARN"))
+	h.Add(&models.LogLine{Level: "INFO"}) // No timestamp
+
+	if h.BucketSize() != time.Second {
+		t.Errorf("Expected 1s buckets, got %s", h.BucketSize())
+	}
+	buckets := h.Buckets()
+	if len(buckets) != 4 {
+		t.Fatalf("Expected 4 buckets, got %d", len(buckets))
+	}
+	if !buckets[0].Start.Equal(base) || buckets[0].Counts[LevelError] != 1 || buckets[0].Counts[LevelInfo] != 1 {
+		t.Errorf("Unexpected first bucket %+v", buckets[0])
+	}
+	if buckets[1].Total() != 0 || buckets[2].Total() != 0 {
+		t.Errorf("Expected empty buckets between lines, got %+v %+v", buckets[1], buckets[2])
+	}
+	if buckets[3].Counts[LevelWarn] != 1 {
+		t.Errorf("Unexpected last bucket %+v", buckets[3])
+	}
+
+	// A minute of lines does not fit 10 one-second buckets
+	h.Add(lineAt(time.Minute, "DEBUG"))
+	if h.BucketSize() != 10*time.Second {
+		t.Errorf("Expected 10s buckets, got %s", h.BucketSize())
+	}
+	buckets = h.Buckets()
+	if len(buckets) != 7 || buckets[0].Total() != 3 || buckets[6].Counts[LevelOther] != 1 {
+		t.Errorf("Unexpected merged buckets %+v", buckets)
+	}
+
+	h.Remove(lineAt(time.Minute, "DEBUG"))
+	if buckets = h.Buckets(); len(buckets) != 1 || buckets[0].Total() != 3 {
+		t.Errorf("Expected one bucket after removing the last line, got %+v", buckets)
+	}
+
+	h.Reset()
+	if h.Len() != 0 || h.BucketSize() != time.Second {
+		t.Errorf("Expected an empty histogram of 1s buckets after reset")
+	}
+}
+
+func TestWideSpan(t *testing.T) {
+	h := New(10)
+	h.Add(lineAt(0, "INFO"))
+	h.Add(lineAt(5*365*24*time.Hour, "INFO"))
+	if h.BucketSize() != 364*24*time.Hour {
+		t.Errorf("Expected yearly buckets, got %s", h.BucketSize())
+	}
+	if buckets := h.Buckets(); len(buckets) > 10 {
+		t.Errorf("Expected at most 10 buckets, got %d", len(buckets))
+	}
+
+	// Past the largest buckets only the newest fit
+	h.Add(lineAt(-40*365*24*time.Hour, "ERROR"))
+	buckets := h.Buckets()
+	if len(buckets) != 10 || buckets[9].Total() != 1 {
+		t.Errorf("Expected the newest 10 buckets, got %d", len(buckets))
+	}
+}
+
+func TestLevelOf(t *testing.T) {
+	tests := map[string]Level{
+		"FATAL": LevelError,
+		"ERROR": LevelError,
+		"WARN":  LevelWarn,
+		"INFO":  LevelInfo,
+		"DEBUG": LevelOther,
+		"":      LevelOther,
+	}
+	for level, want := range tests {
+		if got := LevelOf(&models.LogLine{Level: level}); got != want {
+			t.Errorf("LevelOf(%q) = %d, want %d", level, got, want)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+// Package index keeps an inverted index of buffered log lines so keyword
+// and field-equality searches can skip lines that cannot match
+package index
+
+import (
+	"container/heap"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+// keyOverhead approximates the memory of a map entry and its slice header
+const keyOverhead = 64
+
+// postingSize is the memory of one posting list entry
+const postingSize = 4
+
+// gramSize is the length of the n-grams indexed for substring search
+const gramSize = 3
+
+// maxMergedTokens is the most words a search word may lie within before
+// the index gives up on it; merging their postings would cost more than a
+// scan
+const maxMergedTokens = 1024
+
+// Index maps tokens, n-grams and field values to the lines containing
+// them. Lines are numbered in the order they are added; callers map these
+// sequence numbers back to their own buffer. Candidates may run on another
+// goroutine while lines are added; the other methods must be called from
+// the goroutine adding lines.
+type Index struct {
+	mu       sync.RWMutex
+	budget   int64
+	ngrams   bool
+	tokens   map[string][]uint32 // lower-case words of the raw line
+	grams    map[string][]uint32 // n-grams of those words, when enabled
+	fields   map[string][]uint32 // field + "\x00" + lower-case value
+	next     uint32              // sequence number of the next line
+	first    uint32              // lowest sequence number still indexed
+	size     int64               // estimated memory in bytes
+	disabled bool
+}
+
+// New creates an index bounded to roughly budget bytes. A budget of zero
+// or less disables indexing; ngrams also indexes word n-grams, which
+// speeds up substring searches at the cost of memory.
+func New(budget int64, ngrams bool) *Index {
+	return &Index{
+		budget:   budget,
+		ngrams:   ngrams,
+		tokens:   make(map[string][]uint32),
+		grams:    make(map[string][]uint32),
+		fields:   make(map[string][]uint32),
+		disabled: budget <= 0,
+	}
+}
+
+// Add indexes a line and returns its sequence number. Once the budget is
+// exceeded the index is dropped and searches fall back to scanning.
+func (ix *Index) Add(line *models.LogLine) uint32 {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	seq := ix.next
+	ix.next++
+	if ix.disabled {
+		return seq
+	}
+
+	for _, token := range tokenize(strings.ToLower(line.Raw)) {
+		ix.post(ix.tokens, token, seq)
+		if ix.ngrams {
+			for _, gram := range grams(token) {
+				ix.post(ix.grams, gram, seq)
+			}
+		}
+	}
+
+	ix.post(ix.fields, fieldKey("@level", line.Level), seq)
+	ix.post(ix.fields, fieldKey("@source", line.Source), seq)
+	for key, value := range line.Parsed {
+		ix.post(ix.fields, fieldKey(key, parser.FormatValue(value)), seq)
+	}
+
+	if ix.size > ix.budget {
+		ix.disable()
+	}
+	return seq
+}
+
+// post appends a line to a posting list, once per line
+func (ix *Index) post(lists map[string][]uint32, key string, seq uint32) {
+	list, ok := lists[key]
+	if !ok {
+		ix.size += int64(len(key)) + keyOverhead
+	} else if list[len(list)-1] == seq {
+		return
+	}
+	lists[key] = append(list, seq)
+	ix.size += postingSize
+}
+
+// disable drops the index once it outgrows its budget
+func (ix *Index) disable() {
+	ix.disabled = true
+	ix.tokens = nil
+	ix.grams = nil
+	ix.fields = nil
+	ix.size = 0
+}
+
+// Enabled reports whether the index can answer searches
+func (ix *Index) Enabled() bool {
+	return !ix.disabled
+}
+
+// Size returns the estimated memory of the index in bytes
+func (ix *Index) Size() int64 {
+	return ix.size
+}
+
+// Next returns the sequence number the next added line will get
+func (ix *Index) Next() uint32 {
+	return ix.next
+}
+
+// First returns the lowest sequence number still indexed
+func (ix *Index) First() uint32 {
+	return ix.first
+}
+
+// Prune forgets lines numbered below min, e.g. after the buffer holding
+// them evicted them
+func (ix *Index) Prune(min uint32) {
+	if ix.disabled || min <= ix.first {
+		return
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.first = min
+	ix.size = 0
+	for _, lists := range []map[string][]uint32{ix.tokens, ix.grams, ix.fields} {
+		for key, list := range lists {
+			cut := sort.Search(len(list), func(i int) bool { return list[i] >= min })
+			if cut == len(list) {
+				delete(lists, key)
+				continue
+			}
+			if cut > 0 {
+				list = append([]uint32(nil), list[cut:]...)
+				lists[key] = list
+			}
+			ix.size += int64(len(key)) + keyOverhead + int64(len(list))*postingSize
+		}
+	}
+}
+
+// Candidates returns, in ascending order, the sequence numbers of lines
+// that may match expr; every matching line is among them, but callers must
+// still evaluate expr on each. It returns false when the index cannot
+// narrow the search, e.g. for regexes, negations or ranges.
+func (ix *Index) Candidates(expr filter.QueryExpression) ([]uint32, bool) {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	if ix.disabled || expr == nil {
+		return nil, false
+	}
+	return ix.plan(expr)
+}
+
+// plan resolves an expression to candidate lines
+func (ix *Index) plan(expr filter.QueryExpression) ([]uint32, bool) {
+	switch e := expr.(type) {
+	case *filter.AndExpression:
+		left, okLeft := ix.plan(e.Left)
+		right, okRight := ix.plan(e.Right)
+		switch {
+		case okLeft && okRight:
+			return intersect(left, right), true
+		case okLeft:
+			return left, true
+		case okRight:
+			return right, true
+		}
+		return nil, false
+
+	case *filter.OrExpression:
+		left, ok := ix.plan(e.Left)
+		if !ok {
+			return nil, false
+		}
+		right, ok := ix.plan(e.Right)
+		if !ok {
+			return nil, false
+		}
+		return union(left, right), true
+
+	case *filter.TextExpression:
+		if e.IsRegex {
+			return nil, false
+		}
+		return ix.text(e.Text)
+
+	case *filter.FieldExpression:
+		return ix.field(e)
+	}
+	return nil, false
+}
+
+// text finds lines that may contain a phrase. Every word of the phrase
+// lies within some word of a matching line, so each word narrows the set:
+// through its n-grams when indexed, else through the words containing it.
+// Words found in too many lines to narrow the set are skipped.
+func (ix *Index) text(phrase string) ([]uint32, bool) {
+	words := tokenize(strings.ToLower(phrase))
+
+	var result []uint32
+	narrowed := false
+	for i, word := range words {
+		var set []uint32
+		if ix.ngrams && utf8.RuneCountInString(word) >= gramSize {
+			for j, gram := range grams(word) {
+				if j == 0 {
+					set = ix.grams[gram]
+				} else {
+					set = intersect(set, ix.grams[gram])
+				}
+			}
+		} else if list, ok := ix.tokens[word]; ok && len(words) > 1 && i > 0 && i < len(words)-1 {
+			// Inner words of a phrase must be whole words
+			set = list
+		} else if lists, ok := ix.containing(word); ok {
+			set = merge(lists)
+		} else {
+			continue
+		}
+
+		if !narrowed {
+			result, narrowed = set, true
+		} else {
+			result = intersect(result, set)
+		}
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result, narrowed
+}
+
+// containing returns the posting lists of the words containing a word. It
+// returns false when they are too many, or cover more than half the lines,
+// for the index to narrow the search.
+func (ix *Index) containing(word string) ([][]uint32, bool) {
+	var lists [][]uint32
+	postings := 0
+	limit := int(ix.next-ix.first) / 2
+	for token, list := range ix.tokens {
+		if !strings.Contains(token, word) {
+			continue
+		}
+		lists = append(lists, list)
+		postings += len(list)
+		if len(lists) > maxMergedTokens || postings > limit {
+			return nil, false
+		}
+	}
+	return lists, true
+}
+
+// builtinFields maps the aliases of indexed line attributes
+var builtinFields = map[string]string{
+	"level": "@level", "severity": "@level", "lvl": "@level",
+	"source": "@source", "file": "@source", "src": "@source",
+}
+
+// field finds lines whose field may equal a value. Only plain equality on
+// top-level fields and the level and source attributes is indexed.
+func (ix *Index) field(e *filter.FieldExpression) ([]uint32, bool) {
+	if (e.Operator != ":" && e.Operator != ":=") || e.Pattern != nil || e.Network != nil || e.Value == "" {
+		return nil, false
+	}
+
+	name := e.Field
+	if strings.ContainsAny(name, `.[]*"\`) {
+		return nil, false
+	}
+
+	set := ix.fields[fieldKey(name, e.Value)]
+	if builtin, ok := builtinFields[strings.ToLower(name)]; ok {
+		set = union(set, ix.fields[fieldKey(builtin, e.Value)])
+	} else if isAttribute(name) {
+		return nil, false
+	}
+	return set, true
+}
+
+// isAttribute reports whether a field name resolves to a line attribute
+// that is not indexed, such as message or timestamp
+func isAttribute(name string) bool {
+	switch strings.ToLower(name) {
+	case "message", "msg", "text", "raw", "timestamp", "time", "ts", "id", "line", "linenum", "offset":
+		return true
+	}
+	return false
+}
+
+// fieldKey builds the key of a field value
+func fieldKey(field, value string) string {
+	return field + "\x00" + strings.ToLower(value)
+}
+
+// tokenize splits text into runs of letters, digits and underscores
+func tokenize(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// grams returns the n-grams of a word, or the word itself when shorter
+func grams(word string) []string {
+	runes := []rune(word)
+	if len(runes) <= gramSize {
+		return []string{word}
+	}
+	out := make([]string, 0, len(runes)-gramSize+1)
+	for i := 0; i+gramSize <= len(runes); i++ {
+		out = append(out, string(runes[i:i+gramSize]))
+	}
+	return out
+}
+
+// intersect returns the values present in both sorted lists
+func intersect(a, b []uint32) []uint32 {
+	var out []uint32
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// merge merges sorted lists without duplicates, taking the smallest head
+// of all of them at each step
+func merge(lists [][]uint32) []uint32 {
+	h := make(listHeap, 0, len(lists))
+	total := 0
+	for _, list := range lists {
+		if len(list) > 0 {
+			h = append(h, list)
+			total += len(list)
+		}
+	}
+	heap.Init(&h)
+
+	out := make([]uint32, 0, total)
+	for len(h) > 0 {
+		seq := h[0][0]
+		if len(out) == 0 || out[len(out)-1] != seq {
+			out = append(out, seq)
+		}
+		if h[0] = h[0][1:]; len(h[0]) == 0 {
+			heap.Pop(&h)
+		} else {
+			heap.Fix(&h, 0)
+		}
+	}
+	return out
+}
+
+// listHeap orders the remaining parts of sorted lists by their head
+type listHeap [][]uint32
+
+func (h listHeap) Len() int           { return len(h) }
+func (h listHeap) Less(i, j int) bool { return h[i][0] < h[j][0] }
+func (h listHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *listHeap) Push(x any)        { *h = append(*h, x.([]uint32)) }
+func (h *listHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// union merges two sorted lists without duplicates
+func union(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
@@ -0,0 +1,160 @@
+package index
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+var testLines = []string{
+	`{"level":"ERROR","status":503,"user":"alice","msg":"upstream timeout"}`,
+	`{"level":"WARN","status":404,"user":"bob","msg":"not found"}`,
+	`{"level":"INFO","status":200,"user":"alice","msg":"request served"}`,
+	`plain text line with a Timeout and no fields`,
+}
+
+// buildIndex indexes the test lines and returns them with their parser
+func buildIndex(t *testing.T, ngrams bool) (*Index, []*models.LogLine, *parser.LogParser) {
+	t.Helper()
+
+	p := parser.New()
+	ix := New(1<<20, ngrams)
+	var lines []*models.LogLine
+	for _, raw := range testLines {
+		line := &models.LogLine{Raw: raw, Source: "app.log"}
+		p.ParseLogLine(line)
+		ix.Add(line)
+		lines = append(lines, line)
+	}
+	return ix, lines, p
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // candidate sequence numbers, or "scan"
+	}{
+		{`timeout`, "[0 3]"},
+		{`TIMEOUT`, "[0 3]"},
+		{`meout`, "[0 3]"},
+		{`"upstream timeout"`, "[0]"},
+		{`status:503`, "[0]"},
+		{`level:warn`, "[1]"},
+		{`severity:=INFO`, "[2]"},
+		{`source:app.log`, "[0 1 2 3]"},
+		{`user:alice AND served`, "[2]"},
+		{`user:alice AND status:>300`, "[0 2]"},
+		{`user:bob OR level:error`, "[0 1]"},
+		{`user:bob OR status:>300`, "scan"},
+		{`NOT timeout`, "scan"},
+		{`user:ali*`, "scan"},
+		{`msg:timeout`, "scan"},
+		{`missing`, "[]"},
+	}
+
+	for _, ngrams := range []bool{false, true} {
+		ix, lines, p := buildIndex(t, ngrams)
+		for _, tt := range tests {
+			f := filter.New(p)
+			if err := f.SetFilter(models.FilterOptions{Query: tt.query}); err != nil {
+				t.Fatalf("SetFilter(%q) failed: %v", tt.query, err)
+			}
+
+			seqs, ok := ix.Candidates(f.Expression())
+			got := "scan"
+			if ok {
+				got = fmt.Sprint(seqs)
+			}
+			if got != tt.want {
+				t.Errorf("%s (ngrams=%v): expected %s, got %s", tt.query, ngrams, tt.want, got)
+			}
+
+			// Every matching line must be a candidate
+			if !ok {
+				continue
+			}
+			candidate := make(map[uint32]bool)
+			for _, seq := range seqs {
+				candidate[seq] = true
+			}
+			for i, line := range lines {
+				if f.Match(line) && !candidate[uint32(i)] {
+					t.Errorf("%s (ngrams=%v): line %d matches but is not a candidate", tt.query, ngrams, i)
+				}
+			}
+		}
+	}
+}
+
+func TestBudgetAndPrune(t *testing.T) {
+	ix, _, p := buildIndex(t, false)
+	size := ix.Size()
+
+	ix.Prune(2)
+	if ix.First() != 2 || ix.Size() >= size {
+		t.Errorf("Prune: expected first 2 and a smaller index, got first %d, size %d of %d", ix.First(), ix.Size(), size)
+	}
+	expr, _ := filter.ParseQuery(`timeout`)
+	if seqs, ok := ix.Candidates(expr); !ok || fmt.Sprint(seqs) != "[3]" {
+		t.Errorf("Expected [3] after pruning, got %v (%v)", seqs, ok)
+	}
+
+	small := New(200, false)
+	for _, raw := range testLines {
+		line := &models.LogLine{Raw: raw}
+		p.ParseLogLine(line)
+		small.Add(line)
+	}
+	if small.Enabled() || small.Next() != uint32(len(testLines)) {
+		t.Errorf("Expected index over budget to be disabled, got enabled=%v next=%d", small.Enabled(), small.Next())
+	}
+	if _, ok := small.Candidates(expr); ok {
+		t.Error("Expected disabled index to fall back to scanning")
+	}
+
+	if New(0, false).Enabled() {
+		t.Error("Expected a zero budget to disable the index")
+	}
+}
+
+// buildNumbered indexes n lines holding a distinct request ID each
+func buildNumbered(n int) *Index {
+	ix := New(1<<30, false)
+	for i := 0; i < n; i++ {
+		ix.Add(&models.LogLine{Raw: fmt.Sprintf("request req%d took %dms", i, i%500)})
+	}
+	return ix
+}
+
+func TestShortWordCandidates(t *testing.T) {
+	ix := buildNumbered(20000)
+
+	// "1" lies within thousands of distinct words: scan rather than merge
+	expr, _ := filter.ParseQuery(`1`)
+	if seqs, ok := ix.Candidates(expr); ok {
+		t.Errorf("Expected a scan for a word in many tokens, got %d candidates", len(seqs))
+	}
+
+	// "request" is on every line, so only the ID narrows the phrase
+	expr, _ = filter.ParseQuery(`"request req1999"`)
+	seqs, ok := ix.Candidates(expr)
+	want := "[1999 19990 19991 19992 19993 19994 19995 19996 19997 19998 19999]"
+	if !ok || fmt.Sprint(seqs) != want {
+		t.Errorf("Expected %s, got %v (%v)", want, seqs, ok)
+	}
+}
+
+func BenchmarkShortWordCandidates(b *testing.B) {
+	ix := buildNumbered(200000)
+	for _, query := range []string{`1`, `a`, `req1999`} {
+		expr, _ := filter.ParseQuery(query)
+		b.Run(query, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ix.Candidates(expr)
+			}
+		})
+	}
+}
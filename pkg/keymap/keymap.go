@@ -0,0 +1,383 @@
+// Package keymap resolves key presses to named actions, with multi-key
+// sequences such as "g g" and count prefixes such as "10j"
+package keymap
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxCount caps a count prefix
+const maxCount = 99999
+
+// namedKeys are the multi-letter key names that are not sequences of
+// single-letter keys
+var namedKeys = map[string]bool{
+	"esc": true, "enter": true, "tab": true, "space": true, "backspace": true,
+	"delete": true, "insert": true, "up": true, "down": true, "left": true,
+	"right": true, "home": true, "end": true, "pgup": true, "pgdown": true,
+}
+
+// Binding is an action with its key sequences. Actions named mode.name only
+// apply in that mode; the others apply in the main view
+type Binding struct {
+	Action string
+	Keys   []string // Key sequences, keys separated by spaces
+	Help   string
+	Group  string
+}
+
+// Mode returns the mode of the binding, "" for the main view
+func (b Binding) Mode() string {
+	return modeOf(b.Action)
+}
+
+// Conflict is a key sequence bound to several actions of a mode, or a
+// sequence that is the prefix of another
+type Conflict struct {
+	Keys    string
+	Actions []string
+}
+
+// Error describes the conflict
+func (c Conflict) Error() string {
+	return fmt.Sprintf("key %q is bound to %s", Display(c.Keys), strings.Join(c.Actions, " and "))
+}
+
+// Keymap resolves keys to actions
+type Keymap struct {
+	bindings []Binding
+	index    map[string]int               // Action -> binding
+	actions  map[string]map[string]string // Mode -> sequence -> action
+	prefixes map[string]map[string]bool   // Mode -> proper prefixes of sequences
+
+	mode    string
+	pending []string
+	count   int
+}
+
+// New builds a keymap from default bindings and overrides from the config,
+// action -> keys. An override listing only default keys keeps all of them,
+// so "j" still leaves "down" bound. Overrides that name no action or
+// conflict with another binding are dropped, keeping the defaults, and
+// returned as errors
+func New(defaults []Binding, overrides map[string]string) (*Keymap, []error) {
+	var errs []error
+	bindings := make([]Binding, len(defaults))
+	copy(bindings, defaults)
+	index := make(map[string]int, len(bindings))
+	for i, b := range bindings {
+		index[b.Action] = i
+	}
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	overridden := make(map[string]bool)
+	for _, name := range names {
+		i, ok := index[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown action %q in keybindings", name))
+			continue
+		}
+		keys := ParseKeys(overrides[name])
+		if len(keys) == 0 {
+			errs = append(errs, fmt.Errorf("no keys for action %q in keybindings", name))
+			continue
+		}
+		if subset(keys, defaults[i].Keys) {
+			continue // Written when only the first default was configurable
+		}
+		bindings[i].Keys = keys
+		overridden[name] = true
+	}
+
+	// Revert overrides involved in a conflict until none is left
+	for {
+		conflicts := findConflicts(bindings)
+		reverted := false
+		for _, c := range conflicts {
+			errs = append(errs, c)
+			for _, action := range c.Actions {
+				if overridden[action] {
+					i := index[action]
+					bindings[i].Keys = defaults[i].Keys
+					delete(overridden, action)
+					reverted = true
+				}
+			}
+		}
+		if !reverted {
+			break
+		}
+	}
+
+	k := &Keymap{
+		bindings: bindings,
+		index:    index,
+		actions:  make(map[string]map[string]string),
+		prefixes: make(map[string]map[string]bool),
+	}
+	for _, b := range bindings {
+		mode := b.Mode()
+		if k.actions[mode] == nil {
+			k.actions[mode] = make(map[string]string)
+			k.prefixes[mode] = make(map[string]bool)
+		}
+		for _, seq := range b.Keys {
+			if _, taken := k.actions[mode][seq]; taken {
+				continue // Conflicting defaults: the first binding wins
+			}
+			k.actions[mode][seq] = b.Action
+			keys := strings.Split(seq, " ")
+			for n := 1; n < len(keys); n++ {
+				k.prefixes[mode][strings.Join(keys[:n], " ")] = true
+			}
+		}
+	}
+	return k, errs
+}
+
+// subset reports whether every key sequence of a is in b
+func subset(a, b []string) bool {
+	for _, seq := range a {
+		found := false
+		for _, other := range b {
+			found = found || seq == other
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// findConflicts returns the sequences bound twice within a mode and the
+// sequences that are the prefix of another
+func findConflicts(bindings []Binding) []Conflict {
+	type bound struct {
+		seq    string
+		action string
+	}
+	byMode := make(map[string][]bound)
+	var modes []string
+	for _, b := range bindings {
+		mode := b.Mode()
+		if _, ok := byMode[mode]; !ok {
+			modes = append(modes, mode)
+		}
+		for _, seq := range b.Keys {
+			byMode[mode] = append(byMode[mode], bound{seq, b.Action})
+		}
+	}
+
+	var conflicts []Conflict
+	for _, mode := range modes {
+		all := byMode[mode]
+		for i := 0; i < len(all); i++ {
+			for j := i + 1; j < len(all); j++ {
+				a, b := all[i], all[j]
+				if a.action == b.action {
+					continue
+				}
+				if a.seq == b.seq || strings.HasPrefix(b.seq, a.seq+" ") {
+					conflicts = append(conflicts, Conflict{Keys: a.seq, Actions: []string{a.action, b.action}})
+				} else if strings.HasPrefix(a.seq, b.seq+" ") {
+					conflicts = append(conflicts, Conflict{Keys: b.seq, Actions: []string{b.action, a.action}})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+// Press feeds a key of a mode and returns the action it completes with its
+// count, 0 when none was typed. It returns "" while a sequence or count is
+// pending and for unbound keys
+func (k *Keymap) Press(mode, key string) (string, int) {
+	key = normalize(key)
+	if mode != k.mode {
+		k.Reset()
+		k.mode = mode
+	}
+
+	if len(k.pending) == 0 && isDigit(key) && (k.count > 0 || key != "0") && !k.bound(mode, key) {
+		k.count = min(k.count*10+int(key[0]-'0'), maxCount)
+		return "", 0
+	}
+
+	seq := strings.Join(append(k.pending, key), " ")
+	if action, ok := k.actions[mode][seq]; ok {
+		count := k.count
+		k.Reset()
+		return action, count
+	}
+	if k.prefixes[mode][seq] {
+		k.pending = append(k.pending, key)
+		return "", 0
+	}
+
+	// An unfinished sequence is dropped and the key read on its own
+	retry := len(k.pending) > 0
+	k.Reset()
+	if retry {
+		return k.Press(mode, key)
+	}
+	return "", 0
+}
+
+// bound reports whether a sequence of a mode starts with a key
+func (k *Keymap) bound(mode, key string) bool {
+	_, ok := k.actions[mode][key]
+	return ok || k.prefixes[mode][key]
+}
+
+// Pending returns the count and keys typed so far, for display
+func (k *Keymap) Pending() string {
+	var s string
+	if k.count > 0 {
+		s = fmt.Sprint(k.count)
+	}
+	if len(k.pending) > 0 {
+		s += Display(strings.Join(k.pending, " "))
+	}
+	return s
+}
+
+// Reset drops the pending count and keys
+func (k *Keymap) Reset() {
+	k.pending = nil
+	k.count = 0
+}
+
+// Bindings returns the effective bindings in their default order
+func (k *Keymap) Bindings() []Binding {
+	return k.bindings
+}
+
+// Keys returns the key sequences of an action for display, joined by ", "
+func (k *Keymap) Keys(action string) string {
+	i, ok := k.index[action]
+	if !ok {
+		return ""
+	}
+	keys := make([]string, len(k.bindings[i].Keys))
+	for j, seq := range k.bindings[i].Keys {
+		keys[j] = Display(seq)
+	}
+	return strings.Join(keys, ", ")
+}
+
+// ParseKeys parses the keys of a config binding: alternatives are separated
+// by commas and the keys of a sequence by spaces, or written together when
+// they are single characters ("gg")
+func ParseKeys(value string) []string {
+	var alternatives []string
+	if strings.TrimSpace(value) == "," {
+		alternatives = []string{","}
+	} else {
+		alternatives = strings.Split(value, ",")
+	}
+
+	var seqs []string
+	for _, alt := range alternatives {
+		alt = strings.TrimSpace(alt)
+		if alt == "" {
+			if value == " " {
+				seqs = append(seqs, "space")
+			}
+			continue
+		}
+		var keys []string
+		for _, field := range strings.Fields(alt) {
+			keys = append(keys, splitKeys(field)...)
+		}
+		seqs = append(seqs, strings.Join(keys, " "))
+	}
+	return seqs
+}
+
+// splitKeys splits a word into its keys: a key name such as "ctrl+d" or
+// "enter" is one key, anything else one key per character
+func splitKeys(word string) []string {
+	lower := strings.ToLower(word)
+	if utf8.RuneCountInString(word) == 1 || namedKeys[lower] || (strings.Contains(word, "+") && len(word) > 1) ||
+		(len(lower) > 1 && lower[0] == 'f' && isDigit(lower[1:2])) {
+		return []string{normalize(word)}
+	}
+	keys := make([]string, 0, len(word))
+	for _, r := range word {
+		keys = append(keys, string(r))
+	}
+	return keys
+}
+
+// normalize returns the name of a key as written in bindings
+func normalize(key string) string {
+	switch key {
+	case " ":
+		return "space"
+	case "ctrl+@":
+		return "ctrl+space"
+	}
+	if lower := strings.ToLower(key); namedKeys[lower] {
+		return lower
+	}
+	return key
+}
+
+// Display formats a key sequence for the help screen
+func Display(seq string) string {
+	keys := strings.Split(seq, " ")
+	single := true
+	for i, key := range keys {
+		keys[i] = displayKey(key)
+		single = single && utf8.RuneCountInString(keys[i]) == 1
+	}
+	if single {
+		return strings.Join(keys, "")
+	}
+	return strings.Join(keys, " ")
+}
+
+// displayKey formats one key
+func displayKey(key string) string {
+	switch key {
+	case "up":
+		return "↑"
+	case "down":
+		return "↓"
+	case "left":
+		return "←"
+	case "right":
+		return "→"
+	}
+	if utf8.RuneCountInString(key) == 1 {
+		return key
+	}
+	parts := strings.Split(key, "+")
+	for i, part := range parts {
+		if i < len(parts)-1 || utf8.RuneCountInString(part) > 1 {
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return strings.Join(parts, "+")
+}
+
+// modeOf returns the mode of an action name
+func modeOf(action string) string {
+	if i := strings.IndexByte(action, '.'); i >= 0 {
+		return action[:i]
+	}
+	return ""
+}
+
+// isDigit reports whether a key is a single digit
+func isDigit(key string) bool {
+	return len(key) == 1 && key[0] >= '0' && key[0] <= '9'
+}
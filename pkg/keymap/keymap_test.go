@@ -0,0 +1,139 @@
+package keymap
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var defaults = []Binding{
+	{Action: "scroll_down", Keys: []string{"j", "down"}},
+	{Action: "scroll_up", Keys: []string{"k", "up"}},
+	{Action: "goto_top", Keys: []string{"g"}},
+	{Action: "pause_resume", Keys: []string{"space"}},
+	{Action: "quit", Keys: []string{"q"}},
+	{Action: "detail.close", Keys: []string{"esc", "q"}},
+}
+
+// press feeds keys and returns the actions completed
+func press(k *Keymap, mode string, keys ...string) []string {
+	var got []string
+	for _, key := range keys {
+		if action, _ := k.Press(mode, key); action != "" {
+			got = append(got, action)
+		}
+	}
+	return got
+}
+
+func TestParseKeys(t *testing.T) {
+	tests := map[string][]string{
+		"j":            {"j"},
+		"gg":           {"g g"},
+		"g g":          {"g g"},
+		"ctrl+d":       {"ctrl+d"},
+		"space":        {"space"},
+		" ":            {"space"},
+		"Enter":        {"enter"},
+		"j, down":      {"j", "down"},
+		",":            {","},
+		"shift+tab":    {"shift+tab"},
+		"space f":      {"space f"},
+		"f5":           {"f5"},
+		"":             nil,
+		"ctrl+w right": {"ctrl+w right"},
+	}
+	for value, want := range tests {
+		if got := ParseKeys(value); !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseKeys(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestPress(t *testing.T) {
+	k, errs := New(defaults, map[string]string{"goto_top": "gg"})
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected errors %v", errs)
+	}
+
+	if got := press(k, "", "j", "down", " "); !reflect.DeepEqual(got, []string{"scroll_down", "scroll_down", "pause_resume"}) {
+		t.Errorf("Unexpected actions %v", got)
+	}
+	if got := press(k, "", "g", "g"); !reflect.DeepEqual(got, []string{"goto_top"}) {
+		t.Errorf("Expected gg to go to the top, got %v", got)
+	}
+	if got := press(k, "", "g", "k"); !reflect.DeepEqual(got, []string{"scroll_up"}) {
+		t.Errorf("Expected an unfinished sequence to be dropped, got %v", got)
+	}
+
+	// Counts
+	if got := press(k, "", "1", "2"); len(got) != 0 || k.Pending() != "12" {
+		t.Errorf("Expected a pending count, got %v %q", got, k.Pending())
+	}
+	if action, count := k.Press("", "j"); action != "scroll_down" || count != 12 {
+		t.Errorf("Expected 12j, got %s %d", action, count)
+	}
+	if action, count := k.Press("", "j"); count != 0 {
+		t.Errorf("Expected the count to be reset, got %s %d", action, count)
+	}
+	if got := press(k, "", "0", "j"); !reflect.DeepEqual(got, []string{"scroll_down"}) {
+		t.Errorf("Expected a leading 0 not to start a count, got %v", got)
+	}
+
+	// Modes
+	if got := press(k, "detail", "q"); !reflect.DeepEqual(got, []string{"detail.close"}) {
+		t.Errorf("Expected q to close the detail pane, got %v", got)
+	}
+	if got := press(k, "detail", "j"); len(got) != 0 {
+		t.Errorf("Expected j to be unbound in the detail mode, got %v", got)
+	}
+	press(k, "", "3")
+	if action, count := k.Press("detail", "q"); action != "detail.close" || count != 0 {
+		t.Errorf("Expected a mode change to reset the count, got %s %d", action, count)
+	}
+
+	if got := k.Keys("scroll_down"); got != "j, ↓" {
+		t.Errorf("Unexpected keys %q", got)
+	}
+	if got := k.Keys("goto_top"); got != "gg" {
+		t.Errorf("Unexpected keys %q", got)
+	}
+
+	// Overrides listing only default keys keep them all
+	k, _ = New(defaults, map[string]string{"scroll_down": "j", "scroll_up": "K"})
+	if got := press(k, "", "down", "up", "K"); !reflect.DeepEqual(got, []string{"scroll_down", "scroll_up"}) {
+		t.Errorf("Unexpected actions %v", got)
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	k, errs := New(defaults, map[string]string{
+		"quit":      "j",   // Taken by scroll_down
+		"goto_top":  "k k", // Prefixed by scroll_up
+		"jump":      "x",   // No such action
+		"scroll_up": "u",   // Fine, frees k
+	})
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	joined := strings.Join(msgs, "\n")
+	for _, want := range []string{`"j" is bound to scroll_down and quit`, `unknown action "jump"`} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("Expected an error containing %q, got:\n%s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "goto_top") {
+		t.Errorf("Expected k k not to conflict once k is rebound, got:\n%s", joined)
+	}
+
+	if got := press(k, "", "j", "q", "u", "k", "k"); !reflect.DeepEqual(got, []string{"scroll_down", "quit", "scroll_up", "goto_top"}) {
+		t.Errorf("Expected conflicting overrides to keep their defaults, got %v", got)
+	}
+
+	_, errs = New(defaults, map[string]string{"goto_top": "q q"})
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"q" is bound to quit and goto_top`) {
+		t.Errorf("Expected a prefix conflict, got %v", errs)
+	}
+}
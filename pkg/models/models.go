@@ -15,6 +15,9 @@ type LogLine struct {
 	Tokens    []Token                `json:"tokens"`    // tokens for syntax highlighting
 	Offset    int64                  `json:"offset"`    // byte offset in file when available
 	LineNum   int                    `json:"line_num"`  // line number in file
+	Format    LogFormat              `json:"format"`    // detected log format
+	Seq       uint32                 `json:"-"`         // arrival order among buffered lines
+	Arrived   time.Time              `json:"-"`         // when the tailer read the line
 }
 
 // Token represents a highlighted token in a log line
@@ -25,6 +28,13 @@ type Token struct {
 	End       int       `json:"end"`
 }
 
+// Span is the byte range [Start, End) of a line's raw text that a filter
+// term matched
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
 // TokenType defines the different types of tokens for syntax highlighting
 type TokenType string
 
@@ -41,6 +51,8 @@ const (
 	TokenString     TokenType = "string"
 	TokenJSON       TokenType = "json"
 	TokenKeyword    TokenType = "keyword"
+	TokenMatch      TokenType = "match"
+	TokenFocusMatch TokenType = "match_focus"
 )
 
 // LogLevel represents normalized log levels
@@ -55,6 +67,21 @@ const (
 	LevelTrace LogLevel = "TRACE"
 )
 
+// LogFormat identifies the detected format of a log line
+type LogFormat string
+
+const (
+	FormatText  LogFormat = "text"
+	FormatJSON  LogFormat = "json"
+	FormatYAML  LogFormat = "yaml"
+	FormatKlog  LogFormat = "klog"
+	FormatGoLog LogFormat = "golog"
+	FormatCEF   LogFormat = "cef"
+	FormatLEEF  LogFormat = "leef"
+	FormatCSV   LogFormat = "csv"
+	FormatW3C   LogFormat = "w3c"
+)
+
 // Bookmark represents a saved position in the log
 type Bookmark struct {
 	ID        string    `json:"id"`
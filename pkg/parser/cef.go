@@ -0,0 +1,302 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// cefHeaderFields names the pipe-delimited CEF header fields after the version
+var cefHeaderFields = []string{"device_vendor", "device_product", "device_version", "signature_id", "name", "severity"}
+
+// leefHeaderFields names the pipe-delimited LEEF header fields after the version
+var leefHeaderFields = []string{"vendor", "product", "product_version", "event_id"}
+
+// securityTimestampFields are extension keys holding the event time
+var securityTimestampFields = []string{"rt", "start", "devTime", "end"}
+
+// securityTimestampLayouts are the date formats used by CEF and LEEF producers
+var securityTimestampLayouts = []string{
+	"Jan 02 2006 15:04:05.000 MST",
+	"Jan 02 2006 15:04:05 MST",
+	"Jan 02 2006 15:04:05.000",
+	"Jan 02 2006 15:04:05",
+	"Jan 2 2006 15:04:05",
+	"Jan 02 15:04:05.000 MST",
+	"Jan 02 15:04:05",
+	time.RFC3339Nano,
+	time.RFC3339,
+}
+
+// tryParseCEF attempts to parse an ArcSight Common Event Format record,
+// optionally preceded by a syslog header
+func (p *LogParser) tryParseCEF(line *models.LogLine) bool {
+	start := strings.Index(line.Raw, "CEF:")
+	if start == -1 {
+		return false
+	}
+
+	header, extension, ok := splitSecurityHeader(line.Raw[start+4:], len(cefHeaderFields)+1)
+	if !ok {
+		return false
+	}
+
+	parsed := map[string]interface{}{
+		"cef_version": header[0],
+	}
+	for i, name := range cefHeaderFields {
+		parsed[name] = header[i+1]
+	}
+	for key, val := range parseSecurityExtension(extension, ' ') {
+		parsed[key] = val
+	}
+
+	line.Parsed = parsed
+	line.Format = models.FormatCEF
+	if level, ok := cefSeverityLevel(header[len(header)-1]); ok {
+		line.Level = string(level)
+	}
+	if timestamp, ok := securityTimestamp(parsed, ""); ok {
+		line.Timestamp = timestamp
+	}
+
+	return true
+}
+
+// tryParseLEEF attempts to parse an IBM QRadar Log Event Extended Format record
+func (p *LogParser) tryParseLEEF(line *models.LogLine) bool {
+	start := strings.Index(line.Raw, "LEEF:")
+	if start == -1 {
+		return false
+	}
+	rest := line.Raw[start+5:]
+
+	// LEEF 2.0 adds an optional delimiter field after the event id
+	version := rest
+	if idx := strings.IndexByte(rest, '|'); idx != -1 {
+		version = rest[:idx]
+	}
+	fieldCount := len(leefHeaderFields) + 1
+	if strings.HasPrefix(version, "2") {
+		fieldCount++
+	}
+
+	header, extension, ok := splitSecurityHeader(rest, fieldCount)
+	if !ok {
+		return false
+	}
+
+	parsed := map[string]interface{}{
+		"leef_version": header[0],
+	}
+	for i, name := range leefHeaderFields {
+		parsed[name] = header[i+1]
+	}
+
+	delimiter := byte('\t')
+	if len(header) > len(leefHeaderFields)+1 {
+		if d := leefDelimiter(header[len(header)-1]); d != 0 {
+			delimiter = d
+		}
+	}
+	for key, val := range parseSecurityExtension(extension, delimiter) {
+		parsed[key] = val
+	}
+
+	line.Parsed = parsed
+	line.Format = models.FormatLEEF
+	if sev, ok := parsed["sev"].(string); ok {
+		if level, ok := cefSeverityLevel(sev); ok {
+			line.Level = string(level)
+		}
+	}
+	format, _ := parsed["devTimeFormat"].(string)
+	if timestamp, ok := securityTimestamp(parsed, format); ok {
+		line.Timestamp = timestamp
+	}
+
+	return true
+}
+
+// splitSecurityHeader splits count pipe-delimited header fields, honouring
+// \| and \\ escapes, and returns the remaining extension text
+func splitSecurityHeader(s string, count int) ([]string, string, bool) {
+	fields := make([]string, 0, count)
+	var current strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\') {
+			current.WriteByte(s[i+1])
+			i++
+			continue
+		}
+		if ch == '|' {
+			fields = append(fields, current.String())
+			current.Reset()
+			if len(fields) == count {
+				return fields, s[i+1:], true
+			}
+			continue
+		}
+		current.WriteByte(ch)
+	}
+
+	return nil, "", false
+}
+
+// parseSecurityExtension parses the key=value extension of a CEF or LEEF
+// record. With a space delimiter (CEF) values may contain spaces; a value
+// ends where the next key= begins. \=, \\, \n and \r escapes are decoded.
+func parseSecurityExtension(s string, delimiter byte) map[string]string {
+	result := make(map[string]string)
+	s = strings.TrimRight(s, "\r\n")
+
+	type pair struct {
+		keyStart, valueStart int
+	}
+	var pairs []pair
+
+	// Locate every unescaped key= boundary
+	for i := 0; i < len(s); i++ {
+		if s[i] != '=' || (i > 0 && s[i-1] == '\\') {
+			continue
+		}
+		keyStart := i
+		for keyStart > 0 && isSecurityKeyChar(s[keyStart-1]) {
+			keyStart--
+		}
+		if keyStart == i || (keyStart > 0 && s[keyStart-1] != delimiter) {
+			continue
+		}
+		pairs = append(pairs, pair{keyStart: keyStart, valueStart: i + 1})
+	}
+
+	for i, pr := range pairs {
+		end := len(s)
+		if i+1 < len(pairs) {
+			end = pairs[i+1].keyStart - 1
+		}
+		if end < pr.valueStart {
+			end = pr.valueStart
+		}
+		key := s[pr.keyStart : pr.valueStart-1]
+		result[key] = unescapeSecurityValue(strings.TrimRight(s[pr.valueStart:end], " "))
+	}
+
+	return result
+}
+
+// isSecurityKeyChar reports whether ch may appear in a CEF/LEEF extension key
+func isSecurityKeyChar(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch == '_' || ch == '.'
+}
+
+// unescapeSecurityValue decodes CEF extension escapes
+func unescapeSecurityValue(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			switch s[i+1] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i+1])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// leefDelimiter decodes the LEEF 2.0 delimiter field (a character or hex code)
+func leefDelimiter(s string) byte {
+	if len(s) == 1 {
+		return s[0]
+	}
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0x"), "x")
+	if code, err := strconv.ParseUint(s, 16, 8); err == nil {
+		return byte(code)
+	}
+	return 0
+}
+
+// cefSeverityLevel maps a CEF/LEEF severity (0-10 or Low..Very-High) onto a level
+func cefSeverityLevel(severity string) (models.LogLevel, bool) {
+	severity = strings.TrimSpace(severity)
+	if n, err := strconv.Atoi(severity); err == nil {
+		switch {
+		case n < 0 || n > 10:
+			return "", false
+		case n <= 3:
+			return models.LevelInfo, true
+		case n <= 6:
+			return models.LevelWarn, true
+		case n <= 8:
+			return models.LevelError, true
+		default:
+			return models.LevelFatal, true
+		}
+	}
+
+	switch strings.ToLower(severity) {
+	case "unknown", "low":
+		return models.LevelInfo, true
+	case "medium":
+		return models.LevelWarn, true
+	case "high":
+		return models.LevelError, true
+	case "very-high", "very high":
+		return models.LevelFatal, true
+	}
+	return "", false
+}
+
+// securityTimestamp extracts the event time from rt/start/devTime extension
+// keys, which hold epoch milliseconds or one of the CEF date formats
+func securityTimestamp(parsed map[string]interface{}, layout string) (time.Time, bool) {
+	for _, field := range securityTimestampFields {
+		val, ok := parsed[field].(string)
+		if !ok || val == "" {
+			continue
+		}
+		if ms, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return time.UnixMilli(ms), true
+		}
+		if layout != "" {
+			if t, err := parseJavaLayout(layout, val); err == nil {
+				return t, true
+			}
+		}
+		for _, candidate := range securityTimestampLayouts {
+			if t, err := time.Parse(candidate, val); err == nil {
+				if t.Year() == 0 {
+					t = inferYear(int(t.Month()), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond())
+				}
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// javaLayoutReplacer converts the common Java SimpleDateFormat tokens used in
+// LEEF devTimeFormat into Go layout tokens
+var javaLayoutReplacer = strings.NewReplacer(
+	"yyyy", "2006", "MMM", "Jan", "MM", "01", "dd", "02",
+	"HH", "15", "mm", "04", "ss", "05", "SSS", "000", "z", "MST", "Z", "-0700",
+)
+
+// parseJavaLayout parses a value using a Java-style date pattern
+func parseJavaLayout(javaLayout, value string) (time.Time, error) {
+	return time.Parse(javaLayoutReplacer.Replace(javaLayout), value)
+}
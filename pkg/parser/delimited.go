@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"encoding/csv"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// delimitedSource tracks the header of a CSV/TSV or W3C extended log source.
+// It is not changed once stored, so rows are parsed without locking.
+type delimitedSource struct {
+	delimiter rune
+	header    []string
+	w3c       bool
+}
+
+// delimitedExtensions maps file extensions to their field delimiter
+var delimitedExtensions = map[string]rune{
+	".csv": ',',
+	".tsv": '\t',
+}
+
+// RegisterDelimitedSource declares that a source holds delimited rows with a
+// header line, for sources whose extension does not reveal it
+func (p *LogParser) RegisterDelimitedSource(source string, delimiter rune) {
+	p.delimited.Store(source, &delimitedSource{delimiter: delimiter})
+}
+
+// tryParseDelimited parses CSV/TSV rows against the source header and W3C
+// extended log rows against the latest #Fields: directive of the source
+func (p *LogParser) tryParseDelimited(line *models.LogLine) bool {
+	var state *delimitedSource
+	if stored, ok := p.delimited.Load(line.Source); ok {
+		state = stored.(*delimitedSource)
+	}
+
+	// W3C directives may appear in any source and can change mid-file
+	if strings.HasPrefix(line.Raw, "#") && (state == nil || state.w3c) {
+		if fields, ok := strings.CutPrefix(line.Raw, "#Fields:"); ok {
+			p.delimited.Store(line.Source, &delimitedSource{delimiter: ' ', header: strings.Fields(fields), w3c: true})
+			line.Format = models.FormatW3C
+			return true
+		}
+		if state != nil || isW3CDirective(line.Raw) {
+			line.Format = models.FormatW3C
+			return true
+		}
+	}
+
+	if state == nil {
+		delimiter, ok := delimitedExtensions[strings.ToLower(filepath.Ext(line.Source))]
+		if !ok {
+			return false
+		}
+		state = &delimitedSource{delimiter: delimiter}
+	}
+
+	record, ok := splitDelimitedRow(line.Raw, state.delimiter, state.w3c)
+	if !ok {
+		return false
+	}
+
+	format := models.FormatCSV
+	if state.w3c {
+		format = models.FormatW3C
+	}
+
+	// The first row is the header; a repeated header (e.g. after rotation)
+	// simply replaces it
+	if state.header == nil || (!state.w3c && equalRecords(record, state.header)) {
+		p.delimited.Store(line.Source, &delimitedSource{delimiter: state.delimiter, header: record, w3c: state.w3c})
+		line.Format = format
+		return true
+	}
+
+	parsed := make(map[string]interface{}, len(record))
+	for i, value := range record {
+		name := "column_" + strconv.Itoa(i+1)
+		if i < len(state.header) && state.header[i] != "" {
+			name = state.header[i]
+		}
+		if state.w3c && value == "-" {
+			continue
+		}
+		parsed[name] = value
+	}
+
+	line.Parsed = parsed
+	line.Format = format
+
+	if state.w3c {
+		p.applyW3CFields(line, parsed)
+		return true
+	}
+
+	if timestamp, ok := p.extractTimestampFromParsed(parsed); ok {
+		line.Timestamp = timestamp
+	}
+	if level, ok := p.extractLevelFromParsed(parsed); ok {
+		line.Level = string(level)
+	}
+
+	return true
+}
+
+// splitDelimitedRow splits a single row following RFC 4180 quoting rules.
+// Quoted fields spanning several physical lines are not supported.
+func splitDelimitedRow(raw string, delimiter rune, w3c bool) ([]string, bool) {
+	reader := csv.NewReader(strings.NewReader(raw))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = w3c
+	if delimiter == ' ' {
+		reader.TrimLeadingSpace = true
+	}
+
+	record, err := reader.Read()
+	if err != nil {
+		return nil, false
+	}
+	return record, true
+}
+
+// isW3CDirective reports whether a comment line is a W3C extended log directive
+func isW3CDirective(raw string) bool {
+	for _, directive := range []string{"#Software:", "#Version:", "#Date:", "#Remark:", "#Start-Date:", "#End-Date:"} {
+		if strings.HasPrefix(raw, directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyW3CFields derives timestamp and level from W3C extended log fields
+func (p *LogParser) applyW3CFields(line *models.LogLine, parsed map[string]interface{}) {
+	date, _ := parsed["date"].(string)
+	clock, _ := parsed["time"].(string)
+	if date != "" && clock != "" {
+		if t, err := time.Parse("2006-01-02 15:04:05", date+" "+clock); err == nil {
+			line.Timestamp = t
+		}
+	}
+
+	status, _ := parsed["sc-status"].(string)
+	if code, err := strconv.Atoi(status); err == nil {
+		switch {
+		case code >= 500:
+			line.Level = string(models.LevelError)
+		case code >= 400:
+			line.Level = string(models.LevelWarn)
+		default:
+			line.Level = string(models.LevelInfo)
+		}
+	}
+}
+
+// equalRecords reports whether two records hold the same fields
+func equalRecords(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
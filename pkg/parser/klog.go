@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+var (
+	// klogPattern matches klog/glog headers: Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg
+	klogPattern = regexp.MustCompile(`^([IWEF])(\d{2})(\d{2}) (\d{2}):(\d{2}):(\d{2})\.(\d{6})\s+(\d+) ([^\s:\]]+):(\d+)\] ?(.*)$`)
+
+	// goLogPattern matches the Go standard library log package output with
+	// optional microseconds and Lshortfile/Llongfile prefixes
+	goLogPattern = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d{1,6})?) (?:(\S+\.go):(\d+): )?(.*)$`)
+
+	// klogKeyValueStart finds the start of a trailing key=value section
+	klogKeyValueStart = regexp.MustCompile(`\s[A-Za-z_][\w.\-/]*=`)
+
+	// now is the clock used for year inference
+	now = time.Now
+)
+
+// klogSeverities maps klog severity letters to log levels
+var klogSeverities = map[string]models.LogLevel{
+	"I": models.LevelInfo,
+	"W": models.LevelWarn,
+	"E": models.LevelError,
+	"F": models.LevelFatal,
+}
+
+// tryParseKlog attempts to parse the line as a Kubernetes klog/glog line
+func (p *LogParser) tryParseKlog(line *models.LogLine) bool {
+	matches := klogPattern.FindStringSubmatch(line.Raw)
+	if matches == nil {
+		return false
+	}
+
+	month, _ := strconv.Atoi(matches[2])
+	day, _ := strconv.Atoi(matches[3])
+	hour, _ := strconv.Atoi(matches[4])
+	minute, _ := strconv.Atoi(matches[5])
+	second, _ := strconv.Atoi(matches[6])
+	micros, _ := strconv.Atoi(matches[7])
+	threadID, _ := strconv.Atoi(matches[8])
+	lineNum, _ := strconv.Atoi(matches[10])
+
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return false
+	}
+
+	parsed := map[string]interface{}{
+		"severity":  matches[1],
+		"thread_id": threadID,
+		"file":      matches[9],
+		"line":      lineNum,
+	}
+
+	msg, fields := splitKlogMessage(matches[11])
+	parsed["msg"] = msg
+	for key, val := range fields {
+		if _, reserved := parsed[key]; !reserved {
+			parsed[key] = val
+		}
+	}
+
+	line.Parsed = p.decodeNested(parsed, p.maxDecodeDepth)
+	line.Level = string(klogSeverities[matches[1]])
+	line.Timestamp = inferYear(month, day, hour, minute, second, micros*1000)
+	line.Format = models.FormatKlog
+
+	return true
+}
+
+// splitKlogMessage splits a klog message into its text and the structured
+// key="value" suffix written by klog.InfoS and friends
+func splitKlogMessage(text string) (string, map[string]interface{}) {
+	text = strings.TrimSpace(text)
+
+	// Structured logging quotes the message: "Syncing" pod="ns/name"
+	if strings.HasPrefix(text, `"`) {
+		end := 1
+		for end < len(text) && text[end] != '"' {
+			if text[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end < len(text) {
+			if msg, err := strconv.Unquote(text[:end+1]); err == nil {
+				rest := strings.TrimSpace(text[end+1:])
+				if rest == "" {
+					return msg, nil
+				}
+				if fields, ok := parseLogfmtPairs(rest, 1); ok {
+					return msg, fields
+				}
+			}
+		}
+	}
+
+	// Unquoted message followed by key=value pairs
+	for _, loc := range klogKeyValueStart.FindAllStringIndex(text, -1) {
+		if fields, ok := parseLogfmtPairs(text[loc[0]:], 1); ok {
+			return strings.TrimSpace(text[:loc[0]]), fields
+		}
+	}
+
+	return text, nil
+}
+
+// inferYear builds a timestamp for a date logged without a year. The current
+// year is assumed unless that would place the entry more than a day in the
+// future, which happens when December logs are read in January.
+func inferYear(month, day, hour, minute, second, nanos int) time.Time {
+	current := now()
+	t := time.Date(current.Year(), time.Month(month), day, hour, minute, second, nanos, time.Local)
+	if t.After(current.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// tryParseGoLog attempts to parse the line as Go standard library log output
+func (p *LogParser) tryParseGoLog(line *models.LogLine) bool {
+	matches := goLogPattern.FindStringSubmatch(line.Raw)
+	if matches == nil {
+		return false
+	}
+
+	layout := "2006/01/02 15:04:05"
+	if strings.Contains(matches[1], ".") {
+		layout += "." + strings.Repeat("0", len(matches[1])-len(layout)-1)
+	}
+	timestamp, err := time.ParseInLocation(layout, matches[1], time.Local)
+	if err != nil {
+		return false
+	}
+
+	parsed := map[string]interface{}{
+		"msg": matches[4],
+	}
+	if matches[2] != "" {
+		lineNum, _ := strconv.Atoi(matches[3])
+		parsed["file"] = matches[2]
+		parsed["line"] = lineNum
+	}
+
+	line.Parsed = parsed
+	line.Timestamp = timestamp
+	line.Format = models.FormatGoLog
+	if level := p.extractLevel(matches[4]); level != "" {
+		line.Level = string(level)
+	}
+
+	return true
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// parseLogfmt parses a logfmt string (key=value key2="quoted value") into a map.
+// It only succeeds when the whole string consists of key=value pairs and at
+// least two pairs are present, so free text containing a stray '=' is rejected.
+func parseLogfmt(s string) (map[string]interface{}, bool) {
+	return parseLogfmtPairs(s, 2)
+}
+
+// parseLogfmtPairs parses logfmt requiring at least minPairs pairs
+func parseLogfmtPairs(s string, minPairs int) (map[string]interface{}, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || !strings.Contains(s, "=") {
+		return nil, false
+	}
+
+	result := make(map[string]interface{})
+	pos := 0
+
+	for pos < len(s) {
+		// Skip separating whitespace
+		for pos < len(s) && (s[pos] == ' ' || s[pos] == '\t') {
+			pos++
+		}
+		if pos >= len(s) {
+			break
+		}
+
+		// Read key
+		keyStart := pos
+		for pos < len(s) && isLogfmtKeyChar(s[pos]) {
+			pos++
+		}
+		if pos == keyStart || pos >= len(s) || s[pos] != '=' {
+			return nil, false
+		}
+		key := s[keyStart:pos]
+		pos++ // consume '='
+
+		// Read value
+		if pos < len(s) && s[pos] == '"' {
+			end := pos + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, false
+			}
+			value, err := strconv.Unquote(s[pos : end+1])
+			if err != nil {
+				return nil, false
+			}
+			result[key] = value
+			pos = end + 1
+			if pos < len(s) && s[pos] != ' ' && s[pos] != '\t' {
+				return nil, false
+			}
+			continue
+		}
+
+		valueStart := pos
+		for pos < len(s) && s[pos] != ' ' && s[pos] != '\t' {
+			pos++
+		}
+		result[key] = s[valueStart:pos]
+	}
+
+	if len(result) < minPairs {
+		return nil, false
+	}
+
+	return result, true
+}
+
+// isLogfmtKeyChar reports whether ch may appear in a logfmt key
+func isLogfmtKeyChar(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') ||
+		ch == '_' || ch == '-' || ch == '.' || ch == '/' || ch == '@'
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/loganalyzer/traceace/pkg/models"
@@ -15,6 +16,9 @@ type LogParser struct {
 	timestampPatterns []*regexp.Regexp
 	levelPatterns     []*regexp.Regexp
 	levelMapping      map[string]models.LogLevel
+	maxDecodeDepth    int // nesting depth for decoding JSON/logfmt inside string fields (0 disables)
+
+	delimited sync.Map // source -> *delimitedSource, replaced when its header changes
 }
 
 // New creates a new LogParser
@@ -26,17 +30,41 @@ func New() *LogParser {
 	}
 }
 
+// SetMaxDecodeDepth enables recursive decoding of string fields that contain
+// JSON or logfmt, descending at most depth levels. A depth of 0 disables it.
+func (p *LogParser) SetMaxDecodeDepth(depth int) {
+	if depth < 0 {
+		depth = 0
+	}
+	p.maxDecodeDepth = depth
+}
+
 // ParseLogLine parses a raw log line and extracts structured information
 func (p *LogParser) ParseLogLine(line *models.LogLine) {
 	if line == nil || line.Raw == "" {
 		return
 	}
 
+	// Try header-aware CSV/TSV and W3C extended formats
+	if p.tryParseDelimited(line) {
+		return
+	}
+
 	// Try to parse as JSON
 	if p.tryParseJSON(line) {
 		return
 	}
 
+	// Try Kubernetes klog/glog and Go standard library log formats
+	if p.tryParseKlog(line) || p.tryParseGoLog(line) {
+		return
+	}
+
+	// Try CEF and LEEF security event formats
+	if p.tryParseCEF(line) || p.tryParseLEEF(line) {
+		return
+	}
+
 	// Try to parse as YAML
 	if p.tryParseYAML(line) {
 		return
@@ -58,7 +86,8 @@ func (p *LogParser) tryParseJSON(line *models.LogLine) bool {
 		return false
 	}
 
-	line.Parsed = parsed
+	line.Parsed = p.decodeNested(parsed, p.maxDecodeDepth)
+	line.Format = models.FormatJSON
 
 	// Extract common fields
 	if timestamp, ok := p.extractTimestampFromParsed(parsed); ok {
@@ -100,7 +129,8 @@ func (p *LogParser) tryParseYAML(line *models.LogLine) bool {
 		return false
 	}
 
-	line.Parsed = parsed
+	line.Parsed = p.decodeNested(parsed, p.maxDecodeDepth)
+	line.Format = models.FormatYAML
 
 	// Extract common fields
 	if timestamp, ok := p.extractTimestampFromParsed(parsed); ok {
@@ -114,8 +144,65 @@ func (p *LogParser) tryParseYAML(line *models.LogLine) bool {
 	return true
 }
 
+// decodeNested replaces string values that themselves contain JSON or logfmt
+// with their decoded structure, recursing at most depth levels
+func (p *LogParser) decodeNested(parsed map[string]interface{}, depth int) map[string]interface{} {
+	if depth <= 0 {
+		return parsed
+	}
+
+	for key, val := range parsed {
+		parsed[key] = p.decodeNestedValue(val, depth)
+	}
+	return parsed
+}
+
+// decodeNestedValue decodes a single value for decodeNested
+func (p *LogParser) decodeNestedValue(val interface{}, depth int) interface{} {
+	switch v := val.(type) {
+	case string:
+		if decoded, ok := decodeEmbedded(v); ok {
+			return p.decodeNestedValue(decoded, depth-1)
+		}
+	case map[string]interface{}:
+		if depth > 0 {
+			return p.decodeNested(v, depth)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = p.decodeNestedValue(v[i], depth)
+		}
+	}
+	return val
+}
+
+// decodeEmbedded decodes a string holding a JSON object/array or logfmt pairs
+func decodeEmbedded(s string) (interface{}, bool) {
+	trimmed := strings.TrimSpace(s)
+	if trimmed == "" {
+		return nil, false
+	}
+
+	if (strings.HasPrefix(trimmed, "{") && strings.HasSuffix(trimmed, "}")) ||
+		(strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]")) {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(trimmed), &decoded); err == nil {
+			return decoded, true
+		}
+		return nil, false
+	}
+
+	if fields, ok := parseLogfmt(trimmed); ok {
+		return fields, true
+	}
+
+	return nil, false
+}
+
 // parseUnstructured parses unstructured text and extracts common patterns
 func (p *LogParser) parseUnstructured(line *models.LogLine) {
+	line.Format = models.FormatText
+
 	// Extract timestamp
 	if line.Timestamp.IsZero() {
 		if timestamp := p.extractTimestamp(line.Raw); !timestamp.IsZero() {
@@ -167,6 +254,24 @@ func (p *LogParser) extractLevelFromParsed(parsed map[string]interface{}) (model
 	return "", false
 }
 
+// TimestampSource describes where a line's timestamp came from: the parsed
+// field holding it, or the line text. It returns "" when neither holds it,
+// e.g. for the arrival time of a line without a timestamp.
+func (p *LogParser) TimestampSource(line *models.LogLine) string {
+	if line.Timestamp.IsZero() {
+		return ""
+	}
+	for _, key := range sortedKeys(line.Parsed) {
+		if timestamp, ok := p.parseTimestampValue(line.Parsed[key]); ok && timestamp.Equal(line.Timestamp) {
+			return "field " + key
+		}
+	}
+	if timestamp := p.extractTimestamp(line.Raw); !timestamp.IsZero() && timestamp.Equal(line.Timestamp) {
+		return "line text"
+	}
+	return ""
+}
+
 // extractTimestamp extracts timestamp from raw text using regex patterns
 func (p *LogParser) extractTimestamp(text string) time.Time {
 	for _, pattern := range p.timestampPatterns {
@@ -286,34 +391,22 @@ func createLevelMapping() map[string]models.LogLevel {
 	}
 }
 
-// GetParsedField retrieves a field from parsed structured data
+// GetParsedField retrieves a field from parsed structured data using the
+// FieldPath syntax. For wildcard paths the first matching value is returned.
 func (p *LogParser) GetParsedField(line *models.LogLine, fieldPath string) interface{} {
-	if line.Parsed == nil {
+	values := p.GetParsedFieldValues(line, fieldPath)
+	if len(values) == 0 {
 		return nil
 	}
+	return values[0]
+}
 
-	// Split field path by dots for nested access
-	parts := strings.Split(fieldPath, ".")
-	current := line.Parsed
-
-	for i, part := range parts {
-		if val, exists := current[part]; exists {
-			if i == len(parts)-1 {
-				// Last part, return the value
-				return val
-			}
-			// Continue traversing
-			if nested, ok := val.(map[string]interface{}); ok {
-				current = nested
-			} else {
-				return nil
-			}
-		} else {
-			return nil
-		}
+// GetParsedFieldValues retrieves every value a field path resolves to
+func (p *LogParser) GetParsedFieldValues(line *models.LogLine, fieldPath string) []interface{} {
+	if line == nil || line.Parsed == nil {
+		return nil
 	}
-
-	return nil
+	return LookupField(line.Parsed, fieldPath)
 }
 
 // IsStructured returns whether the log line contains structured data
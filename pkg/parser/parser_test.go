@@ -0,0 +1,322 @@
+package parser
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+func TestNestedJSONDecoding(t *testing.T) {
+	p := New()
+	p.SetMaxDecodeDepth(2)
+
+	line := &models.LogLine{Raw: `{"level":"info","msg":"{\"event\":\"login\",\"uid\":7}"}`}
+	p.ParseLogLine(line)
+
+	if got := p.GetParsedField(line, "msg.event"); got != "login" {
+		t.Errorf("Expected msg.event 'login', got %v", got)
+	}
+
+	if got := p.GetParsedField(line, "msg.uid"); got != float64(7) {
+		t.Errorf("Expected msg.uid 7, got %v", got)
+	}
+}
+
+func TestNestedLogfmtDecoding(t *testing.T) {
+	p := New()
+	p.SetMaxDecodeDepth(1)
+
+	line := &models.LogLine{Raw: `{"level":"warn","detail":"user=alice action=\"delete file\""}`}
+	p.ParseLogLine(line)
+
+	if got := p.GetParsedField(line, "detail.action"); got != "delete file" {
+		t.Errorf("Expected detail.action 'delete file', got %v", got)
+	}
+}
+
+func TestNestedDecodingDepthLimit(t *testing.T) {
+	p := New()
+	p.SetMaxDecodeDepth(1)
+
+	line := &models.LogLine{Raw: `{"outer":"{\"inner\":\"{\\\"deep\\\":1}\"}"}`}
+	p.ParseLogLine(line)
+
+	if got := p.GetParsedField(line, "outer.inner"); got != `{"deep":1}` {
+		t.Errorf("Expected outer.inner to stay encoded at depth 1, got %v", got)
+	}
+}
+
+func TestNestedDecodingDisabled(t *testing.T) {
+	p := New()
+
+	line := &models.LogLine{Raw: `{"msg":"{\"event\":\"login\"}"}`}
+	p.ParseLogLine(line)
+
+	if got := p.GetParsedField(line, "msg.event"); got != nil {
+		t.Errorf("Expected no nested decoding by default, got %v", got)
+	}
+}
+
+func TestFieldPathLookup(t *testing.T) {
+	data := map[string]interface{}{
+		"errors": []interface{}{
+			map[string]interface{}{"code": "E1"},
+			map[string]interface{}{"code": "E2"},
+		},
+		"tags":             []interface{}{"api", "prod"},
+		"http.status_code": float64(503),
+		"resource": map[string]interface{}{
+			"service.name": "checkout",
+		},
+	}
+
+	tests := []struct {
+		path     string
+		expected []string
+	}{
+		{"errors[0].code", []string{"E1"}},
+		{"errors[-1].code", []string{"E2"}},
+		{"errors[*].code", []string{"E1", "E2"}},
+		{"errors.*.code", []string{"E1", "E2"}},
+		{"tags.*", []string{"api", "prod"}},
+		{"http.status_code", []string{"503"}},
+		{`"http.status_code"`, []string{"503"}},
+		{`http\.status_code`, []string{"503"}},
+		{"resource.service.name", []string{"checkout"}},
+		{`resource['service.name']`, []string{"checkout"}},
+		{"errors[5].code", nil},
+		{"missing.key", nil},
+	}
+
+	for _, tt := range tests {
+		values := LookupField(data, tt.path)
+		if len(values) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.expected, values)
+			continue
+		}
+		for i, val := range values {
+			if FormatValue(val) != tt.expected[i] {
+				t.Errorf("%s: expected %v, got %v", tt.path, tt.expected, values)
+				break
+			}
+		}
+	}
+}
+
+func TestAppendPath(t *testing.T) {
+	data := map[string]interface{}{
+		"http.status_code": float64(503),
+		"errors": []interface{}{
+			map[string]interface{}{"error code": "E1"},
+		},
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{AppendKey("", "http.status_code"), `["http.status_code"]`},
+		{AppendKey(AppendIndex(AppendKey("", "errors"), 0), "error code"), `errors[0]["error code"]`},
+	}
+
+	for _, tt := range tests {
+		if tt.path != tt.expected {
+			t.Errorf("Expected path %s, got %s", tt.expected, tt.path)
+		}
+		if values := LookupField(data, tt.path); len(values) != 1 {
+			t.Errorf("%s: expected one value, got %v", tt.path, values)
+		}
+	}
+}
+
+func TestParsePathErrors(t *testing.T) {
+	invalid := []string{"", "a..b", "a.", "errors[x]", "errors[0", `"unterminated`}
+	for _, path := range invalid {
+		if _, err := ParsePath(path); err == nil {
+			t.Errorf("Expected error for path %q", path)
+		}
+	}
+}
+
+func TestPathCacheBounded(t *testing.T) {
+	for i := 0; i < 3*maxCachedPaths; i++ {
+		if _, err := ParsePath("field_" + strconv.Itoa(i)); err != nil {
+			t.Fatalf("ParsePath failed: %v", err)
+		}
+	}
+	if len(pathCache) > maxCachedPaths {
+		t.Errorf("Expected at most %d cached paths, got %d", maxCachedPaths, len(pathCache))
+	}
+}
+
+func TestKlogParsing(t *testing.T) {
+	p := New()
+
+	line := &models.LogLine{Raw: `E0115 14:30:22.123456   12345 controller.go:42] "Sync failed" pod="kube-system/coredns" attempt=3`}
+	p.ParseLogLine(line)
+
+	if line.Format != models.FormatKlog {
+		t.Fatalf("Expected klog format, got %q", line.Format)
+	}
+	if line.Level != string(models.LevelError) {
+		t.Errorf("Expected level ERROR, got %q", line.Level)
+	}
+	if line.Timestamp.Month() != 1 || line.Timestamp.Day() != 15 || line.Timestamp.Nanosecond() != 123456000 {
+		t.Errorf("Unexpected timestamp %v", line.Timestamp)
+	}
+
+	expected := map[string]interface{}{
+		"thread_id": 12345,
+		"file":      "controller.go",
+		"line":      42,
+		"msg":       "Sync failed",
+		"pod":       "kube-system/coredns",
+		"attempt":   "3",
+	}
+	for key, want := range expected {
+		if got := line.Parsed[key]; got != want {
+			t.Errorf("Expected %s=%v, got %v", key, want, got)
+		}
+	}
+}
+
+func TestKlogYearInference(t *testing.T) {
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local) }
+
+	p := New()
+	line := &models.LogLine{Raw: `I1231 23:59:59.000001    7 main.go:10] Year end`}
+	p.ParseLogLine(line)
+
+	if line.Timestamp.Year() != 2023 {
+		t.Errorf("Expected December entry to be placed in 2023, got %v", line.Timestamp)
+	}
+	if line.Parsed["msg"] != "Year end" {
+		t.Errorf("Expected msg 'Year end', got %v", line.Parsed["msg"])
+	}
+}
+
+func TestGoLogParsing(t *testing.T) {
+	p := New()
+
+	line := &models.LogLine{Raw: `2024/01/15 14:30:22.123456 server.go:88: ERROR listener closed`}
+	p.ParseLogLine(line)
+
+	if line.Format != models.FormatGoLog {
+		t.Fatalf("Expected golog format, got %q", line.Format)
+	}
+	if line.Level != string(models.LevelError) {
+		t.Errorf("Expected level ERROR, got %q", line.Level)
+	}
+	if line.Parsed["file"] != "server.go" || line.Parsed["line"] != 88 {
+		t.Errorf("Unexpected file/line %v:%v", line.Parsed["file"], line.Parsed["line"])
+	}
+	if line.Timestamp.Nanosecond() != 123456000 {
+		t.Errorf("Unexpected timestamp %v", line.Timestamp)
+	}
+}
+
+func TestCEFParsing(t *testing.T) {
+	p := New()
+
+	line := &models.LogLine{Raw: `Jan 15 14:30:22 fw01 CEF:0|Palo Alto|PAN-OS|10.1|threat|Blocked request \| policy|8|src=10.0.0.5 dst=192.168.1.20 act=blocked msg=Denied by rule a\=b rt=1705329022000`}
+	p.ParseLogLine(line)
+
+	if line.Format != models.FormatCEF {
+		t.Fatalf("Expected cef format, got %q", line.Format)
+	}
+	if line.Level != string(models.LevelError) {
+		t.Errorf("Expected severity 8 to map to ERROR, got %q", line.Level)
+	}
+	if !line.Timestamp.Equal(time.UnixMilli(1705329022000)) {
+		t.Errorf("Expected rt timestamp, got %v", line.Timestamp)
+	}
+
+	expected := map[string]string{
+		"device_vendor": "Palo Alto",
+		"name":          "Blocked request | policy",
+		"src":           "10.0.0.5",
+		"act":           "blocked",
+		"msg":           "Denied by rule a=b",
+	}
+	for key, want := range expected {
+		if got := line.Parsed[key]; got != want {
+			t.Errorf("Expected %s=%q, got %v", key, want, got)
+		}
+	}
+}
+
+func TestLEEFParsing(t *testing.T) {
+	p := New()
+
+	line := &models.LogLine{Raw: "LEEF:2.0|IBM|QRadar|7.5|LoginFailed|^|src=10.0.0.5^usrName=bob smith^sev=5^devTimeFormat=yyyy-MM-dd HH:mm:ss^devTime=2024-01-15 14:30:22"}
+	p.ParseLogLine(line)
+
+	if line.Format != models.FormatLEEF {
+		t.Fatalf("Expected leef format, got %q", line.Format)
+	}
+	if line.Parsed["usrName"] != "bob smith" || line.Parsed["event_id"] != "LoginFailed" {
+		t.Errorf("Unexpected parsed fields %v", line.Parsed)
+	}
+	if line.Level != string(models.LevelWarn) {
+		t.Errorf("Expected sev 5 to map to WARN, got %q", line.Level)
+	}
+	if line.Timestamp.Hour() != 14 || line.Timestamp.Year() != 2024 {
+		t.Errorf("Expected devTime timestamp, got %v", line.Timestamp)
+	}
+}
+
+func TestCSVHeaderParsing(t *testing.T) {
+	p := New()
+
+	rows := []string{
+		`timestamp,level,user,message`,
+		`2024-01-15 14:30:22,ERROR,alice,"Payment failed, card ""declined"""`,
+	}
+	lines := make([]*models.LogLine, len(rows))
+	for i, raw := range rows {
+		lines[i] = &models.LogLine{Source: "/tmp/export.csv", Raw: raw, LineNum: i + 1}
+		p.ParseLogLine(lines[i])
+	}
+
+	if lines[0].Parsed != nil || lines[0].Format != models.FormatCSV {
+		t.Errorf("Expected header row to be consumed, got %v", lines[0].Parsed)
+	}
+
+	row := lines[1]
+	if row.Parsed["user"] != "alice" || row.Parsed["message"] != `Payment failed, card "declined"` {
+		t.Errorf("Unexpected row fields %v", row.Parsed)
+	}
+	if row.Level != string(models.LevelError) || row.Timestamp.Hour() != 14 {
+		t.Errorf("Expected level and timestamp from columns, got %q %v", row.Level, row.Timestamp)
+	}
+}
+
+func TestW3CFieldsDirective(t *testing.T) {
+	p := New()
+
+	rows := []string{
+		`#Software: Microsoft Internet Information Services 10.0`,
+		`#Fields: date time cs-method cs-uri-stem sc-status`,
+		`2024-01-15 14:30:22 GET /index.html 200`,
+		`#Fields: date time cs-uri-stem sc-status time-taken`,
+		`2024-01-15 14:31:00 /api/orders 503 812`,
+	}
+	lines := make([]*models.LogLine, len(rows))
+	for i, raw := range rows {
+		lines[i] = &models.LogLine{Source: "u_ex240115.log", Raw: raw}
+		p.ParseLogLine(lines[i])
+	}
+
+	if lines[2].Parsed["cs-method"] != "GET" || lines[2].Level != string(models.LevelInfo) {
+		t.Errorf("Unexpected first row %v", lines[2].Parsed)
+	}
+	if lines[4].Parsed["cs-uri-stem"] != "/api/orders" || lines[4].Parsed["time-taken"] != "812" {
+		t.Errorf("Expected mid-file #Fields change to apply, got %v", lines[4].Parsed)
+	}
+	if lines[4].Level != string(models.LevelError) || lines[4].Timestamp.Minute() != 31 {
+		t.Errorf("Unexpected level/timestamp %q %v", lines[4].Level, lines[4].Timestamp)
+	}
+}
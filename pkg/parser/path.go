@@ -0,0 +1,334 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SegmentKind identifies the kind of a field path segment
+type SegmentKind int
+
+const (
+	SegmentKey      SegmentKind = iota // map key, e.g. user or "http.status_code"
+	SegmentIndex                       // array index, e.g. [0]
+	SegmentWildcard                    // any key or element, e.g. * or [*]
+)
+
+// PathSegment is a single step of a FieldPath
+type PathSegment struct {
+	Kind   SegmentKind
+	Key    string
+	Index  int
+	Quoted bool // key was quoted and must match a single map key exactly
+}
+
+// FieldPath is a parsed field access path.
+//
+// Syntax:
+//
+//	user.id              nested map keys
+//	errors[0].code       array index
+//	tags.* / tags[*]     any key or element; matches if any value matches
+//	"http.status_code"   quoted segment holding dots (also 'single quotes')
+//	http\.status_code    backslash-escaped dot
+//
+// Unquoted keys also match flat keys containing dots, so http.status_code
+// finds {"http.status_code": 200} as well as {"http": {"status_code": 200}}.
+type FieldPath struct {
+	Raw      string
+	Segments []PathSegment
+}
+
+// maxCachedPaths bounds pathCache. The cache is emptied when it fills up:
+// the handful of paths in use is parsed again on the next line.
+const maxCachedPaths = 1024
+
+// pathCache caches parsed paths since the same handful of paths is resolved
+// for every line during filtering
+var (
+	pathCacheMu sync.RWMutex
+	pathCache   = make(map[string]FieldPath)
+)
+
+// ParsePath parses a field path string
+func ParsePath(path string) (FieldPath, error) {
+	pathCacheMu.RLock()
+	cached, ok := pathCache[path]
+	pathCacheMu.RUnlock()
+	if ok {
+		return cached, nil
+	}
+
+	fp := FieldPath{Raw: path}
+	if path == "" {
+		return fp, fmt.Errorf("empty field path")
+	}
+
+	pos := 0
+	expectSegment := true
+
+	for pos < len(path) {
+		ch := path[pos]
+
+		switch {
+		case ch == '.':
+			if expectSegment {
+				return fp, fmt.Errorf("empty segment at position %d in %q", pos, path)
+			}
+			expectSegment = true
+			pos++
+
+		case ch == '[':
+			end := strings.IndexByte(path[pos:], ']')
+			if end == -1 {
+				return fp, fmt.Errorf("missing ']' at position %d in %q", pos, path)
+			}
+			inner := strings.TrimSpace(path[pos+1 : pos+end])
+			switch {
+			case inner == "*":
+				fp.Segments = append(fp.Segments, PathSegment{Kind: SegmentWildcard})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				key, err := unquoteSegment(inner)
+				if err != nil {
+					return fp, fmt.Errorf("invalid quoted key at position %d in %q: %w", pos, path, err)
+				}
+				fp.Segments = append(fp.Segments, PathSegment{Kind: SegmentKey, Key: key, Quoted: true})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return fp, fmt.Errorf("invalid array index %q at position %d in %q", inner, pos, path)
+				}
+				fp.Segments = append(fp.Segments, PathSegment{Kind: SegmentIndex, Index: index})
+			}
+			pos += end + 1
+			expectSegment = false
+
+		case ch == '"' || ch == '\'':
+			if !expectSegment {
+				return fp, fmt.Errorf("unexpected quote at position %d in %q", pos, path)
+			}
+			end := pos + 1
+			for end < len(path) && path[end] != ch {
+				if path[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(path) {
+				return fp, fmt.Errorf("unterminated quote at position %d in %q", pos, path)
+			}
+			key, err := unquoteSegment(path[pos : end+1])
+			if err != nil {
+				return fp, fmt.Errorf("invalid quoted key at position %d in %q: %w", pos, path, err)
+			}
+			fp.Segments = append(fp.Segments, PathSegment{Kind: SegmentKey, Key: key, Quoted: true})
+			pos = end + 1
+			expectSegment = false
+
+		default:
+			if !expectSegment {
+				return fp, fmt.Errorf("unexpected character %q at position %d in %q", ch, pos, path)
+			}
+			var key strings.Builder
+			escaped := false
+			for pos < len(path) {
+				c := path[pos]
+				if c == '\\' && pos+1 < len(path) {
+					key.WriteByte(path[pos+1])
+					escaped = true
+					pos += 2
+					continue
+				}
+				if c == '.' || c == '[' {
+					break
+				}
+				key.WriteByte(c)
+				pos++
+			}
+			if key.String() == "*" && !escaped {
+				fp.Segments = append(fp.Segments, PathSegment{Kind: SegmentWildcard})
+			} else {
+				fp.Segments = append(fp.Segments, PathSegment{Kind: SegmentKey, Key: key.String(), Quoted: escaped})
+			}
+			expectSegment = false
+		}
+	}
+
+	if expectSegment {
+		return fp, fmt.Errorf("field path %q ends with '.'", path)
+	}
+
+	pathCacheMu.Lock()
+	if len(pathCache) >= maxCachedPaths {
+		pathCache = make(map[string]FieldPath)
+	}
+	pathCache[path] = fp
+	pathCacheMu.Unlock()
+	return fp, nil
+}
+
+// unquoteSegment strips quotes from a quoted path segment
+func unquoteSegment(s string) (string, error) {
+	if s[0] == '\'' {
+		s = `"` + strings.ReplaceAll(s[1:len(s)-1], `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(s)
+}
+
+// IsWildcard returns true if the path can resolve to more than one value
+func (fp FieldPath) IsWildcard() bool {
+	for _, seg := range fp.Segments {
+		if seg.Kind == SegmentWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup resolves the path against structured data and returns every
+// value it reaches (more than one only when wildcards are involved)
+func (fp FieldPath) Lookup(data map[string]interface{}) []interface{} {
+	if data == nil || len(fp.Segments) == 0 {
+		return nil
+	}
+	return lookupSegments(data, fp.Segments, nil)
+}
+
+// lookupSegments walks the remaining segments from the current value
+func lookupSegments(current interface{}, segments []PathSegment, results []interface{}) []interface{} {
+	if len(segments) == 0 {
+		return append(results, current)
+	}
+
+	seg := segments[0]
+
+	switch node := current.(type) {
+	case map[string]interface{}:
+		switch seg.Kind {
+		case SegmentKey:
+			// Prefer the longest run of unquoted segments that names a flat
+			// key containing dots (OpenTelemetry-style attribute names)
+			if !seg.Quoted {
+				for n := countPlainKeys(segments); n > 1; n-- {
+					if val, ok := node[joinKeys(segments[:n])]; ok {
+						results = lookupSegments(val, segments[n:], results)
+						return results
+					}
+				}
+			}
+			if val, ok := node[seg.Key]; ok {
+				results = lookupSegments(val, segments[1:], results)
+			}
+		case SegmentWildcard:
+			for _, key := range sortedKeys(node) {
+				results = lookupSegments(node[key], segments[1:], results)
+			}
+		}
+
+	case []interface{}:
+		switch seg.Kind {
+		case SegmentIndex:
+			index := seg.Index
+			if index < 0 {
+				index += len(node)
+			}
+			if index >= 0 && index < len(node) {
+				results = lookupSegments(node[index], segments[1:], results)
+			}
+		case SegmentWildcard:
+			for _, elem := range node {
+				results = lookupSegments(elem, segments[1:], results)
+			}
+		}
+	}
+
+	return results
+}
+
+// countPlainKeys counts leading unquoted key segments
+func countPlainKeys(segments []PathSegment) int {
+	n := 0
+	for _, seg := range segments {
+		if seg.Kind != SegmentKey || seg.Quoted {
+			break
+		}
+		n++
+	}
+	return n
+}
+
+// joinKeys joins key segments with dots
+func joinKeys(segments []PathSegment) string {
+	keys := make([]string, len(segments))
+	for i, seg := range segments {
+		keys[i] = seg.Key
+	}
+	return strings.Join(keys, ".")
+}
+
+// sortedKeys returns map keys in sorted order so wildcard results are stable
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// AppendKey extends a field path with a map key. Keys other than plain
+// identifiers are quoted in brackets so the path names exactly that key.
+func AppendKey(path, key string) string {
+	if isPlainKey(key) {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+// AppendIndex extends a field path with an array index
+func AppendIndex(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
+
+// isPlainKey reports whether a key can appear unquoted in a field path
+func isPlainKey(key string) bool {
+	if key == "" || key == "*" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '_' || r == '-' || r == '@' || r == '$' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// LookupField resolves a field path string against structured data.
+// Invalid paths resolve to nothing.
+func LookupField(data map[string]interface{}, path string) []interface{} {
+	fp, err := ParsePath(path)
+	if err != nil {
+		return nil
+	}
+	return fp.Lookup(data)
+}
+
+// FormatValue renders a resolved field value as text for matching and display
+func FormatValue(val interface{}) string {
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -0,0 +1,591 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// The eval expression language, from lowest to highest precedence:
+//
+//	expr    = and { ("||" | "OR") and }
+//	and     = cmp { ("&&" | "AND") cmp }
+//	cmp     = add [ ("==" | "!=" | "<" | "<=" | ">" | ">=") add ]
+//	add     = mul { ("+" | "-") mul }
+//	mul     = unary { ("*" | "/" | "%") unary }
+//	unary   = ("-" | "!" | "NOT") unary | primary
+//	primary = number | "string" | true | false | null | call | field | "(" expr ")"
+//
+// Values are numbers, strings, booleans or null. Arithmetic converts
+// numeric strings and yields null for anything else; "+" concatenates when
+// either side is not a number. Fields use the query path syntax.
+
+// evalNode evaluates part of an expression against a row
+type evalNode func(row *models.LogLine, ctx *Context) interface{}
+
+// evalFunc implements a function; arguments are evaluated lazily so if()
+// and coalesce() only evaluate what they need
+type evalFunc struct {
+	minArgs, maxArgs int // maxArgs < 0 means variadic
+	call             func(args []evalNode, row *models.LogLine, ctx *Context) interface{}
+}
+
+// evalFuncs are the functions available to eval
+var evalFuncs map[string]evalFunc
+
+func init() {
+	strArg := func(args []evalNode, i int, row *models.LogLine, ctx *Context) string {
+		return toString(args[i](row, ctx))
+	}
+	numArg := func(args []evalNode, i int, row *models.LogLine, ctx *Context) (float64, bool) {
+		return toNumber(args[i](row, ctx))
+	}
+	numFunc := func(fn func(float64) float64) evalFunc {
+		return evalFunc{1, 1, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			if n, ok := numArg(args, 0, row, ctx); ok {
+				return fn(n)
+			}
+			return nil
+		}}
+	}
+	strFunc := func(fn func(string) string) evalFunc {
+		return evalFunc{1, 1, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			return fn(strArg(args, 0, row, ctx))
+		}}
+	}
+
+	evalFuncs = map[string]evalFunc{
+		"if": {3, 3, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			if truthy(args[0](row, ctx)) {
+				return args[1](row, ctx)
+			}
+			return args[2](row, ctx)
+		}},
+		"coalesce": {1, -1, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			for _, arg := range args {
+				if v := arg(row, ctx); v != nil && v != "" {
+					return v
+				}
+			}
+			return nil
+		}},
+		"lower": strFunc(strings.ToLower),
+		"upper": strFunc(strings.ToUpper),
+		"trim":  strFunc(strings.TrimSpace),
+		"len": {1, 1, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			return float64(len([]rune(strArg(args, 0, row, ctx))))
+		}},
+		"substr": {2, 3, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			s := []rune(strArg(args, 0, row, ctx))
+			start, ok := numArg(args, 1, row, ctx)
+			if !ok {
+				return nil
+			}
+			from := clamp(int(start), len(s))
+			to := len(s)
+			if len(args) == 3 {
+				n, ok := numArg(args, 2, row, ctx)
+				if !ok {
+					return nil
+				}
+				to = from + clamp(int(n), len(s)-from) // A negative length takes nothing
+			}
+			return string(s[from:to])
+		}},
+		"replace": {3, 3, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			return strings.ReplaceAll(strArg(args, 0, row, ctx), strArg(args, 1, row, ctx), strArg(args, 2, row, ctx))
+		}},
+		"concat": {1, -1, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			var b strings.Builder
+			for i := range args {
+				b.WriteString(strArg(args, i, row, ctx))
+			}
+			return b.String()
+		}},
+		"contains": {2, 2, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			return strings.Contains(strArg(args, 0, row, ctx), strArg(args, 1, row, ctx))
+		}},
+		"match": {2, 2, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			re, err := regexp.Compile(strArg(args, 1, row, ctx))
+			return err == nil && re.MatchString(strArg(args, 0, row, ctx))
+		}},
+		"tonumber": {1, 1, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			if n, ok := numArg(args, 0, row, ctx); ok {
+				return n
+			}
+			return nil
+		}},
+		"tostring": {1, 1, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			return strArg(args, 0, row, ctx)
+		}},
+		"round": {1, 2, func(args []evalNode, row *models.LogLine, ctx *Context) interface{} {
+			n, ok := numArg(args, 0, row, ctx)
+			if !ok {
+				return nil
+			}
+			places := 0.0
+			if len(args) == 2 {
+				places, _ = numArg(args, 1, row, ctx)
+			}
+			scale := math.Pow(10, places)
+			return math.Round(n*scale) / scale
+		}},
+		"abs":   numFunc(math.Abs),
+		"floor": numFunc(math.Floor),
+		"ceil":  numFunc(math.Ceil),
+	}
+}
+
+// clamp limits an index to [0, n]
+func clamp(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+// toNumber converts a value to a number
+func toNumber(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case int:
+		return float64(x), true
+	case bool:
+		if x {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toString converts a value to its display form
+func toString(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		return fmt.Sprint(x)
+	}
+}
+
+// truthy reports whether a value counts as true
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return true
+}
+
+// evalParser is a recursive descent parser for eval expressions
+type evalParser struct {
+	query  string
+	input  string
+	offset int
+	pos    int
+}
+
+// errorf reports an error at the current position
+func (p *evalParser) errorf(format string, args ...interface{}) error {
+	return &filter.QueryError{Query: p.query, Pos: p.offset + p.pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace skips whitespace
+func (p *evalParser) skipSpace() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// accept consumes one of the operators if it comes next. Word operators
+// must not be followed by an identifier character.
+func (p *evalParser) accept(ops ...string) (string, bool) {
+	p.skipSpace()
+	for _, op := range ops {
+		if !strings.HasPrefix(p.input[p.pos:], op) {
+			continue
+		}
+		end := p.pos + len(op)
+		if isIdentChar(op[0]) && end < len(p.input) && isIdentChar(p.input[end]) {
+			continue
+		}
+		// "<" and ">" must not swallow the first half of "<=" or ">="
+		if (op == "<" || op == ">" || op == "!") && end < len(p.input) && p.input[end] == '=' {
+			continue
+		}
+		p.pos = end
+		return op, true
+	}
+	return "", false
+}
+
+func (p *evalParser) parseOr() (evalNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "OR"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row *models.LogLine, ctx *Context) interface{} {
+			return truthy(l(row, ctx)) || truthy(right(row, ctx))
+		}
+	}
+}
+
+func (p *evalParser) parseAnd() (evalNode, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "AND"); !ok {
+			return left, nil
+		}
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row *models.LogLine, ctx *Context) interface{} {
+			return truthy(l(row, ctx)) && truthy(right(row, ctx))
+		}
+	}
+}
+
+func (p *evalParser) parseComparison() (evalNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(row *models.LogLine, ctx *Context) interface{} {
+		a, b := left(row, ctx), right(row, ctx)
+		var cmp int
+		x, okA := toNumber(a)
+		y, okB := toNumber(b)
+		switch {
+		case okA && okB:
+			cmp = compareFloats(x, y)
+		case a == nil || b == nil:
+			if op == "==" {
+				return a == nil && b == nil
+			}
+			if op == "!=" {
+				return (a == nil) != (b == nil)
+			}
+			return false
+		default:
+			cmp = strings.Compare(toString(a), toString(b))
+		}
+		switch op {
+		case "==":
+			return cmp == 0
+		case "!=":
+			return cmp != 0
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	}, nil
+}
+
+func (p *evalParser) parseAdditive() (evalNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row *models.LogLine, ctx *Context) interface{} {
+			a, b := l(row, ctx), right(row, ctx)
+			x, okA := toNumber(a)
+			y, okB := toNumber(b)
+			if okA && okB {
+				if op == "+" {
+					return x + y
+				}
+				return x - y
+			}
+			if op == "+" && (a != nil || b != nil) {
+				return toString(a) + toString(b)
+			}
+			return nil
+		}
+	}
+}
+
+func (p *evalParser) parseMultiplicative() (evalNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row *models.LogLine, ctx *Context) interface{} {
+			x, okA := toNumber(l(row, ctx))
+			y, okB := toNumber(right(row, ctx))
+			if !okA || !okB {
+				return nil
+			}
+			switch op {
+			case "*":
+				return x * y
+			case "/":
+				if y == 0 {
+					return nil
+				}
+				return x / y
+			default:
+				if y == 0 {
+					return nil
+				}
+				return math.Mod(x, y)
+			}
+		}
+	}
+}
+
+func (p *evalParser) parseUnary() (evalNode, error) {
+	if op, ok := p.accept("-", "!", "NOT"); ok {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "-" {
+			return func(row *models.LogLine, ctx *Context) interface{} {
+				if n, ok := toNumber(operand(row, ctx)); ok {
+					return -n
+				}
+				return nil
+			}, nil
+		}
+		return func(row *models.LogLine, ctx *Context) interface{} {
+			return !truthy(operand(row, ctx))
+		}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *evalParser) parsePrimary() (evalNode, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	ch := p.input[p.pos]
+	switch {
+	case ch == '(':
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.errorf("missing ')'")
+		}
+		return node, nil
+
+	case ch == '"':
+		s, err := p.scanString()
+		if err != nil {
+			return nil, err
+		}
+		return func(*models.LogLine, *Context) interface{} { return s }, nil
+
+	case ch >= '0' && ch <= '9' || ch == '.':
+		start := p.pos
+		for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.' || p.input[p.pos] == 'e') {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number")
+		}
+		return func(*models.LogLine, *Context) interface{} { return n }, nil
+
+	case isIdentChar(ch):
+		start := p.pos
+		name := p.scanIdent()
+		switch strings.ToLower(name) {
+		case "true", "false":
+			b := strings.EqualFold(name, "true")
+			return func(*models.LogLine, *Context) interface{} { return b }, nil
+		case "null":
+			return func(*models.LogLine, *Context) interface{} { return nil }, nil
+		}
+
+		p.skipSpace()
+		if p.pos < len(p.input) && p.input[p.pos] == '(' {
+			return p.parseCall(name, start)
+		}
+		return func(row *models.LogLine, ctx *Context) interface{} {
+			if v := ctx.Value(row, name); v != "" {
+				return v
+			}
+			return nil
+		}, nil
+	}
+
+	return nil, p.errorf("unexpected %q", string(ch))
+}
+
+// parseCall parses the arguments of a function call
+func (p *evalParser) parseCall(name string, start int) (evalNode, error) {
+	fn, ok := evalFuncs[strings.ToLower(name)]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function %q", name)
+	}
+	p.pos++ // '('
+
+	var args []evalNode
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, p.errorf("missing ')' in call to %s", name)
+			}
+			break
+		}
+	}
+
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		p.pos = start
+		return nil, p.errorf("wrong number of arguments to %s", name)
+	}
+
+	return func(row *models.LogLine, ctx *Context) interface{} {
+		return fn.call(args, row, ctx)
+	}, nil
+}
+
+// scanString reads a double-quoted string with backslash escapes
+func (p *evalParser) scanString() (string, error) {
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.input) {
+		ch := p.input[p.pos]
+		if ch == '\\' && p.pos+1 < len(p.input) {
+			b.WriteByte(p.input[p.pos+1])
+			p.pos += 2
+			continue
+		}
+		if ch == '"' {
+			p.pos++
+			return b.String(), nil
+		}
+		b.WriteByte(ch)
+		p.pos++
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+// scanIdent reads a field or function name, including path brackets
+func (p *evalParser) scanIdent() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		ch := p.input[p.pos]
+		if ch == '[' {
+			if end := strings.IndexByte(p.input[p.pos:], ']'); end >= 0 {
+				p.pos += end + 1
+				continue
+			}
+		}
+		if !isIdentChar(ch) && ch != '.' && !isDigit(ch) {
+			break
+		}
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// isIdentChar reports whether ch may start an identifier
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch == '@' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+// isDigit reports whether ch is a decimal digit
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// compareFloats returns -1, 0 or 1
+func compareFloats(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,262 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// RexStage extracts named regex captures into fields
+type RexStage struct {
+	Field   string // Field to match against; empty means the raw line
+	Pattern *regexp.Regexp
+}
+
+// parseRex parses: rex [field=name] "regex"
+func parseRex(s *scanner) (Stage, error) {
+	stage := &RexStage{}
+
+	if tok := s.peek(); tok.kind == tokIdent && strings.EqualFold(tok.text, "field") {
+		s.next()
+		if !s.accept("=") {
+			return nil, s.errorf(s.peek().pos, "expected '=' after field")
+		}
+		name := s.next()
+		if name.kind != tokIdent && name.kind != tokString {
+			return nil, s.errorf(name.pos, "expected a field name")
+		}
+		stage.Field = name.text
+	}
+
+	tok := s.next()
+	if tok.kind != tokString {
+		return nil, s.errorf(tok.pos, "expected a quoted regular expression")
+	}
+	re, err := regexp.Compile(tok.text)
+	if err != nil {
+		return nil, s.errorf(tok.pos, "invalid regular expression: %v", err)
+	}
+	if !hasNamedGroups(re) {
+		return nil, s.errorf(tok.pos, "regular expression has no named groups such as (?P<name>...)")
+	}
+	stage.Pattern = re
+
+	return stage, nil
+}
+
+// hasNamedGroups reports whether a regex captures anything by name
+func hasNamedGroups(re *regexp.Regexp) bool {
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply adds the named captures of matching rows; other rows pass unchanged
+func (r *RexStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	out := &Result{Columns: in.Columns, Aggregated: in.Aggregated}
+	names := r.Pattern.SubexpNames()
+
+	for _, row := range in.Rows {
+		text := row.Raw
+		if r.Field != "" {
+			text = ctx.Value(row, r.Field)
+		}
+
+		match := r.Pattern.FindStringSubmatch(text)
+		if match == nil {
+			out.Rows = append(out.Rows, row)
+			continue
+		}
+
+		derived := derive(row)
+		for i, name := range names {
+			if name != "" {
+				derived.Parsed[name] = match[i]
+			}
+		}
+		out.Rows = append(out.Rows, derived)
+	}
+
+	for _, name := range names {
+		if name != "" {
+			out.Columns = addColumn(out.Columns, name)
+		}
+	}
+	refreshRaw(out)
+	return out, nil
+}
+
+// String returns the stage as written
+func (r *RexStage) String() string {
+	s := "rex "
+	if r.Field != "" {
+		s += "field=" + r.Field + " "
+	}
+	return s + `"` + strings.ReplaceAll(r.Pattern.String(), `"`, `\"`) + `"`
+}
+
+// assignment is one name = expression pair of an eval stage
+type assignment struct {
+	name   string
+	source string
+	expr   evalNode
+}
+
+// EvalStage computes new fields from expressions
+type EvalStage struct {
+	Assignments []assignment
+}
+
+// parseEval parses: eval name = expr {, name = expr}
+func parseEval(s *scanner) (Stage, error) {
+	text, offset := s.rest()
+	p := &evalParser{query: s.query, input: text, offset: offset}
+	stage := &EvalStage{}
+
+	for {
+		p.skipSpace()
+		name := p.scanIdent()
+		if name == "" {
+			return nil, p.errorf("expected a field name")
+		}
+		if _, ok := p.accept("="); !ok || strings.HasPrefix(p.input[p.pos:], "=") {
+			return nil, p.errorf("expected '=' after %s", name)
+		}
+
+		p.skipSpace()
+		exprStart := p.pos
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		stage.Assignments = append(stage.Assignments, assignment{
+			name:   name,
+			source: strings.TrimSpace(p.input[exprStart:p.pos]),
+			expr:   expr,
+		})
+
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+		p.skipSpace()
+		if p.pos < len(p.input) {
+			return nil, p.errorf("unexpected %q", p.input[p.pos:])
+		}
+		return stage, nil
+	}
+}
+
+// Apply evaluates the assignments in order on every row; later
+// assignments see the results of earlier ones
+func (e *EvalStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	out := &Result{Columns: in.Columns, Aggregated: in.Aggregated}
+
+	for _, row := range in.Rows {
+		derived := derive(row)
+		for _, a := range e.Assignments {
+			if value := a.expr(derived, ctx); value != nil {
+				derived.Parsed[a.name] = value
+			} else {
+				delete(derived.Parsed, a.name)
+			}
+		}
+		out.Rows = append(out.Rows, derived)
+	}
+
+	for _, a := range e.Assignments {
+		out.Columns = addColumn(out.Columns, a.name)
+	}
+	refreshRaw(out)
+	return out, nil
+}
+
+// String returns the stage as written
+func (e *EvalStage) String() string {
+	parts := make([]string, len(e.Assignments))
+	for i, a := range e.Assignments {
+		parts[i] = a.name + " = " + a.source
+	}
+	return "eval " + strings.Join(parts, ", ")
+}
+
+// WhereStage keeps rows matching a filter expression
+type WhereStage struct {
+	Expression filter.QueryExpression
+}
+
+// parseWhere parses: where <filter expression>
+func parseWhere(s *scanner) (Stage, error) {
+	text, offset := s.rest()
+	if strings.TrimSpace(text) == "" {
+		return nil, s.errorf(offset, "where needs a filter expression")
+	}
+
+	expr, err := filter.ParseQuery(text)
+	if err != nil {
+		var queryErr *filter.QueryError
+		if errors.As(err, &queryErr) {
+			return nil, s.errorf(offset+queryErr.Pos, "%s", queryErr.Message)
+		}
+		return nil, err
+	}
+	return &WhereStage{Expression: expr}, nil
+}
+
+// Apply filters rows, including fields added by earlier stages
+func (w *WhereStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	out := &Result{Columns: in.Columns, Aggregated: in.Aggregated}
+	for _, row := range in.Rows {
+		if w.Expression.Evaluate(row, ctx.Filter) {
+			out.Rows = append(out.Rows, row)
+		}
+	}
+	return out, nil
+}
+
+// String returns the stage as written
+func (w *WhereStage) String() string {
+	return fmt.Sprintf("where %s", w.Expression.String())
+}
+
+// derive copies a row with its own Parsed map so stages can add fields
+// without changing the buffered line
+func derive(row *models.LogLine) *models.LogLine {
+	copied := *row
+	copied.Parsed = make(map[string]interface{}, len(row.Parsed)+1)
+	for k, v := range row.Parsed {
+		copied.Parsed[k] = v
+	}
+	return &copied
+}
+
+// addColumn appends a column to a table result; results shown as log
+// lines keep no columns
+func addColumn(columns []string, name string) []string {
+	if columns == nil {
+		return nil
+	}
+	for _, column := range columns {
+		if column == name {
+			return columns
+		}
+	}
+	return append(append([]string{}, columns...), name)
+}
+
+// refreshRaw rebuilds the text of synthetic rows after columns changed
+func refreshRaw(result *Result) {
+	if !result.Aggregated {
+		return
+	}
+	for i, row := range result.Rows {
+		fresh := newRow(i, result.Columns, row.Parsed)
+		row.Raw = fresh.Raw
+	}
+}
@@ -0,0 +1,186 @@
+// Package pipeline evaluates the "| stage" operators that may follow a
+// filter expression, e.g. level:ERROR | stats count() by service
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// Pipeline is a filter expression followed by zero or more stages
+type Pipeline struct {
+	Query  string  // Full query text
+	Filter string  // Filter expression before the first stage
+	Stages []Stage // Operators applied in order to the matching lines
+}
+
+// Result is the output of a stage. Rows are log lines; aggregating stages
+// produce synthetic lines whose Parsed map holds one value per column, so
+// later stages and exports read them like any other line.
+type Result struct {
+	Columns    []string // Table columns; nil means rows are shown as log lines
+	Rows       []*models.LogLine
+	Aggregated bool // Rows are synthetic (stats, top)
+}
+
+// Context gives stages access to field extraction
+type Context struct {
+	Filter *filter.FilterEngine
+}
+
+// Value returns the first value of a field on a row
+func (c *Context) Value(line *models.LogLine, field string) string {
+	return c.Filter.FieldValue(line, field)
+}
+
+// Values returns every value of a field on a row
+func (c *Context) Values(line *models.LogLine, field string) []string {
+	return c.Filter.FieldValues(line, field)
+}
+
+// Stage is one pipeline operator
+type Stage interface {
+	Apply(in *Result, ctx *Context) (*Result, error)
+	String() string
+}
+
+// stageParser parses the arguments of a stage into a Stage
+type stageParser func(s *scanner) (Stage, error)
+
+// stageParsers maps operator names to their parsers
+var stageParsers = map[string]stageParser{
+	"stats":       parseStats,
+	"top":         parseTop,
+	"sort":        parseSort,
+	"fields":      parseFields,
+	"dedup":       parseDedup,
+	"rex":         parseRex,
+	"eval":        parseEval,
+	"where":       parseWhere,
+	"transaction": parseTransaction,
+}
+
+// Parse splits a query into its filter expression and stages. Stage
+// syntax errors are reported as *filter.QueryError with positions in the
+// full query; the filter expression itself is left to the FilterEngine.
+func Parse(query string) (*Pipeline, error) {
+	filterPart, parts := splitStages(query)
+	p := &Pipeline{Query: query, Filter: filterPart}
+
+	for _, part := range parts {
+		s := newScanner(query, part.text, part.pos)
+
+		name := s.next()
+		if name.kind != tokIdent {
+			return nil, s.errorf(name.pos, "missing operator after '|'")
+		}
+
+		parse, ok := stageParsers[strings.ToLower(name.text)]
+		if !ok {
+			return nil, s.errorf(name.pos, "unknown operator %q", name.text)
+		}
+
+		stage, err := parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if tok := s.peek(); tok.kind != tokEOF {
+			return nil, s.errorf(tok.pos, "unexpected %q", tok.text)
+		}
+
+		p.Stages = append(p.Stages, stage)
+	}
+
+	return p, nil
+}
+
+// HasStages reports whether the query has any operators after the filter
+func (p *Pipeline) HasStages() bool {
+	return len(p.Stages) > 0
+}
+
+// Run applies the stages to lines that already passed the filter
+func (p *Pipeline) Run(lines []*models.LogLine, f *filter.FilterEngine) (*Result, error) {
+	ctx := &Context{Filter: f}
+
+	rows := make([]*models.LogLine, len(lines))
+	copy(rows, lines)
+	result := &Result{Rows: rows}
+
+	for _, stage := range p.Stages {
+		next, err := stage.Apply(result, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", stage.String(), err)
+		}
+		result = next
+	}
+
+	return result, nil
+}
+
+// String returns the stages in canonical form
+func (p *Pipeline) String() string {
+	parts := make([]string, len(p.Stages))
+	for i, stage := range p.Stages {
+		parts[i] = stage.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// stagePart is the text of one stage and its offset in the query
+type stagePart struct {
+	text string
+	pos  int
+}
+
+// splitStages splits a query at top-level '|' separators. A separator must
+// be preceded by whitespace and stand outside quotes, parentheses and
+// brackets, so level:(A|B), ~a|b and || are never split.
+func splitStages(query string) (string, []stagePart) {
+	var cuts []int
+	depth := 0
+	inQuotes := false
+
+	for i := 0; i < len(query); i++ {
+		ch := query[i]
+		switch {
+		case ch == '\\':
+			i++
+		case ch == '"':
+			inQuotes = !inQuotes
+		case inQuotes:
+		case ch == '(' || ch == '[':
+			depth++
+		case ch == ')' || ch == ']':
+			if depth > 0 {
+				depth--
+			}
+		case ch == '|' && depth == 0:
+			if i+1 < len(query) && query[i+1] == '|' {
+				i++
+				continue
+			}
+			if i == 0 || query[i-1] == ' ' || query[i-1] == '\t' {
+				cuts = append(cuts, i)
+			}
+		}
+	}
+
+	if len(cuts) == 0 {
+		return strings.TrimSpace(query), nil
+	}
+
+	parts := make([]stagePart, len(cuts))
+	for n, cut := range cuts {
+		end := len(query)
+		if n+1 < len(cuts) {
+			end = cuts[n+1]
+		}
+		parts[n] = stagePart{text: query[cut+1 : end], pos: cut + 1}
+	}
+
+	return strings.TrimSpace(query[:cuts[0]]), parts
+}
@@ -0,0 +1,242 @@
+package pipeline
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+// run filters raw lines with the query's filter part and applies its stages
+func run(t *testing.T, query string, raws ...string) *Result {
+	t.Helper()
+
+	pl, err := Parse(query)
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", query, err)
+	}
+
+	p := parser.New()
+	f := filter.New(p)
+	if err := f.SetFilter(models.FilterOptions{Query: pl.Filter}); err != nil {
+		t.Fatalf("SetFilter(%q) failed: %v", pl.Filter, err)
+	}
+
+	var lines []*models.LogLine
+	for _, raw := range raws {
+		line := &models.LogLine{Raw: raw}
+		p.ParseLogLine(line)
+		if !f.HasFilter() || f.Match(line) {
+			lines = append(lines, line)
+		}
+	}
+
+	result, err := pl.Run(lines, f)
+	if err != nil {
+		t.Fatalf("Run(%q) failed: %v", query, err)
+	}
+	return result
+}
+
+var sample = []string{
+	`{"level":"ERROR","service":"api","path":"/orders","latency":120,"trace_id":"a"}`,
+	`{"level":"ERROR","service":"api","path":"/orders","latency":80,"trace_id":"a"}`,
+	`{"level":"INFO","service":"api","path":"/health","latency":5,"trace_id":"b"}`,
+	`{"level":"ERROR","service":"db","path":"/query","latency":300,"trace_id":"c"}`,
+}
+
+func TestSplitStages(t *testing.T) {
+	pl, err := Parse(`level:(ERROR|WARN) || ~a|b | top path`)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if pl.Filter != `level:(ERROR|WARN) || ~a|b` {
+		t.Errorf("Unexpected filter part %q", pl.Filter)
+	}
+	if len(pl.Stages) != 1 || pl.Stages[0].String() != "top 10 path" {
+		t.Errorf("Unexpected stages %q", pl.String())
+	}
+}
+
+func TestStatsByGroup(t *testing.T) {
+	result := run(t, `level:ERROR | stats count(), avg(latency), p95(latency) by service`, sample...)
+
+	if len(result.Rows) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(result.Rows))
+	}
+
+	api := result.Rows[0].Parsed
+	if api["service"] != "api" || api["count"] != 2.0 || api["avg(latency)"] != 100.0 || api["p95(latency)"] != 118.0 {
+		t.Errorf("Unexpected api row %v", api)
+	}
+}
+
+func TestStatsBySource(t *testing.T) {
+	p := parser.New()
+	f := filter.New(p)
+	var lines []*models.LogLine
+	for _, source := range []string{"app.log", "app.log", "db.log"} {
+		line := &models.LogLine{Raw: `{"latency":5}`, Source: source}
+		p.ParseLogLine(line)
+		lines = append(lines, line)
+	}
+
+	pl, _ := Parse(`| stats count() by source | where source:app.log`)
+	result, err := pl.Run(lines, f)
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0].Parsed["source"] != "app.log" || result.Rows[0].Parsed["count"] != 2.0 {
+		t.Errorf("Expected the app.log row, got %v", result.Rows)
+	}
+}
+
+func TestStatsUnits(t *testing.T) {
+	units := []string{
+		`{"service":"api","latency":"812ms","size":"1KB"}`,
+		`{"service":"api","latency":"1.2s","size":"2KB"}`,
+		`{"service":"db","latency":"40ms","size":512}`,
+	}
+
+	result := run(t, `| stats avg(latency) as avg, max(latency), sum(size) by service | where avg:>500ms`, units...)
+	if len(result.Rows) != 1 {
+		t.Fatalf("Expected the api row, got %v", result.Rows)
+	}
+	api := result.Rows[0].Parsed
+	if api["avg"] != "1.006s" || api["max(latency)"] != "1.2s" || api["sum(size)"] != "3KB" {
+		t.Errorf("Unexpected api row %v", api)
+	}
+
+	sorted := run(t, `| sort -latency | fields latency`, units...)
+	if sorted.Rows[0].Parsed["latency"] != "1.2s" {
+		t.Errorf("Expected 1.2s to sort first, got %v", sorted.Rows[0].Parsed)
+	}
+}
+
+func TestTopSortFieldsDedup(t *testing.T) {
+	top := run(t, `* | top 1 path`, sample...)
+	if len(top.Rows) != 1 || top.Rows[0].Parsed["path"] != "/orders" || top.Rows[0].Parsed["percent"] != 50.0 {
+		t.Errorf("Unexpected top result %v", top.Rows)
+	}
+
+	sorted := run(t, `| sort -latency | fields service, latency`, sample...)
+	if sorted.Columns[1] != "latency" || sorted.Rows[0].Parsed["latency"] != 300.0 {
+		t.Errorf("Expected highest latency first, got %v", sorted.Rows[0].Parsed)
+	}
+
+	deduped := run(t, `| dedup trace_id`, sample...)
+	if len(deduped.Rows) != 3 {
+		t.Errorf("Expected 3 distinct traces, got %d", len(deduped.Rows))
+	}
+
+	stats := run(t, `| stats count() by level | sort -count`, sample...)
+	if stats.Rows[0].Parsed["level"] != "ERROR" {
+		t.Errorf("Expected ERROR to sort first, got %v", stats.Rows[0].Parsed)
+	}
+}
+
+func TestStageErrors(t *testing.T) {
+	_, err := Parse(`level:ERROR | stats avg() by host`)
+	var queryErr *filter.QueryError
+	if !errors.As(err, &queryErr) || queryErr.Pos != 20 {
+		t.Errorf("Expected error at 20, got %v", err)
+	}
+
+	_, err = Parse(`level:ERROR | frobnicate`)
+	if !errors.As(err, &queryErr) || queryErr.Pos != 14 {
+		t.Errorf("Expected error at 14, got %v", err)
+	}
+}
+
+func TestRexEvalWhere(t *testing.T) {
+	lines := []string{
+		`2024-01-15 10:00:00 INFO login user=alice took=250ms`,
+		`2024-01-15 10:00:01 INFO login user=bob took=1500ms`,
+		`2024-01-15 10:00:02 INFO logout user=alice`,
+	}
+
+	result := run(t, `login | rex "user=(?P<user>\w+) took=(?P<ms>\d+)ms" | eval secs = ms / 1000, slow = if(secs > 1, "yes", "no") | where slow:yes`, lines...)
+	if len(result.Rows) != 1 {
+		t.Fatalf("Expected 1 slow login, got %d", len(result.Rows))
+	}
+	row := result.Rows[0].Parsed
+	if row["user"] != "bob" || row["secs"] != 1.5 {
+		t.Errorf("Unexpected extracted fields %v", row)
+	}
+
+	stats := run(t, `| rex "user=(?P<user>\w+)" | stats count() by user | eval label = upper(user) + ":" + count`, lines...)
+	if stats.Columns[2] != "label" || stats.Rows[0].Parsed["label"] != "ALICE:2" {
+		t.Errorf("Unexpected eval over stats %v %v", stats.Columns, stats.Rows[0].Parsed)
+	}
+
+	fallback := run(t, `logout | eval took = coalesce(took, "n/a"), n = len(substr("abcdef", 1, 3))`, lines...)
+	if fallback.Rows[0].Parsed["took"] != "n/a" || fallback.Rows[0].Parsed["n"] != 3.0 {
+		t.Errorf("Unexpected coalesce result %v", fallback.Rows[0].Parsed)
+	}
+
+	clamped := run(t, `logout | eval neg = substr("hello world", 5, -3), past = substr("hello", 9, 2), tail = substr("hello", 3, 99)`, lines...)
+	if row := clamped.Rows[0].Parsed; row["neg"] != "" || row["past"] != "" || row["tail"] != "lo" {
+		t.Errorf("Unexpected substr bounds %v", row)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+	}{
+		{`* | eval x = frob(1)`, 13},
+		{`* | eval x = (1 + 2`, 19},
+		{`* | eval = 1`, 9},
+		{`* | where level:(`, 16},
+		{`* | rex "(\d+)"`, 8},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		var queryErr *filter.QueryError
+		if !errors.As(err, &queryErr) || queryErr.Pos != tt.pos {
+			t.Errorf("Parse(%q): expected error at %d, got %v", tt.query, tt.pos, err)
+		}
+	}
+}
+
+func TestTransaction(t *testing.T) {
+	lines := []string{
+		`{"timestamp":"2024-01-15T10:00:00Z","level":"INFO","trace_id":"a","msg":"start"}`,
+		`{"timestamp":"2024-01-15T10:00:00Z","level":"INFO","trace_id":"b","msg":"start"}`,
+		`{"timestamp":"2024-01-15T10:00:03Z","level":"ERROR","trace_id":"a","msg":"failed"}`,
+		`{"timestamp":"2024-01-15T10:00:01Z","level":"INFO","trace_id":"b","msg":"done"}`,
+		`{"timestamp":"2024-01-15T10:01:00Z","level":"INFO","trace_id":"a","msg":"retry"}`,
+		`{"level":"INFO","msg":"no trace"}`,
+	}
+
+	result := run(t, `| transaction trace_id maxspan=30s`, lines...)
+	if len(result.Rows) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(result.Rows))
+	}
+	first := result.Rows[0].Parsed
+	if first["trace_id"] != "a" || first["duration"] != "3s" || first["line_count"] != 2.0 || first["has_error"] != true {
+		t.Errorf("Unexpected first transaction %v", first)
+	}
+
+	slow := run(t, `| transaction trace_id maxspan=30s | where duration:>500ms`, lines...)
+	if len(slow.Rows) != 2 {
+		t.Errorf("Expected 2 transactions over 500ms, got %v", slow.Rows)
+	}
+	if slower := run(t, `| transaction trace_id maxspan=30s | where duration:>1m`, lines...); len(slower.Rows) != 0 {
+		t.Errorf("Expected no transaction over a minute, got %v", slower.Rows)
+	}
+
+	failed := run(t, `| transaction trace_id | where has_error:true duration:>2s`, lines...)
+	if len(failed.Rows) != 1 || failed.Rows[0].Parsed["line_count"] != 3.0 {
+		t.Errorf("Expected one failing transaction of 3 lines, got %v", failed.Rows)
+	}
+
+	same := run(t, `trace_id:="a" | sort timestamp`, lines...)
+	if len(same.Rows) != 3 || same.Rows[1].Parsed["msg"] != "failed" {
+		t.Errorf("Expected trace a in time order, got %d rows", len(same.Rows))
+	}
+}
@@ -0,0 +1,164 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+)
+
+// tokenKind identifies a stage argument token
+type tokenKind int
+
+const (
+	tokEOF    tokenKind = iota
+	tokIdent            // field names, function names, numbers, -field
+	tokString           // "quoted"
+	tokPunct            // , ( ) =
+)
+
+// token is a stage argument token with its position in the full query
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// scanner tokenizes the arguments of one stage
+type scanner struct {
+	query  string // full query, for error reporting
+	input  string // stage text
+	offset int    // position of input within query
+	pos    int
+	peeked *token
+}
+
+// newScanner creates a scanner over a stage starting at offset
+func newScanner(query, input string, offset int) *scanner {
+	return &scanner{query: query, input: input, offset: offset}
+}
+
+// errorf creates a positioned query error
+func (s *scanner) errorf(pos int, format string, args ...interface{}) error {
+	return &filter.QueryError{Query: s.query, Pos: pos, Message: fmt.Sprintf(format, args...)}
+}
+
+// peek returns the next token without consuming it
+func (s *scanner) peek() token {
+	if s.peeked == nil {
+		tok := s.scan()
+		s.peeked = &tok
+	}
+	return *s.peeked
+}
+
+// next consumes the next token
+func (s *scanner) next() token {
+	tok := s.peek()
+	s.peeked = nil
+	return tok
+}
+
+// accept consumes the next token if it is the given punctuation
+func (s *scanner) accept(punct string) bool {
+	if tok := s.peek(); tok.kind == tokPunct && tok.text == punct {
+		s.next()
+		return true
+	}
+	return false
+}
+
+// acceptKeyword consumes the next token if it is the given word
+func (s *scanner) acceptKeyword(word string) bool {
+	if tok := s.peek(); tok.kind == tokIdent && strings.EqualFold(tok.text, word) {
+		s.next()
+		return true
+	}
+	return false
+}
+
+// rest returns the unconsumed stage text and its position
+func (s *scanner) rest() (string, int) {
+	if s.peeked != nil {
+		s.pos = s.peeked.pos - s.offset
+		s.peeked = nil
+	}
+	text := s.input[s.pos:]
+	pos := s.offset + s.pos
+	s.pos = len(s.input)
+	return text, pos
+}
+
+// scan reads the next token
+func (s *scanner) scan() token {
+	for s.pos < len(s.input) && (s.input[s.pos] == ' ' || s.input[s.pos] == '\t') {
+		s.pos++
+	}
+
+	start := s.pos
+	if s.pos >= len(s.input) {
+		return token{kind: tokEOF, pos: s.offset + start}
+	}
+
+	ch := s.input[s.pos]
+	switch ch {
+	case ',', '(', ')', '=':
+		s.pos++
+		return token{kind: tokPunct, text: string(ch), pos: s.offset + start}
+
+	case '"':
+		s.pos++
+		var b strings.Builder
+		for s.pos < len(s.input) && s.input[s.pos] != '"' {
+			// Only \" is unescaped so regular expressions keep their escapes
+			if s.input[s.pos] == '\\' && s.pos+1 < len(s.input) {
+				if s.input[s.pos+1] != '"' {
+					b.WriteByte('\\')
+				}
+				s.pos++
+			}
+			b.WriteByte(s.input[s.pos])
+			s.pos++
+		}
+		s.pos++ // closing quote
+		return token{kind: tokString, text: b.String(), pos: s.offset + start}
+	}
+
+	for s.pos < len(s.input) && !strings.ContainsRune(" \t,()=\"", rune(s.input[s.pos])) {
+		s.pos++
+	}
+	return token{kind: tokIdent, text: s.input[start:s.pos], pos: s.offset + start}
+}
+
+// fieldList parses field {, field}
+func (s *scanner) fieldList(what string) ([]string, error) {
+	var fields []string
+	for {
+		tok := s.next()
+		if tok.kind != tokIdent && tok.kind != tokString {
+			return nil, s.errorf(tok.pos, "expected %s", what)
+		}
+		fields = append(fields, tok.text)
+		if !s.accept(",") {
+			return fields, nil
+		}
+	}
+}
+
+// number parses an optional positive integer, returning def when absent
+func (s *scanner) number(def int) (int, error) {
+	tok := s.peek()
+	if tok.kind != tokIdent {
+		return def, nil
+	}
+	n, err := strconv.Atoi(tok.text)
+	if err != nil {
+		return def, nil
+	}
+	s.next()
+	if n <= 0 {
+		return 0, s.errorf(tok.pos, "count must be positive")
+	}
+	return n, nil
+}
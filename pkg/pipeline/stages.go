@@ -0,0 +1,568 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+// aggregation is one stats function, e.g. p95(latency) as slow
+type aggregation struct {
+	fn    string
+	field string
+	alias string
+}
+
+// column returns the output column name
+func (a aggregation) column() string {
+	if a.alias != "" {
+		return a.alias
+	}
+	if a.field == "" {
+		return a.fn
+	}
+	return fmt.Sprintf("%s(%s)", a.fn, a.field)
+}
+
+// String returns the aggregation as written
+func (a aggregation) String() string {
+	s := fmt.Sprintf("%s(%s)", a.fn, a.field)
+	if a.alias != "" {
+		s += " as " + a.alias
+	}
+	return s
+}
+
+// aggregateFuncs lists the supported stats functions; percentiles are
+// p1 through p99 and are checked separately
+var aggregateFuncs = map[string]bool{
+	"count": true, "sum": true, "avg": true, "min": true, "max": true,
+	"dc": true, "median": true,
+}
+
+// StatsStage aggregates rows, optionally grouped by fields
+type StatsStage struct {
+	Aggregations []aggregation
+	By           []string
+}
+
+// parseStats parses: stats agg(field) [as name] {, ...} [by field {, field}]
+func parseStats(s *scanner) (Stage, error) {
+	stage := &StatsStage{}
+
+	for {
+		tok := s.next()
+		if tok.kind != tokIdent {
+			return nil, s.errorf(tok.pos, "expected an aggregation such as count() or avg(field)")
+		}
+
+		agg := aggregation{fn: strings.ToLower(tok.text)}
+		if !aggregateFuncs[agg.fn] && percentile(agg.fn) < 0 {
+			return nil, s.errorf(tok.pos, "unknown aggregation %q", tok.text)
+		}
+
+		if s.accept("(") {
+			if arg := s.peek(); arg.kind == tokIdent || arg.kind == tokString {
+				agg.field = s.next().text
+			}
+			if !s.accept(")") {
+				return nil, s.errorf(s.peek().pos, "missing ')'")
+			}
+		}
+		if agg.field == "" && agg.fn != "count" {
+			return nil, s.errorf(tok.pos, "%s needs a field", agg.fn)
+		}
+
+		if s.acceptKeyword("as") {
+			alias := s.next()
+			if alias.kind != tokIdent && alias.kind != tokString {
+				return nil, s.errorf(alias.pos, "expected a name after 'as'")
+			}
+			agg.alias = alias.text
+		}
+
+		stage.Aggregations = append(stage.Aggregations, agg)
+		if !s.accept(",") {
+			break
+		}
+	}
+
+	if s.acceptKeyword("by") {
+		by, err := s.fieldList("a field after 'by'")
+		if err != nil {
+			return nil, err
+		}
+		stage.By = by
+	}
+
+	return stage, nil
+}
+
+// percentile returns N for pN functions (p50, p95, ...), or -1
+func percentile(fn string) float64 {
+	if len(fn) < 2 || fn[0] != 'p' {
+		return -1
+	}
+	n, err := strconv.Atoi(fn[1:])
+	if err != nil || n < 1 || n > 99 {
+		return -1
+	}
+	return float64(n)
+}
+
+// Apply groups rows and computes the aggregations for each group
+func (st *StatsStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	type group struct {
+		key    []string
+		values [][]string // per aggregation
+		count  int
+	}
+
+	groups := make(map[string]*group)
+	var order []*group
+
+	for _, row := range in.Rows {
+		key := make([]string, len(st.By))
+		for i, field := range st.By {
+			key[i] = ctx.Value(row, field)
+		}
+		id := strings.Join(key, "\x00")
+
+		g, ok := groups[id]
+		if !ok {
+			g = &group{key: key, values: make([][]string, len(st.Aggregations))}
+			groups[id] = g
+			order = append(order, g)
+		}
+		g.count++
+
+		for i, agg := range st.Aggregations {
+			if agg.field != "" {
+				g.values[i] = append(g.values[i], ctx.Values(row, agg.field)...)
+			}
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return compareKeys(order[i].key, order[j].key) < 0
+	})
+
+	columns := append([]string{}, st.By...)
+	for _, agg := range st.Aggregations {
+		columns = append(columns, agg.column())
+	}
+
+	out := &Result{Columns: columns, Aggregated: true}
+	for _, g := range order {
+		values := make(map[string]interface{}, len(columns))
+		for i, field := range st.By {
+			values[field] = g.key[i]
+		}
+		for i, agg := range st.Aggregations {
+			values[agg.column()] = aggregate(agg, g.count, g.values[i])
+		}
+		out.Rows = append(out.Rows, newRow(len(out.Rows), columns, values))
+	}
+
+	// A global aggregate over no rows still reports zero counts
+	if len(out.Rows) == 0 && len(st.By) == 0 {
+		values := make(map[string]interface{})
+		for _, agg := range st.Aggregations {
+			values[agg.column()] = aggregate(agg, 0, nil)
+		}
+		out.Rows = append(out.Rows, newRow(0, columns, values))
+	}
+
+	return out, nil
+}
+
+// String returns the stage as written
+func (st *StatsStage) String() string {
+	aggs := make([]string, len(st.Aggregations))
+	for i, agg := range st.Aggregations {
+		aggs[i] = agg.String()
+	}
+	s := "stats " + strings.Join(aggs, ", ")
+	if len(st.By) > 0 {
+		s += " by " + strings.Join(st.By, ", ")
+	}
+	return s
+}
+
+// aggregate computes one aggregation over a group's values. Non-numeric
+// values are ignored by the numeric functions; empty results are nil.
+func aggregate(agg aggregation, rows int, values []string) interface{} {
+	switch agg.fn {
+	case "count":
+		if agg.field == "" {
+			return float64(rows)
+		}
+		return float64(len(values))
+	case "dc":
+		distinct := make(map[string]bool, len(values))
+		for _, v := range values {
+			distinct[v] = true
+		}
+		return float64(len(distinct))
+	}
+
+	nums, unit := numbers(values)
+	if len(nums) == 0 {
+		return nil
+	}
+	value := compute(agg.fn, nums)
+	if unit != "" {
+		return filter.FormatQuantity(value, unit) // 812ms stays a duration
+	}
+	return value
+}
+
+// compute applies a numeric function to a non-empty list
+func compute(fn string, nums []float64) float64 {
+	switch fn {
+	case "sum":
+		return sum(nums)
+	case "avg":
+		return sum(nums) / float64(len(nums))
+	case "min":
+		sort.Float64s(nums)
+		return nums[0]
+	case "max":
+		sort.Float64s(nums)
+		return nums[len(nums)-1]
+	case "median":
+		return quantile(nums, 50)
+	default:
+		return quantile(nums, percentile(fn))
+	}
+}
+
+// numbers parses the numeric values of a list, durations in seconds and
+// sizes in bytes, with the unit they share or "" when they are plain or
+// mixed
+func numbers(values []string) ([]float64, string) {
+	nums := make([]float64, 0, len(values))
+	unit, first := "", true
+	for _, v := range values {
+		n, u, ok := filter.ParseQuantity(v)
+		if !ok || math.IsNaN(n) {
+			continue
+		}
+		if first {
+			unit, first = u, false
+		} else if u != unit {
+			unit = ""
+		}
+		nums = append(nums, n)
+	}
+	return nums, unit
+}
+
+// sum adds a list of numbers
+func sum(nums []float64) float64 {
+	total := 0.0
+	for _, n := range nums {
+		total += n
+	}
+	return total
+}
+
+// quantile returns the p-th percentile using linear interpolation
+func quantile(nums []float64, p float64) float64 {
+	sort.Float64s(nums)
+	if len(nums) == 1 {
+		return nums[0]
+	}
+	rank := p / 100 * float64(len(nums)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	frac := rank - float64(lower)
+	return nums[lower] + (nums[upper]-nums[lower])*frac
+}
+
+// TopStage counts the most common values of one or more fields
+type TopStage struct {
+	Limit  int
+	Fields []string
+}
+
+// parseTop parses: top [N] field {, field}
+func parseTop(s *scanner) (Stage, error) {
+	limit, err := s.number(10)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := s.fieldList("a field to count")
+	if err != nil {
+		return nil, err
+	}
+	return &TopStage{Limit: limit, Fields: fields}, nil
+}
+
+// Apply counts value combinations and keeps the most frequent
+func (t *TopStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	type entry struct {
+		key   []string
+		count int
+	}
+
+	counts := make(map[string]*entry)
+	total := 0
+	for _, row := range in.Rows {
+		key := make([]string, len(t.Fields))
+		present := false
+		for i, field := range t.Fields {
+			key[i] = ctx.Value(row, field)
+			present = present || key[i] != ""
+		}
+		if !present {
+			continue
+		}
+
+		id := strings.Join(key, "\x00")
+		e, ok := counts[id]
+		if !ok {
+			e = &entry{key: key}
+			counts[id] = e
+		}
+		e.count++
+		total++
+	}
+
+	entries := make([]*entry, 0, len(counts))
+	for _, e := range counts {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].count != entries[j].count {
+			return entries[i].count > entries[j].count
+		}
+		return compareKeys(entries[i].key, entries[j].key) < 0
+	})
+	if len(entries) > t.Limit {
+		entries = entries[:t.Limit]
+	}
+
+	columns := append(append([]string{}, t.Fields...), "count", "percent")
+	out := &Result{Columns: columns, Aggregated: true}
+	for _, e := range entries {
+		values := map[string]interface{}{
+			"count":   float64(e.count),
+			"percent": math.Round(float64(e.count)/float64(total)*10000) / 100,
+		}
+		for i, field := range t.Fields {
+			values[field] = e.key[i]
+		}
+		out.Rows = append(out.Rows, newRow(len(out.Rows), columns, values))
+	}
+
+	return out, nil
+}
+
+// String returns the stage as written
+func (t *TopStage) String() string {
+	return fmt.Sprintf("top %d %s", t.Limit, strings.Join(t.Fields, ", "))
+}
+
+// sortKey is one sort field and its direction
+type sortKey struct {
+	field      string
+	descending bool
+}
+
+// SortStage orders rows by one or more fields
+type SortStage struct {
+	Keys []sortKey
+}
+
+// parseSort parses: sort [-|+]field {, [-|+]field}
+func parseSort(s *scanner) (Stage, error) {
+	fields, err := s.fieldList("a field to sort by")
+	if err != nil {
+		return nil, err
+	}
+
+	stage := &SortStage{}
+	for _, field := range fields {
+		key := sortKey{field: field}
+		switch {
+		case strings.HasPrefix(field, "-"):
+			key.field, key.descending = field[1:], true
+		case strings.HasPrefix(field, "+"):
+			key.field = field[1:]
+		}
+		stage.Keys = append(stage.Keys, key)
+	}
+	return stage, nil
+}
+
+// Apply sorts rows; numbers compare numerically and missing values last
+func (st *SortStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	rows := make([]*models.LogLine, len(in.Rows))
+	copy(rows, in.Rows)
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		for _, key := range st.Keys {
+			if cmp, ok := compareTimestamps(rows[i], rows[j], key.field); ok {
+				if cmp == 0 {
+					continue
+				}
+				return (cmp < 0) != key.descending
+			}
+
+			a, b := ctx.Value(rows[i], key.field), ctx.Value(rows[j], key.field)
+			if a == b {
+				continue
+			}
+			if a == "" || b == "" {
+				return b == ""
+			}
+			cmp := compareValues(a, b)
+			if key.descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+
+	return &Result{Columns: in.Columns, Rows: rows, Aggregated: in.Aggregated}, nil
+}
+
+// compareTimestamps compares the parsed times of two lines when sorting by
+// timestamp, keeping sub-second order the formatted value would lose
+func compareTimestamps(a, b *models.LogLine, field string) (int, bool) {
+	if !strings.EqualFold(field, "timestamp") || a.Timestamp.IsZero() || b.Timestamp.IsZero() {
+		return 0, false
+	}
+	return a.Timestamp.Compare(b.Timestamp), true
+}
+
+// String returns the stage as written
+func (st *SortStage) String() string {
+	keys := make([]string, len(st.Keys))
+	for i, key := range st.Keys {
+		keys[i] = key.field
+		if key.descending {
+			keys[i] = "-" + key.field
+		}
+	}
+	return "sort " + strings.Join(keys, ", ")
+}
+
+// FieldsStage selects the columns to show
+type FieldsStage struct {
+	Fields []string
+}
+
+// parseFields parses: fields field {, field}
+func parseFields(s *scanner) (Stage, error) {
+	fields, err := s.fieldList("a field name")
+	if err != nil {
+		return nil, err
+	}
+	return &FieldsStage{Fields: fields}, nil
+}
+
+// Apply sets the result columns; rows are kept as they are
+func (fs *FieldsStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	return &Result{Columns: fs.Fields, Rows: in.Rows, Aggregated: in.Aggregated}, nil
+}
+
+// String returns the stage as written
+func (fs *FieldsStage) String() string {
+	return "fields " + strings.Join(fs.Fields, ", ")
+}
+
+// DedupStage keeps the first row for each combination of field values
+type DedupStage struct {
+	Fields []string
+}
+
+// parseDedup parses: dedup field {, field}
+func parseDedup(s *scanner) (Stage, error) {
+	fields, err := s.fieldList("a field to deduplicate on")
+	if err != nil {
+		return nil, err
+	}
+	return &DedupStage{Fields: fields}, nil
+}
+
+// Apply drops repeated rows; rows missing every field are dropped too
+func (d *DedupStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	seen := make(map[string]bool)
+	out := &Result{Columns: in.Columns, Aggregated: in.Aggregated}
+
+	for _, row := range in.Rows {
+		key := make([]string, len(d.Fields))
+		present := false
+		for i, field := range d.Fields {
+			key[i] = ctx.Value(row, field)
+			present = present || key[i] != ""
+		}
+		id := strings.Join(key, "\x00")
+		if !present || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out.Rows = append(out.Rows, row)
+	}
+
+	return out, nil
+}
+
+// String returns the stage as written
+func (d *DedupStage) String() string {
+	return "dedup " + strings.Join(d.Fields, ", ")
+}
+
+// newRow creates a synthetic line for an aggregated row. Its built-in
+// attributes are left empty so source, level and the like are read from the
+// row's values
+func newRow(index int, columns []string, values map[string]interface{}) *models.LogLine {
+	cells := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = parser.FormatValue(values[column])
+	}
+
+	return &models.LogLine{
+		ID:      fmt.Sprintf("row:%d", index+1),
+		Raw:     strings.Join(cells, "  "),
+		Parsed:  values,
+		LineNum: index + 1,
+	}
+}
+
+// compareValues compares two values numerically when both are numbers, or
+// quantities of the same unit
+func compareValues(a, b string) int {
+	x, unitA, okA := filter.ParseQuantity(a)
+	y, unitB, okB := filter.ParseQuantity(b)
+	if okA && okB && unitA == unitB {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// compareKeys compares group keys field by field
+func compareKeys(a, b []string) int {
+	for i := range a {
+		if cmp := compareValues(a[i], b[i]); cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
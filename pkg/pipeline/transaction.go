@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// transactionTimeFormat shows transaction start times to the millisecond
+const transactionTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// TransactionStage groups lines sharing field values, across sources, into
+// one row per transaction
+type TransactionStage struct {
+	Fields  []string
+	MaxSpan time.Duration // Longest transaction; a later line starts a new one. Zero means no limit.
+}
+
+// parseTransaction parses: transaction field {, field} [maxspan=duration]
+func parseTransaction(s *scanner) (Stage, error) {
+	fields, err := s.fieldList("a field to group by")
+	if err != nil {
+		return nil, err
+	}
+	stage := &TransactionStage{Fields: fields}
+
+	if s.acceptKeyword("maxspan") {
+		if !s.accept("=") {
+			return nil, s.errorf(s.peek().pos, "expected '=' after maxspan")
+		}
+		tok := s.next()
+		span, err := time.ParseDuration(tok.text)
+		if tok.kind != tokIdent || err != nil || span <= 0 {
+			return nil, s.errorf(tok.pos, "expected a duration such as 30s after maxspan=")
+		}
+		stage.MaxSpan = span
+	}
+
+	return stage, nil
+}
+
+// transaction accumulates the lines of one group
+type transaction struct {
+	key        []string
+	start, end time.Time
+	lines      int
+	hasError   bool
+	sources    []string
+}
+
+// Apply orders lines by time and groups them; lines missing every field
+// are dropped. Durations are reported in seconds.
+func (t *TransactionStage) Apply(in *Result, ctx *Context) (*Result, error) {
+	rows := make([]*models.LogLine, len(in.Rows))
+	copy(rows, in.Rows)
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Timestamp.Before(rows[j].Timestamp)
+	})
+
+	open := make(map[string]*transaction)
+	var all []*transaction
+
+	for _, row := range rows {
+		key := make([]string, len(t.Fields))
+		present := false
+		for i, field := range t.Fields {
+			key[i] = ctx.Value(row, field)
+			present = present || key[i] != ""
+		}
+		if !present {
+			continue
+		}
+
+		id := strings.Join(key, "\x00")
+		tx, ok := open[id]
+		if ok && t.MaxSpan > 0 && !tx.start.IsZero() && row.Timestamp.Sub(tx.start) > t.MaxSpan {
+			ok = false
+		}
+		if !ok {
+			tx = &transaction{key: key}
+			open[id] = tx
+			all = append(all, tx)
+		}
+		tx.add(row)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		if !all[i].start.Equal(all[j].start) {
+			return all[i].start.Before(all[j].start)
+		}
+		return compareKeys(all[i].key, all[j].key) < 0
+	})
+
+	columns := append(append([]string{}, t.Fields...), "start", "duration", "line_count", "has_error", "sources")
+	out := &Result{Columns: columns, Aggregated: true}
+	for _, tx := range all {
+		values := map[string]interface{}{
+			"duration":   filter.FormatQuantity(tx.end.Sub(tx.start).Seconds(), filter.UnitSeconds),
+			"line_count": float64(tx.lines),
+			"has_error":  tx.hasError,
+			"sources":    strings.Join(tx.sources, ","),
+		}
+		if !tx.start.IsZero() {
+			values["start"] = tx.start.Format(transactionTimeFormat)
+		}
+		for i, field := range t.Fields {
+			values[field] = tx.key[i]
+		}
+
+		row := newRow(len(out.Rows), columns, values)
+		row.Timestamp = tx.start
+		out.Rows = append(out.Rows, row)
+	}
+
+	return out, nil
+}
+
+// add extends a transaction with a line
+func (tx *transaction) add(line *models.LogLine) {
+	tx.lines++
+	if !line.Timestamp.IsZero() {
+		if tx.start.IsZero() {
+			tx.start = line.Timestamp
+		}
+		tx.end = line.Timestamp
+	}
+
+	switch strings.ToUpper(line.Level) {
+	case string(models.LevelError), string(models.LevelFatal), "PANIC", "CRITICAL":
+		tx.hasError = true
+	}
+
+	for _, source := range tx.sources {
+		if source == line.Source {
+			return
+		}
+	}
+	if line.Source != "" {
+		tx.sources = append(tx.sources, line.Source)
+	}
+}
+
+// String returns the stage as written
+func (t *TransactionStage) String() string {
+	s := "transaction " + strings.Join(t.Fields, ", ")
+	if t.MaxSpan > 0 {
+		s += fmt.Sprintf(" maxspan=%s", t.MaxSpan)
+	}
+	return s
+}
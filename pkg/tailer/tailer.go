@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -33,6 +34,10 @@ type FileWatcher struct {
 	lastModTime   time.Time
 	lineCounter   int
 	isRotating    bool
+	paused        bool
+	wake          chan struct{}      // Signals the reader that paused or the tail changed
+	ctx           context.Context    // Cancelled when the file is removed
+	cancel        context.CancelFunc
 	mu           sync.RWMutex
 }
 
@@ -64,17 +69,17 @@ func (t *Tailer) AddFile(filePath string) error {
 		return fmt.Errorf("cannot access file %s: %w", filePath, err)
 	}
 	
-	watcher := &FileWatcher{
-		path: filePath,
-	}
+	watcher := t.newWatcher(filePath)
 	
 	// Initialize file info
 	if err := watcher.updateFileInfo(); err != nil {
+		watcher.cancel()
 		return fmt.Errorf("failed to get file info for %s: %w", filePath, err)
 	}
 	
 	// Start tailing the file
 	if err := watcher.startTail(); err != nil {
+		watcher.cancel()
 		return fmt.Errorf("failed to start tailing %s: %w", filePath, err)
 	}
 	
@@ -97,7 +102,8 @@ func (t *Tailer) RemoveFile(filePath string) error {
 		return fmt.Errorf("file %s is not being watched", filePath)
 	}
 	
-	// Stop the tail
+	// Stop its goroutines, then the tail
+	watcher.cancel()
 	if watcher.tail != nil {
 		watcher.tail.Stop()
 	}
@@ -145,9 +151,90 @@ func (t *Tailer) GetWatchedFiles() []string {
 	for path := range t.files {
 		files = append(files, path)
 	}
+	sort.Strings(files)
 	return files
 }
 
+// SetPaused stops or resumes reading a file. Lines written while it is
+// paused are read once it resumes.
+func (t *Tailer) SetPaused(filePath string, paused bool) error {
+	t.mu.RLock()
+	watcher, exists := t.files[filePath]
+	t.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("file %s is not being watched", filePath)
+	}
+	
+	watcher.mu.Lock()
+	watcher.paused = paused
+	watcher.mu.Unlock()
+	
+	select {
+	case watcher.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// IsWatched reports whether a file is being watched
+func (t *Tailer) IsWatched(filePath string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, exists := t.files[filePath]
+	return exists
+}
+
+// IsPaused reports whether reading a file is paused
+func (t *Tailer) IsPaused(filePath string) bool {
+	t.mu.RLock()
+	watcher, exists := t.files[filePath]
+	t.mu.RUnlock()
+	if !exists {
+		return false
+	}
+	
+	watcher.mu.RLock()
+	defer watcher.mu.RUnlock()
+	return watcher.paused
+}
+
+// newWatcher creates a watcher whose goroutines stop with the tailer or
+// when the file is removed
+func (t *Tailer) newWatcher(filePath string) *FileWatcher {
+	ctx, cancel := context.WithCancel(t.ctx)
+	return &FileWatcher{
+		path:   filePath,
+		wake:   make(chan struct{}, 1),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// lines returns the channel of a tail to read lines from, or nil while
+// paused
+func (fw *FileWatcher) lines(t *tail.Tail) chan *tail.Line {
+	fw.mu.RLock()
+	defer fw.mu.RUnlock()
+	if fw.paused || t == nil {
+		return nil
+	}
+	return t.Lines
+}
+
+// nextTail moves the reader from a drained tail to the one that replaced
+// it after a rotation, restarting the line and byte counts with the new
+// file. It returns the drained tail when it was not replaced yet.
+func (fw *FileWatcher) nextTail(drained *tail.Tail) *tail.Tail {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if fw.tail == drained {
+		return drained
+	}
+	fw.lineCounter = 0
+	fw.lastOffset = 0
+	return fw.tail
+}
+
 // updateFileInfo updates the file information for rotation detection
 func (fw *FileWatcher) updateFileInfo() error {
 	fw.mu.Lock()
@@ -209,32 +296,44 @@ func (fw *FileWatcher) checkRotation() (bool, error) {
 	return false, nil
 }
 
-// handleRotation handles file rotation
+// handleRotation handles file rotation. The old tail is stopped without
+// waiting for the reader to drain it; the reader moves to the new tail and
+// resets the counts once it has read the old tail's last line.
 func (fw *FileWatcher) handleRotation() error {
 	fw.mu.Lock()
-	defer fw.mu.Unlock()
-	
 	fw.isRotating = true
-	defer func() { fw.isRotating = false }()
-	
-	// Stop current tail
-	if fw.tail != nil {
-		fw.tail.Stop()
-		fw.tail.Cleanup()
-	}
-	
-	// Close current file
+	old := fw.tail
 	if fw.file != nil {
 		fw.file.Close()
 	}
+	fw.mu.Unlock()
+	
+	defer func() {
+		fw.mu.Lock()
+		fw.isRotating = false
+		fw.mu.Unlock()
+	}()
+	
+	// Stop current tail; its channel closes after the lines it has read
+	if old != nil {
+		old.Kill(nil)
+		old.Cleanup()
+	}
 	
 	// Update file info
 	if err := fw.updateFileInfo(); err != nil {
 		return err
 	}
 	
-	// Restart tailing
-	return fw.startTail()
+	// Restart tailing; the new file is read from its start
+	if err := fw.startTail(); err != nil {
+		return err
+	}
+	select {
+	case fw.wake <- struct{}{}:
+	default:
+	}
+	return nil
 }
 
 // monitorFile monitors a single file for changes and rotation
@@ -248,11 +347,25 @@ func (t *Tailer) monitorFile(watcher *FileWatcher) {
 	t.wg.Add(1)
 	go func() {
 		defer t.wg.Done()
+		watcher.mu.RLock()
+		current := watcher.tail
+		watcher.mu.RUnlock()
+		drained := false
+		
 		for {
+			var lines chan *tail.Line
+			if !drained {
+				lines = watcher.lines(current)
+			}
+			
 			select {
-			case line := <-watcher.tail.Lines:
-				if line == nil {
-					return
+			case line, ok := <-lines:
+				if !ok {
+					// Stopped by a rotation; wait for the new tail
+					next := watcher.nextTail(current)
+					drained = next == current
+					current = next
+					continue
 				}
 				
 				if line.Err != nil {
@@ -263,7 +376,7 @@ func (t *Tailer) monitorFile(watcher *FileWatcher) {
 						Error:   line.Err,
 						Message: fmt.Sprintf("Error reading from %s", watcher.path),
 					}:
-					case <-t.ctx.Done():
+					case <-watcher.ctx.Done():
 						return
 					}
 					continue
@@ -272,6 +385,8 @@ func (t *Tailer) monitorFile(watcher *FileWatcher) {
 				watcher.mu.Lock()
 				watcher.lineCounter++
 				lineNum := watcher.lineCounter
+				offset := watcher.lastOffset
+				watcher.lastOffset += int64(len(line.Text)) + 1 // The newline is stripped
 				watcher.mu.Unlock()
 				
 				// Create log line
@@ -280,23 +395,29 @@ func (t *Tailer) monitorFile(watcher *FileWatcher) {
 					Source:  watcher.path,
 					Raw:     line.Text,
 					LineNum: lineNum,
-					Offset:  watcher.lastOffset,
+					Offset:  offset,
+					Arrived: time.Now(), // The parser finds the timestamp
 				}
 				
-				// Set timestamp to current time initially
-				logLine.Timestamp = time.Now()
-				
 				select {
 				case t.events <- models.TailerEvent{
 					Type:   models.EventNewLine,
 					Source: watcher.path,
 					Line:   logLine,
 				}:
-				case <-t.ctx.Done():
+				case <-watcher.ctx.Done():
 					return
 				}
 				
-			case <-t.ctx.Done():
+			case <-watcher.wake:
+				// Paused, resumed or rotated; pick the channel again
+				if drained {
+					next := watcher.nextTail(current)
+					drained = next == current
+					current = next
+				}
+				
+			case <-watcher.ctx.Done():
 				return
 			}
 		}
@@ -324,7 +445,7 @@ func (t *Tailer) monitorFile(watcher *FileWatcher) {
 					Error:   err,
 					Message: fmt.Sprintf("Error checking rotation for %s", watcher.path),
 				}:
-				case <-t.ctx.Done():
+				case <-watcher.ctx.Done():
 					return
 				}
 				continue
@@ -338,7 +459,7 @@ func (t *Tailer) monitorFile(watcher *FileWatcher) {
 					Source:  watcher.path,
 					Message: fmt.Sprintf("File %s has been rotated", watcher.path),
 				}:
-				case <-t.ctx.Done():
+				case <-watcher.ctx.Done():
 					return
 				}
 				
@@ -351,13 +472,13 @@ func (t *Tailer) monitorFile(watcher *FileWatcher) {
 						Error:   err,
 						Message: fmt.Sprintf("Error handling rotation for %s", watcher.path),
 					}:
-					case <-t.ctx.Done():
+					case <-watcher.ctx.Done():
 						return
 					}
 				}
 			}
 			
-		case <-t.ctx.Done():
+		case <-watcher.ctx.Done():
 			return
 		}
 	}
@@ -370,6 +491,7 @@ func (t *Tailer) TailFromStart(filePath string) error {
 	
 	// Remove if already watching
 	if watcher, exists := t.files[filePath]; exists {
+		watcher.cancel()
 		if watcher.tail != nil {
 			watcher.tail.Stop()
 		}
@@ -384,12 +506,11 @@ func (t *Tailer) TailFromStart(filePath string) error {
 		return fmt.Errorf("cannot access file %s: %w", filePath, err)
 	}
 	
-	watcher := &FileWatcher{
-		path: filePath,
-	}
+	watcher := t.newWatcher(filePath)
 	
 	// Initialize file info
 	if err := watcher.updateFileInfo(); err != nil {
+		watcher.cancel()
 		return fmt.Errorf("failed to get file info for %s: %w", filePath, err)
 	}
 	
@@ -404,6 +525,7 @@ func (t *Tailer) TailFromStart(filePath string) error {
 	
 	tail, err := tail.TailFile(filePath, config)
 	if err != nil {
+		watcher.cancel()
 		return fmt.Errorf("failed to start tailing %s: %w", filePath, err)
 	}
 	
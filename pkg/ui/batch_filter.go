@@ -53,14 +53,32 @@ func (sb *SimpleBatcher) processBatch(m *Model) {
 	// Process all lines in the batch at once
 	for _, line := range sb.pendingLines {
 		// Add to all lines buffer
+		m.trackFacets(m.allLinesBuffer, line)
+		m.trackHistogram(m.allLinesBuffer, line)
 		m.allLinesBuffer.Add(line)
+		m.indexLine(line)
+		if m.timeline != nil {
+			m.timeline.push(line, startTime)
+		}
+		m.routeToTabs(line)
+		
+		// Lines arriving during a re-filter wait for it to finish so the
+		// filtered pane stays in order
+		if m.refilter != nil {
+			m.refilter.backlog = append(m.refilter.backlog, line)
+			continue
+		}
 		
-		// Check if filter is active and line matches
-		if m.filter.HasFilter() && m.filter.Match(line) {
-			m.filteredBuffer.Add(line)
-			matchedCount++
+		// Check if filter is active and line matches; sequence queries may
+		// release several earlier lines at once
+		if m.filter.HasFilter() && m.inFilterScope(line) {
+			for _, matched := range m.filter.Feed(line) {
+				m.addFiltered(matched)
+				matchedCount++
+			}
 		}
 	}
+	m.releaseTimeline()
 	
 	// Show batch processing stats
 	if len(sb.pendingLines) >= sb.batchSize {
@@ -86,64 +104,3 @@ func (sb *SimpleBatcher) ForceBatch(m *Model) {
 	defer sb.mutex.Unlock()
 	sb.processBatch(m)
 }
-
-// ProcessAllExistingLines processes all existing lines in 1000-line batches
-func (m *Model) ProcessAllExistingLines() error {
-	if !m.filter.HasFilter() {
-		m.filteredBuffer.Clear()
-		return nil
-	}
-	
-	totalLines := m.allLinesBuffer.Size()
-	if totalLines == 0 {
-		return nil
-	}
-	
-	startTime := time.Now()
-	m.setStatusMessage(fmt.Sprintf("⚡ Processing %d existing lines in batches...", totalLines))
-	
-	// Clear filtered buffer first
-	m.filteredBuffer.Clear()
-	
-	// Process in batches of 1000 lines
-	batchSize := 1000
-	totalMatches := 0
-	
-	for start := 0; start < totalLines; start += batchSize {
-		end := start + batchSize
-		if end > totalLines {
-			end = totalLines
-		}
-		
-		// Process this batch
-		batchMatches := 0
-		for i := start; i < end; i++ {
-			line := m.allLinesBuffer.Get(i)
-			if line != nil && m.filter.Match(line) {
-				m.filteredBuffer.Add(line)
-				batchMatches++
-				totalMatches++
-			}
-		}
-		
-		// Update progress for large batches
-		if totalLines > 5000 && start > 0 && start%5000 == 0 {
-			progress := int(float64(start) / float64(totalLines) * 100)
-			m.setStatusMessage(fmt.Sprintf("⚡ Processing... %d%% (%d matches so far)", 
-				progress, totalMatches))
-		}
-	}
-	
-	// Final result
-	duration := time.Since(startTime)
-	if duration.Milliseconds() > 0 {
-		linesPerSec := int64(float64(totalLines) / duration.Seconds())
-		m.setStatusMessage(fmt.Sprintf("⚡ Found %d/%d matches in %v (%dk lines/sec)", 
-			totalMatches, totalLines, duration.Round(time.Millisecond), linesPerSec/1000))
-	} else {
-		m.setStatusMessage(fmt.Sprintf("⚡ Found %d/%d matches instantly!", 
-			totalMatches, totalLines))
-	}
-	
-	return nil
-}
@@ -3,6 +3,7 @@ package ui
 import (
 	"strings"
 	"sync"
+	"time"
 	"github.com/loganalyzer/traceace/pkg/models"
 )
 
@@ -114,6 +115,17 @@ func (cb *CircularBuffer) Clear() {
 	}
 }
 
+// Truncate drops the newest lines beyond the given size
+func (cb *CircularBuffer) Truncate(size int) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	for ; cb.size > max(0, size); cb.size-- {
+		cb.tail = (cb.tail - 1 + cb.capacity) % cb.capacity
+		cb.data[cb.tail] = nil
+	}
+}
+
 // ForEach applies a function to each element in the buffer
 func (cb *CircularBuffer) ForEach(fn func(*models.LogLine) bool) {
 	cb.mu.RLock()
@@ -127,6 +139,52 @@ func (cb *CircularBuffer) ForEach(fn func(*models.LogLine) bool) {
 	}
 }
 
+// SearchTime returns the index of the line whose timestamp is nearest to t,
+// or -1 when no line has one. Timestamps are binary searched as if
+// ascending, then the lines within window of the result are scanned, so
+// lines slightly out of order, such as those of merged sources, still land
+// on the nearest
+func (cb *CircularBuffer) SearchTime(t time.Time, window int) int {
+	cb.mu.RLock()
+	defer cb.mu.RUnlock()
+	
+	at := func(i int) time.Time {
+		return cb.data[(cb.head+i)%cb.capacity].Timestamp
+	}
+	
+	// First line at or after t, skipping lines without a timestamp
+	lo, hi := 0, cb.size
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		j := mid
+		for j < hi && at(j).IsZero() {
+			j++
+		}
+		if j < hi && at(j).Before(t) {
+			lo = j + 1
+		} else {
+			hi = mid
+		}
+	}
+	
+	best := -1
+	var bestDiff time.Duration
+	for i := max(0, lo-window); i < min(cb.size, lo+window+1); i++ {
+		ts := at(i)
+		if ts.IsZero() {
+			continue
+		}
+		diff := ts.Sub(t)
+		if diff < 0 {
+			diff = -diff
+		}
+		if best < 0 || diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	return best
+}
+
 // ObjectPool provides pooling for LogLine objects to reduce allocations
 type ObjectPool struct {
 	logLinePool   sync.Pool
@@ -0,0 +1,384 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/config"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
+)
+
+const (
+	// maxSuggestedColumns caps the columns suggested for a source
+	maxSuggestedColumns = 6
+
+	// suggestSampleLines is how many recent lines are sampled for keys
+	suggestSampleLines = 1000
+
+	// minColumnWidth is the narrowest a column can be resized to
+	minColumnWidth = 3
+)
+
+// columnView shows the lines of the panes as aligned columns of fields
+type columnView struct {
+	source   string // Source the columns are saved for
+	fields   []string
+	widths   []int // Set by resizing; 0 fits the visible values
+	fit      []int // Widths of the last render
+	selected int
+}
+
+// toggleColumns switches the panes between raw lines and columns
+func (m *Model) toggleColumns() {
+	if m.columns != nil {
+		m.columns = nil
+		m.setStatusMessage("Showing raw lines")
+		return
+	}
+
+	m.loadColumns(m.columnSource())
+	m.setStatusMessage(fmt.Sprintf("Columns for %s: %s", m.columns.source, strings.Join(m.columns.fields, ", ")))
+}
+
+// columnSource picks the source whose columns to show: the file of a
+// source tab, else that of the selected line
+func (m *Model) columnSource() string {
+	if tab := m.activeTab(); tab.kind == tabSource {
+		return tab.source
+	}
+	pane := m.getActivePane()
+	if line := m.getActiveBuffer().Get(pane.scrollY + pane.cursorY); line != nil {
+		return line.Source
+	}
+	if files := m.tailer.GetWatchedFiles(); len(files) > 0 {
+		return files[0]
+	}
+	return ""
+}
+
+// loadColumns shows the saved columns of a source, or suggests some from
+// its most frequent fields
+func (m *Model) loadColumns(source string) {
+	view := &columnView{source: source}
+	if set, ok := m.config.ColumnsFor(source); ok && len(set.Fields) > 0 {
+		view.fields = append(view.fields, set.Fields...)
+		view.widths = make([]int, len(set.Fields))
+		copy(view.widths, set.Widths)
+	} else {
+		view.fields = m.suggestColumns(source)
+		view.widths = make([]int, len(view.fields))
+	}
+	m.columns = view
+}
+
+// suggestColumns returns the most frequent top-level keys of a source's
+// recent lines, time and level first; plain text lines get the built-in
+// attributes
+func (m *Model) suggestColumns(source string) []string {
+	counts := make(map[string]int)
+	sampled := 0
+	for i := m.allLinesBuffer.Size() - 1; i >= 0 && sampled < suggestSampleLines; i-- {
+		line := m.allLinesBuffer.Get(i)
+		if line == nil || (source != "" && line.Source != source) {
+			continue
+		}
+		sampled++
+		for key := range line.Parsed {
+			counts[key]++
+		}
+	}
+	if len(counts) == 0 {
+		return []string{"timestamp", "level", "message"}
+	}
+
+	rank := func(key string) int {
+		switch strings.ToLower(key) {
+		case "timestamp", "time", "ts", "@timestamp":
+			return 0
+		case "level", "severity", "lvl":
+			return 1
+		}
+		return 2
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if rank(keys[i]) != rank(keys[j]) {
+			return rank(keys[i]) < rank(keys[j])
+		}
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	fields := make([]string, 0, maxSuggestedColumns)
+	for _, key := range keys[:min(len(keys), maxSuggestedColumns)] {
+		fields = append(fields, parser.AppendKey("", key))
+	}
+	return fields
+}
+
+// saveColumns stores the columns of the current source in the config
+func (m *Model) saveColumns() {
+	set := config.ColumnSet{Source: m.columns.source, Fields: m.columns.fields, Widths: m.columns.widths}
+	if err := m.config.SetColumns(set); err != nil {
+		m.setStatusMessage(fmt.Sprintf("Failed to save columns: %s", err.Error()))
+	}
+}
+
+// addColumn appends a field to the columns, turning the column view on
+func (m *Model) addColumn(field string) {
+	if m.columns == nil {
+		m.loadColumns(m.columnSource())
+	}
+	for i, existing := range m.columns.fields {
+		if existing == field {
+			m.columns.selected = i
+			m.setStatusMessage(fmt.Sprintf("%s is already a column", field))
+			return
+		}
+	}
+
+	m.columns.fields = append(m.columns.fields, field)
+	m.columns.widths = append(m.columns.widths, 0)
+	m.columns.selected = len(m.columns.fields) - 1
+	m.saveColumns()
+	m.setStatusMessage(fmt.Sprintf("Added column %s for %s", field, m.columns.source))
+}
+
+// updateColumns runs the actions that select, resize, sort and remove
+// columns
+func (m *Model) updateColumns(action string) tea.Cmd {
+	cv := m.columns
+	if cv == nil || len(cv.fields) == 0 {
+		m.setStatusMessage("No columns; press v for the column view")
+		return nil
+	}
+
+	switch action {
+	case "column_prev":
+		cv.selected = max(0, cv.selected-1)
+	case "column_next":
+		cv.selected = min(len(cv.fields)-1, cv.selected+1)
+	case "column_narrow", "column_widen":
+		width := cv.widths[cv.selected]
+		if width == 0 && cv.selected < len(cv.fit) {
+			width = cv.fit[cv.selected]
+		}
+		if action == "column_narrow" {
+			width = max(minColumnWidth, width-2)
+		} else {
+			width += 2
+		}
+		cv.widths[cv.selected] = width
+		m.saveColumns()
+	case "column_remove":
+		field := cv.fields[cv.selected]
+		cv.fields = append(cv.fields[:cv.selected], cv.fields[cv.selected+1:]...)
+		cv.widths = append(cv.widths[:cv.selected], cv.widths[cv.selected+1:]...)
+		cv.selected = max(0, min(cv.selected, len(cv.fields)-1))
+		m.saveColumns()
+		m.setStatusMessage(fmt.Sprintf("Removed column %s", field))
+	case "column_sort":
+		return m.sortByColumn(cv.fields[cv.selected])
+	}
+	return nil
+}
+
+// sortStage returns the sort stage ending the current pipeline, if any
+func (m *Model) sortStage() string {
+	if m.pipeline == nil {
+		return ""
+	}
+	last := m.pipeline.Stages[len(m.pipeline.Stages)-1]
+	if _, ok := last.(*pipeline.SortStage); !ok {
+		return ""
+	}
+	return last.String()
+}
+
+// sortByColumn sorts the filtered lines by a column, ascending, then
+// descending, then back to their order, with a sort stage on the query
+func (m *Model) sortByColumn(field string) tea.Cmd {
+	query := m.filter.GetLastOptions().Query
+	if m.pipeline != nil {
+		query = m.pipeline.Query
+	}
+	pl, err := pipeline.Parse(query)
+	if err != nil {
+		m.setStatusMessage(fmt.Sprintf("Sort error: %s", err.Error()))
+		return nil
+	}
+
+	current := m.sortStage()
+	stages := pl.Stages
+	if current != "" {
+		stages = stages[:len(stages)-1]
+	}
+
+	var next string
+	switch current {
+	case "sort " + field:
+		next = "sort " + sortField("-"+field)
+	case "sort -" + field:
+	default:
+		next = "sort " + sortField(field)
+	}
+
+	parts := []string{strings.TrimSpace(pl.Filter)}
+	for _, stage := range stages {
+		parts = append(parts, stage.String())
+	}
+	if next != "" {
+		parts = append(parts, next)
+	}
+
+	// Sorting without a filter sorts every line; unsorting clears it again
+	switch {
+	case len(parts) == 1 && (parts[0] == "" || parts[0] == "*"):
+		m.searchInput = ""
+	case parts[0] == "":
+		m.searchInput = "* | " + strings.Join(parts[1:], " | ")
+	default:
+		m.searchInput = strings.Join(parts, " | ")
+	}
+	m.searchCursor = len(m.searchInput)
+	cmd, err := m.applySearch()
+	if err != nil {
+		m.setStatusMessage(fmt.Sprintf("Sort error: %s", err.Error()))
+		return nil
+	}
+	if next == "" {
+		m.setStatusMessage("Sort removed")
+	} else {
+		m.setStatusMessage("Filtered lines: " + next)
+	}
+	return cmd
+}
+
+// sortField writes a field for a sort stage, quoting it when it holds
+// characters that end a stage argument
+func sortField(field string) string {
+	if strings.ContainsAny(field, " \t,()=\"") {
+		return `"` + strings.ReplaceAll(field, `"`, `\"`) + `"`
+	}
+	return field
+}
+
+// columnCell returns the value of a column on one display line
+func (m *Model) columnCell(line *models.LogLine, field string) string {
+	return strings.NewReplacer("\n", " ", "\t", " ").Replace(m.tableCell(line, field))
+}
+
+// showsColumns reports whether a pane renders its lines as columns
+func (m *Model) showsColumns(pane *LogPane) bool {
+	if m.columns == nil {
+		return false
+	}
+	return pane != m.filteredPane || m.pipeline == nil || len(m.resultColumns) == 0
+}
+
+// renderColumnContent renders the visible lines of a pane as columns
+// under a header naming them
+func (m *Model) renderColumnContent(pane *LogPane, height int, buffer *CircularBuffer) string {
+	cv := m.columns
+	startIdx := max(0, min(pane.scrollY, buffer.Size()-1))
+	lines := buffer.GetRange(startIdx, startIdx+height-1)
+
+	cells := make([][]string, len(lines))
+	for r, line := range lines {
+		cells[r] = make([]string, len(cv.fields))
+		for i, field := range cv.fields {
+			cells[r][i] = m.columnCell(line, field)
+		}
+	}
+	widths := fitColumns(cv.fields, cv.widths, cells)
+	cv.fit = widths
+
+	gutter := ""
+	if buffer == m.timelineBuffer {
+		gutter = "  "
+	}
+	content := []string{"  " + gutter + m.renderColumnHeader(widths)}
+
+	maxWidth := pane.width - 4
+	for r, line := range lines {
+		prefix := "  "
+		if pane.showCursor && r == pane.cursorY {
+			prefix = "> "
+		}
+		row := formatTableRow(cells[r], widths)
+		if maxWidth > 10 {
+			row = truncateText(row, maxWidth-len(gutter))
+		}
+		if buffer == m.timelineBuffer {
+			row = m.sourceGutter(line) + row
+		}
+		content = append(content, prefix+row)
+	}
+
+	if len(lines) == 0 {
+		content = append(content, "  No logs")
+	}
+	for len(content) < height {
+		content = append(content, "")
+	}
+	return strings.Join(content, "\n")
+}
+
+// fitColumns returns the width of each column: the width it was resized to,
+// else its name, with room for the sort marker, or its widest value, up to
+// maxColumnWidth
+func fitColumns(fields []string, resized []int, cells [][]string) []int {
+	widths := make([]int, len(fields))
+	for i, field := range fields {
+		if resized[i] > 0 {
+			widths[i] = resized[i]
+			continue
+		}
+		widths[i] = utf8.RuneCountInString(field) + 2
+		for _, row := range cells {
+			widths[i] = max(widths[i], utf8.RuneCountInString(row[i]))
+		}
+		widths[i] = min(widths[i], maxColumnWidth)
+	}
+	return widths
+}
+
+// renderColumnHeader renders the column names with the selected one
+// highlighted and the sorted one marked
+func (m *Model) renderColumnHeader(widths []int) string {
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#00ffff"))
+	selectedStyle := headerStyle.Copy().Reverse(true)
+
+	sorted := m.sortStage()
+	parts := make([]string, len(m.columns.fields))
+	for i, field := range m.columns.fields {
+		name := field
+		switch sorted {
+		case "sort " + field:
+			name += " ▲"
+		case "sort -" + field:
+			name += " ▼"
+		}
+		if runes := []rune(name); len(runes) > widths[i] {
+			name = string(runes[:widths[i]])
+		}
+		cell := fmt.Sprintf("%-*s", widths[i], name)
+		if i == m.columns.selected {
+			parts[i] = selectedStyle.Render(cell)
+		} else {
+			parts[i] = headerStyle.Render(cell)
+		}
+	}
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,71 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+func TestColumnCell(t *testing.T) {
+	m := newTestModel(t, 0)
+	line := &models.LogLine{Raw: `{"level":"WARN","msg":"first\nsecond\tthird","tags":["a","b"],"http":{"status":503}}`, Source: "app.log"}
+	m.parser.ParseLogLine(line)
+
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{"level", "WARN"},
+		{"msg", "first second third"},
+		{"tags[*]", "a;b"},
+		{"http.status", "503"},
+		{"source", "app.log"},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := m.columnCell(line, tt.field); got != tt.want {
+			t.Errorf("columnCell(%s): expected %q, got %q", tt.field, tt.want, got)
+		}
+	}
+}
+
+func TestFitColumns(t *testing.T) {
+	fields := []string{"level", "user", "msg", "status"}
+	cells := [][]string{
+		{"INFO", "jürgen", strings.Repeat("x", 100), "200"},
+		{"WARN", "ünïcödé_üser", "short", "404"},
+	}
+
+	got := fmt.Sprint(fitColumns(fields, []int{0, 0, 0, 9}, cells))
+	// Names with room for the sort marker, values counted in characters,
+	// long values capped and a resized column kept as set
+	if want := fmt.Sprint([]int{7, 12, maxColumnWidth, 9}); got != want {
+		t.Errorf("Expected widths %s, got %s", want, got)
+	}
+}
+
+func TestColumnRowsKeepMultibyteValues(t *testing.T) {
+	m := newTestModel(t, 0)
+	buffer := NewCircularBuffer(10)
+	for i := 0; i < 3; i++ {
+		line := &models.LogLine{Raw: fmt.Sprintf(`{"user":"jürgen-%d","msg":"%s"}`, i, strings.Repeat("ü", 60)), Source: "app.log"}
+		m.parser.ParseLogLine(line)
+		buffer.Add(line)
+	}
+	m.columns = &columnView{fields: []string{"user", "msg"}, widths: []int{0, 0}}
+
+	for width := 15; width < 60; width += 7 {
+		pane := &LogPane{width: width}
+		for _, row := range strings.Split(m.renderColumnContent(pane, 5, buffer), "\n")[1:] {
+			if !utf8.ValidString(row) {
+				t.Fatalf("Width %d: row %q splits a character", width, row)
+			}
+			if n := utf8.RuneCountInString(row); n > width-2 {
+				t.Errorf("Width %d: row of %d characters: %q", width, n, row)
+			}
+		}
+	}
+}
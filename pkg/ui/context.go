@@ -0,0 +1,312 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+const (
+	// contextRefreshInterval throttles rebuilding the context view while
+	// lines stream in
+	contextRefreshInterval = 250 * time.Millisecond
+
+	// contextExpandStep is how many lines + adds on each side of a match
+	contextExpandStep = 5
+
+	// contextScanLimit bounds how far the lines of other sources are
+	// skipped looking for context from the same source
+	contextScanLimit = 10000
+)
+
+// contextSeparator is the row drawn between groups of the context view
+var contextSeparator = &models.LogLine{Raw: "--"}
+
+// contextView shows each filtered line with the lines before and after it
+// from the same source, grep -C style. The rows are laid out as matches
+// come in: only the trailing matches whose following lines have not all
+// arrived yet are laid out again.
+type contextView struct {
+	lines   int                     // Context lines on each side of every match
+	off     bool                    // Turned off with C
+	extra   map[*models.LogLine]int // More lines around single matches
+	buffer  *CircularBuffer         // Matches, context lines and separators
+	dirty   bool
+	builtAt time.Time
+
+	added    []*models.LogLine // Matches not laid out yet
+	open     []*models.LogLine // Laid out matches still missing lines after them
+	openRows int               // Rows of the open matches, at the end of buffer
+	settled  contextLayout     // Layout before the open matches
+}
+
+// contextLayout is where laying out the matches got to
+type contextLayout struct {
+	lastEnd    map[string]uint32 // Seq of the last line shown per source
+	lastSource string
+	rows       int
+}
+
+// clone copies a layout
+func (l contextLayout) clone() contextLayout {
+	lastEnd := make(map[string]uint32, len(l.lastEnd))
+	for source, end := range l.lastEnd {
+		lastEnd[source] = end
+	}
+	return contextLayout{lastEnd: lastEnd, lastSource: l.lastSource, rows: l.rows}
+}
+
+// newContextView creates the context view with the configured lines
+func newContextView(lines int) *contextView {
+	return &contextView{lines: max(0, lines), extra: make(map[*models.LogLine]int)}
+}
+
+// showsContext reports whether the filtered pane shows context lines
+func (m *Model) showsContext() bool {
+	cv := m.matchContext
+	return !cv.off && (cv.lines > 0 || len(cv.extra) > 0) && m.filter.HasFilter() && m.pipeline == nil
+}
+
+// contextBuffer returns the rows of the context view, building them when
+// the matches changed
+func (m *Model) contextBuffer() *CircularBuffer {
+	cv := m.matchContext
+	if cv.buffer == nil {
+		m.buildContext()
+	}
+	return cv.buffer
+}
+
+// resetContext drops the context view after the filter changed
+func (m *Model) resetContext() {
+	m.matchContext.buffer = nil
+	m.matchContext.extra = make(map[*models.LogLine]int)
+}
+
+// addContextMatch queues a new match for the context view
+func (m *Model) addContextMatch(line *models.LogLine) {
+	cv := m.matchContext
+	cv.dirty = true
+	if cv.buffer != nil {
+		cv.added = append(cv.added, line)
+	}
+}
+
+// refreshContext lays out the new matches and lines of the context view,
+// at most once per refresh interval
+func (m *Model) refreshContext() {
+	cv := m.matchContext
+	if cv.buffer != nil && cv.dirty && m.refilter == nil && time.Since(cv.builtAt) >= contextRefreshInterval {
+		m.extendContext()
+	}
+}
+
+// toggleContext turns the context lines off and on again
+func (m *Model) toggleContext() {
+	cv := m.matchContext
+	cv.off = !cv.off
+	cv.buffer = nil
+	m.filteredPane.scrollY, m.filteredPane.cursorY = 0, 0
+	switch {
+	case cv.off:
+		m.setStatusMessage("Context lines off")
+	case cv.lines == 0:
+		m.setStatusMessage("No context lines set; press + on a match to add some")
+	default:
+		m.setStatusMessage(fmt.Sprintf("Showing %d context lines around matches", cv.lines))
+	}
+}
+
+// expandContext adds lines around the selected match of the filtered pane,
+// or removes the ones added before
+func (m *Model) expandContext(more bool) {
+	if m.activePane != PaneFiltered || !m.filter.HasFilter() || m.pipeline != nil {
+		m.setStatusMessage("Select a match in the filtered pane to show more context")
+		return
+	}
+	cv := m.matchContext
+	cv.off = false
+	buffer := m.filteredView()
+	pane := m.filteredPane
+	cursor := pane.scrollY + pane.cursorY
+
+	// Use the match under the cursor, else the nearest one above it
+	var match *models.LogLine
+	for i := cursor; i >= 0 && match == nil; i-- {
+		if line := buffer.Get(i); line != nil && line != contextSeparator && m.filter.Match(line) {
+			match = line
+		}
+	}
+	if match == nil {
+		m.setStatusMessage("No match selected")
+		return
+	}
+
+	if more {
+		cv.extra[match] += contextExpandStep
+	} else if cv.extra[match] > contextExpandStep {
+		cv.extra[match] -= contextExpandStep
+	} else {
+		delete(cv.extra, match)
+	}
+	m.buildContext()
+	m.setStatusMessage(fmt.Sprintf("%d context lines around this match", cv.lines+cv.extra[match]))
+
+	// Keep the cursor on the line it was on
+	selected := buffer.Get(cursor)
+	rows := m.filteredView()
+	for i := 0; i < rows.Size(); i++ {
+		if rows.Get(i) == selected {
+			m.scrollToLine(i)
+			break
+		}
+	}
+}
+
+// bufferPosition returns the index of a line in the buffer of every line
+func (m *Model) bufferPosition(line *models.LogLine) (int, bool) {
+	size := m.allLinesBuffer.Size()
+	oldest := m.searchIndex.Next() - uint32(size)
+	pos := int(line.Seq - oldest)
+	if line.Seq < oldest || pos >= size || m.allLinesBuffer.Get(pos) != line {
+		return 0, false
+	}
+	return pos, true
+}
+
+// sourceNeighbors returns the positions of up to n lines of a source next
+// to a position, before it (dir -1) or after it (dir 1), nearest first
+func (m *Model) sourceNeighbors(pos int, source string, dir, n int) []int {
+	var found []int
+	size := m.allLinesBuffer.Size()
+	for p := pos + dir; p >= 0 && p < size && len(found) < n && (p-pos)*dir <= contextScanLimit; p += dir {
+		if line := m.allLinesBuffer.Get(p); line != nil && line.Source == source {
+			found = append(found, p)
+		}
+	}
+	return found
+}
+
+// buildContext lays out every match with its context lines
+func (m *Model) buildContext() {
+	cv := m.matchContext
+	cv.buffer = NewCircularBuffer(m.maxBufferSize)
+	cv.added = make([]*models.LogLine, 0, m.filteredBuffer.Size())
+	m.filteredBuffer.ForEach(func(match *models.LogLine) bool {
+		cv.added = append(cv.added, match)
+		return true
+	})
+	cv.open, cv.openRows = nil, 0
+	cv.settled = contextLayout{lastEnd: make(map[string]uint32)}
+	m.extendContext()
+}
+
+// extendContext lays out the open matches again, followed by the new ones.
+// Matches are settled in order once every line after them is shown.
+func (m *Model) extendContext() {
+	cv := m.matchContext
+	cv.dirty = false
+	cv.builtAt = time.Now()
+
+	cv.buffer.Truncate(cv.buffer.Size() - cv.openRows)
+	matches := append(cv.open, cv.added...)
+	cv.open, cv.added, cv.openRows = nil, nil, 0
+
+	layout := cv.settled.clone()
+	for _, match := range matches {
+		rows := layout.rows
+		complete := m.layoutMatch(&layout, match)
+		if len(cv.open) == 0 && complete {
+			cv.settled = layout.clone()
+			continue
+		}
+		cv.open = append(cv.open, match)
+		cv.openRows += layout.rows - rows
+	}
+}
+
+// layoutMatch adds a match with its context lines to the rows. Groups of
+// the same source whose context touches are merged, and a separator is
+// drawn between the others. It reports whether all the lines after the
+// match are shown.
+func (m *Model) layoutMatch(layout *contextLayout, match *models.LogLine) bool {
+	cv := m.matchContext
+	emit := func(line *models.LogLine) {
+		cv.buffer.Add(line)
+		layout.rows++
+	}
+
+	n := cv.lines + cv.extra[match]
+	pos, ok := m.bufferPosition(match)
+	if !ok {
+		// Evicted from the buffer; shown alone
+		if layout.rows > 0 {
+			emit(contextSeparator)
+		}
+		emit(match)
+		layout.lastSource = ""
+		return true
+	}
+
+	seq := func(p int) uint32 { return m.allLinesBuffer.Get(p).Seq }
+	end, seen := layout.lastEnd[match.Source]
+	shown := func(s uint32) bool { return seen && s <= end }
+	showAfter := func() bool {
+		after := m.sourceNeighbors(pos, match.Source, 1, n)
+		for _, p := range after {
+			if !shown(seq(p)) {
+				emit(m.allLinesBuffer.Get(p))
+				end, seen = seq(p), true
+			}
+		}
+		layout.lastEnd[match.Source] = end
+		return len(after) == n || m.allLinesBuffer.Size()-1-pos > contextScanLimit
+	}
+
+	if shown(match.Seq) && layout.lastSource == match.Source {
+		// Already shown as context of the previous group; extend it
+		return showAfter()
+	}
+
+	before := m.sourceNeighbors(pos, match.Source, -1, n)
+	first := pos
+	if len(before) > 0 {
+		first = before[len(before)-1]
+	}
+	previous := m.sourceNeighbors(first, match.Source, -1, 1)
+	touching := layout.lastSource == match.Source && (len(previous) == 0 || shown(seq(previous[0])))
+	if layout.rows > 0 && !touching {
+		emit(contextSeparator)
+	}
+
+	for i := len(before) - 1; i >= 0; i-- {
+		if !shown(seq(before[i])) {
+			emit(m.allLinesBuffer.Get(before[i]))
+		}
+	}
+	if !shown(match.Seq) {
+		emit(match)
+		end, seen = match.Seq, true
+	}
+	layout.lastSource = match.Source
+	return showAfter()
+}
+
+// renderContextRow renders a separator or a dimmed context line; matches
+// are rendered like any filtered line
+func (m *Model) renderContextRow(line *models.LogLine, maxWidth int) (string, bool) {
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("#666666"))
+	if line == contextSeparator {
+		return dim.Render("--"), true
+	}
+	if m.filter.Match(line) {
+		return "", false
+	}
+	text := line.Raw
+	if maxWidth > 10 && len(text) > maxWidth {
+		text = text[:maxWidth-3] + "..."
+	}
+	return dim.Render(text), true
+}
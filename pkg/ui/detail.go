@@ -0,0 +1,351 @@
+package ui
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
+)
+
+// parsedRoot is the collapse key of the node holding the parsed fields
+const parsedRoot = "#parsed"
+
+// detailRow is one line of the detail pane: a metadata attribute, or a
+// node of the parsed tree
+type detailRow struct {
+	id        string // Collapse key
+	path      string // Field to filter on; empty when it cannot be filtered
+	column    string // Field to show as a column; empty when it has none
+	label     string
+	depth     int
+	value     interface{} // Scalar value, or the map/array of a container
+	meta      bool        // Line attribute rather than a parsed field
+	container bool
+	count     int // Children of a container
+}
+
+// detailView shows the metadata and parsed fields of one line
+type detailView struct {
+	line      *models.LogLine
+	collapsed map[string]bool
+	rows      []detailRow
+	cursor    int
+	scroll    int
+	height    int // Visible rows, set while rendering
+}
+
+// openDetail shows the selected line of the active pane in the detail pane
+func (m *Model) openDetail() {
+	pane := m.getActivePane()
+	buffer := m.getActiveBuffer()
+	if pane == nil || buffer == nil || buffer.Size() == 0 {
+		m.setStatusMessage("No line selected")
+		return
+	}
+
+	line := buffer.Get(pane.scrollY + pane.cursorY)
+	if line == nil {
+		return
+	}
+
+	m.detail = &detailView{line: line, collapsed: make(map[string]bool)}
+	m.buildDetailRows()
+}
+
+// buildDetailRows lists the metadata rows, then the parsed tree without
+// the children of collapsed nodes
+func (m *Model) buildDetailRows() {
+	d := m.detail
+	line := d.line
+	d.rows = d.rows[:0]
+
+	meta := func(label, path, column, value string) {
+		d.rows = append(d.rows, detailRow{id: label, path: path, column: column, label: label, value: value, meta: true})
+	}
+	meta("source", "source", "source", line.Source)
+	meta("offset", "", "offset", strconv.FormatInt(line.Offset, 10))
+	meta("line", "", "line", strconv.Itoa(line.LineNum))
+	format := string(line.Format)
+	if format == "" {
+		format = string(models.FormatText)
+	}
+	meta("format", "", "", format)
+	if line.Level != "" {
+		meta("level", "level", "level", line.Level)
+	}
+	if !line.Timestamp.IsZero() {
+		meta("timestamp", "", "timestamp", fmt.Sprintf("%s (%s)", line.Timestamp.Format(time.RFC3339Nano), m.timestampSource(line)))
+	}
+
+	if len(line.Parsed) == 0 {
+		return
+	}
+	d.rows = append(d.rows, detailRow{id: parsedRoot, label: "parsed", value: line.Parsed, container: true, count: len(line.Parsed)})
+	if !d.collapsed[parsedRoot] {
+		m.appendDetailNode("", line.Parsed, 1)
+	}
+}
+
+// appendDetailNode adds the children of a map or array at a depth
+func (m *Model) appendDetailNode(path string, value interface{}, depth int) {
+	d := m.detail
+	add := func(childPath, label string, child interface{}) {
+		row := detailRow{id: childPath, path: childPath, column: childPath, label: label, depth: depth, value: child}
+		switch c := child.(type) {
+		case map[string]interface{}:
+			row.container, row.count = true, len(c)
+		case []interface{}:
+			row.container, row.count = true, len(c)
+		}
+		d.rows = append(d.rows, row)
+		if row.container && !d.collapsed[row.id] {
+			m.appendDetailNode(childPath, child, depth+1)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			add(detailKeyPath(path, key), key, v[key])
+		}
+	case []interface{}:
+		for i, elem := range v {
+			add(parser.AppendIndex(path, i), fmt.Sprintf("[%d]", i), elem)
+		}
+	}
+}
+
+// detailKeyPath extends a field path with a key
+func detailKeyPath(path, key string) string {
+	return parsedFieldPath(parser.AppendKey(path, key))
+}
+
+// parsedFieldPath names a parsed field so filters read it. Top-level keys
+// named like the level, source and timestamp attributes are bracketed,
+// since filters resolve those names to the attribute.
+func parsedFieldPath(path string) string {
+	switch strings.ToLower(path) {
+	case "level", "source", "timestamp":
+		return `[` + strconv.Quote(path) + `]`
+	}
+	return path
+}
+
+// timestampSource describes where the parser found a line's timestamp
+func (m *Model) timestampSource(line *models.LogLine) string {
+	if source := m.parser.TimestampSource(line); source != "" {
+		return source
+	}
+	if !line.Arrived.IsZero() && line.Timestamp.Equal(line.Arrived) {
+		return "arrival time"
+	}
+	if line.Format == "" || line.Format == models.FormatText {
+		return "previous line"
+	}
+	return "parsed from " + string(line.Format)
+}
+
+// updateDetail handles keys while the detail pane is open
+func (m *Model) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	d := m.detail
+	action, count := m.pressKey(modeDetail, msg)
+	switch action {
+	case "detail.close":
+		m.detail = nil
+	case "detail.down":
+		d.cursor = min(d.cursor+max(1, count), len(d.rows)-1)
+	case "detail.up":
+		d.cursor = max(d.cursor-max(1, count), 0)
+	case "detail.top":
+		d.cursor = 0
+	case "detail.bottom":
+		d.cursor = len(d.rows) - 1
+	case "detail.fold":
+		m.setCollapsed(!d.collapsed[d.rows[d.cursor].id])
+	case "detail.expand":
+		m.setCollapsed(false)
+	case "detail.collapse":
+		m.setCollapsed(true)
+	case "detail.include":
+		return m, m.filterOnField(false)
+	case "detail.exclude":
+		return m, m.filterOnField(true)
+	case "detail.same":
+		return m, m.showSameValue()
+	case "detail.add_column":
+		if row := d.rows[d.cursor]; row.column != "" && !row.container {
+			m.addColumn(row.column)
+		} else {
+			m.setStatusMessage(fmt.Sprintf("Cannot add %s as a column", row.label))
+		}
+	}
+	return m, nil
+}
+
+// setCollapsed folds or unfolds the container under the cursor
+func (m *Model) setCollapsed(collapsed bool) {
+	d := m.detail
+	row := d.rows[d.cursor]
+	if !row.container {
+		return
+	}
+	d.collapsed[row.id] = collapsed
+	m.buildDetailRows()
+}
+
+// filterOnField adds field:=value, or NOT field:=value, for the row under
+// the cursor to the current filter
+func (m *Model) filterOnField(exclude bool) tea.Cmd {
+	row := m.detail.rows[m.detail.cursor]
+	if row.container {
+		m.setStatusMessage("Select a value to filter on")
+		return nil
+	}
+	if row.path == "" || row.value == nil {
+		m.setStatusMessage(fmt.Sprintf("Cannot filter on %s", row.label))
+		return nil
+	}
+
+	term := filter.FieldTerm(row.path, parser.FormatValue(row.value))
+	if exclude {
+		term = "NOT " + term
+	}
+	return m.applyFilterTerm(term)
+}
+
+// showSameValue shows every line sharing the value of the row under the
+// cursor, in time order
+func (m *Model) showSameValue() tea.Cmd {
+	row := m.detail.rows[m.detail.cursor]
+	if row.container || row.path == "" || row.value == nil {
+		m.setStatusMessage(fmt.Sprintf("Cannot filter on %s", row.label))
+		return nil
+	}
+	return m.showLinesWith(row.path, parser.FormatValue(row.value))
+}
+
+// applyFilterTerm ANDs a term to the current filter and applies it
+func (m *Model) applyFilterTerm(term string) tea.Cmd {
+	query, err := m.addFilterTerm(term)
+	if err != nil {
+		m.setStatusMessage(fmt.Sprintf("Filter error: %s", err.Error()))
+		return nil
+	}
+
+	m.searchInput = query
+	m.searchCursor = len(query)
+	cmd, err := m.applySearch()
+	if err != nil {
+		m.setStatusMessage(fmt.Sprintf("Filter error: %s", err.Error()))
+		return nil
+	}
+	m.setStatusMessage("Filter: " + query)
+	return cmd
+}
+
+// addFilterTerm returns the current query with a term ANDed to its filter
+// expression, keeping any pipeline stages
+func (m *Model) addFilterTerm(term string) (string, error) {
+	query := m.filter.GetLastOptions().Query
+	if m.pipeline != nil {
+		query = m.pipeline.Query
+	}
+
+	pl, err := pipeline.Parse(query)
+	if err != nil {
+		return "", err
+	}
+
+	combined := term
+	if current := strings.TrimSpace(pl.Filter); current != "" && current != "*" {
+		expr, err := filter.ParseQuery(current)
+		if err != nil {
+			return "", fmt.Errorf("cannot add a field to %q", current)
+		}
+		if _, ok := expr.(*filter.OrExpression); ok {
+			current = "(" + current + ")"
+		}
+		combined = current + " " + term
+	}
+
+	if pl.HasStages() {
+		combined += " | " + pl.String()
+	}
+	return combined, nil
+}
+
+// renderDetail renders the detail pane in place of the filtered pane
+func (m *Model) renderDetail(width, height int) string {
+	d := m.detail
+	style := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#00ff00"))
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#ffffff")).
+		Background(lipgloss.Color("#333333")).
+		Padding(0, 1)
+
+	header := headerStyle.Render(fmt.Sprintf("Detail: %s:%d  (Enter fold, + filter, - exclude, c column, Esc close)", d.line.Source, d.line.LineNum))
+
+	// Keep the cursor in view
+	d.height = max(1, height-3)
+	if d.cursor < d.scroll {
+		d.scroll = d.cursor
+	}
+	if d.cursor >= d.scroll+d.height {
+		d.scroll = d.cursor - d.height + 1
+	}
+
+	rowStyle := lipgloss.NewStyle().MaxWidth(width - 4)
+	content := make([]string, 0, d.height)
+	for i := d.scroll; i < len(d.rows) && len(content) < d.height; i++ {
+		content = append(content, rowStyle.Render(m.renderDetailRow(d.rows[i], i == d.cursor)))
+	}
+	for len(content) < d.height {
+		content = append(content, "")
+	}
+
+	return style.Width(width - 2).Height(height).Render(header + "\n" + strings.Join(content, "\n"))
+}
+
+// renderDetailRow renders one row with its fold marker and value
+func (m *Model) renderDetailRow(row detailRow, selected bool) string {
+	var b strings.Builder
+	if selected {
+		b.WriteString("> ")
+	} else {
+		b.WriteString("  ")
+	}
+	b.WriteString(strings.Repeat("  ", row.depth))
+
+	switch {
+	case row.container:
+		marker := "▾ "
+		if m.detail.collapsed[row.id] {
+			marker = "▸ "
+		}
+		brackets := "{%d}"
+		if _, ok := row.value.([]interface{}); ok {
+			brackets = "[%d]"
+		}
+		b.WriteString(marker + m.highlighter.RenderKey(row.label) + " " + fmt.Sprintf(brackets, row.count))
+	case row.meta:
+		b.WriteString("  " + m.highlighter.RenderKey(row.label) + ": " + fmt.Sprint(row.value))
+	default:
+		b.WriteString("  " + m.highlighter.RenderKey(row.label) + ": " + m.highlighter.RenderValue(row.value))
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
+)
+
+// explainCommand is typed in the search bar to explain a query instead of
+// applying it
+const explainCommand = ":explain"
+
+// isExplain reports whether search input is an explain command
+func isExplain(input string) bool {
+	input = strings.TrimSpace(input)
+	return input == explainCommand || strings.HasPrefix(input, explainCommand+" ")
+}
+
+// explainQuery explains the query after :explain, or the current one when
+// none is given, against every buffered line
+func (m *Model) explainQuery(input string) error {
+	query := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), explainCommand))
+	if query == "" {
+		query = m.filter.GetLastOptions().Query
+		if m.pipeline != nil {
+			query = m.pipeline.Query
+		}
+	} else {
+		query = m.expandShortcuts(query)
+	}
+
+	pl, err := pipeline.Parse(query)
+	if err != nil {
+		return err
+	}
+
+	var planner filter.Planner
+	if m.searchIndex.Enabled() {
+		planner = m.searchIndex
+	}
+	lines := m.allLinesBuffer.GetRange(0, m.allLinesBuffer.Size())
+	ex, err := m.filter.Explain(models.FilterOptions{Query: pl.Filter}, lines, planner)
+	if err != nil {
+		return err
+	}
+	if planner == nil {
+		ex.Warnings = append(ex.Warnings, "the search index is disabled or exceeded max_index_size")
+	}
+
+	report := ex.String()
+	if pl.HasStages() {
+		report += fmt.Sprintf("\nPipeline: | %s\n", pl.String())
+	}
+	m.explanation = report
+	return nil
+}
+
+// renderExplanation renders the last explain report
+func (m *Model) renderExplanation() string {
+	content := "Query Explain\n\n" + m.explanation + "\nPress Esc to close.\n"
+
+	style := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#00ffff")).
+		Padding(1, 2)
+
+	return style.Width(m.width - 4).Height(m.height - 4).Render(content)
+}
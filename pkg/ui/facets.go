@@ -0,0 +1,231 @@
+package ui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/facets"
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+const (
+	// maxFacetValues caps the distinct values counted per field
+	maxFacetValues = 1000
+
+	// facetTopValues is how many values are listed under a field
+	facetTopValues = 10
+
+	// facetsWidth is the width of the sidebar
+	facetsWidth = 36
+)
+
+// facetRow is a field of the sidebar, or one of the top values of the
+// expanded field
+type facetRow struct {
+	field string
+	value string
+	isVal bool
+	text  string
+}
+
+// facetsView is the sidebar listing the fields of the lines in view
+type facetsView struct {
+	counter  *facets.Counter
+	buffer   *CircularBuffer // Buffer whose lines are counted
+	expanded string          // Field whose top values are shown
+	field    string          // Selected row
+	value    string
+	isVal    bool
+	scroll   int
+}
+
+// toggleFacets opens the sidebar, counting the lines in view once, or
+// closes it
+func (m *Model) toggleFacets() {
+	if m.facets != nil {
+		m.facets = nil
+		return
+	}
+	m.facets = &facetsView{counter: facets.New(maxFacetValues)}
+	m.resetFacets()
+}
+
+// resetFacets recounts the sidebar over the filtered lines, or every line
+// when no filter is set; after that lines are counted as they are added
+func (m *Model) resetFacets() {
+	if m.facets == nil {
+		return
+	}
+	m.facets.buffer = m.allLinesBuffer
+	if m.filter.HasFilter() {
+		m.facets.buffer = m.filteredBuffer
+	}
+	m.facets.counter.Reset()
+	m.facets.buffer.ForEach(func(line *models.LogLine) bool {
+		m.facets.counter.Add(line)
+		return true
+	})
+}
+
+// trackFacets counts a line about to be added to a buffer when the sidebar
+// counts that buffer, uncounting the line it will evict
+func (m *Model) trackFacets(buffer *CircularBuffer, line *models.LogLine) {
+	if m.facets == nil || m.facets.buffer != buffer {
+		return
+	}
+	if buffer.Size() == buffer.capacity {
+		m.facets.counter.Remove(buffer.Get(0))
+	}
+	m.facets.counter.Add(line)
+}
+
+// addFiltered adds a match to the filtered pane
+func (m *Model) addFiltered(line *models.LogLine) {
+	m.trackFacets(m.filteredBuffer, line)
+	m.trackHistogram(m.filteredBuffer, line)
+	m.filteredBuffer.Add(line)
+	m.addContextMatch(line)
+}
+
+// facetRows lists the fields, with the top values of the expanded one
+func (m *Model) facetRows() []facetRow {
+	fv := m.facets
+	total := max(1, fv.counter.Lines())
+
+	var rows []facetRow
+	for _, stat := range fv.counter.Fields() {
+		cardinality := strconv.Itoa(stat.Cardinality)
+		if stat.Overflow {
+			cardinality += "+"
+		}
+		marker := "▸ "
+		if stat.Name == fv.expanded {
+			marker = "▾ "
+		}
+		rows = append(rows, facetRow{field: stat.Name, text: fmt.Sprintf("%s%s (%s)", marker, stat.Name, cardinality)})
+		if stat.Name != fv.expanded {
+			continue
+		}
+		for _, top := range fv.counter.Top(stat.Name, facetTopValues) {
+			text := fmt.Sprintf("    %d %2d%% %s", top.Count, top.Count*100/total, top.Value)
+			rows = append(rows, facetRow{field: stat.Name, value: top.Value, isVal: true, text: text})
+		}
+	}
+	return rows
+}
+
+// facetCursor returns the index of the selected row
+func (m *Model) facetCursor(rows []facetRow) int {
+	fv := m.facets
+	for i, row := range rows {
+		if row.field == fv.field && row.isVal == fv.isVal && row.value == fv.value {
+			return i
+		}
+	}
+	return 0
+}
+
+// updateFacets handles keys while the sidebar is open
+func (m *Model) updateFacets(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	fv := m.facets
+	rows := m.facetRows()
+	action, count := m.pressKey(modeFacets, msg)
+	if action == "facets.close" {
+		m.facets = nil
+		return m, nil
+	}
+	if len(rows) == 0 {
+		return m, nil
+	}
+
+	cursor := m.facetCursor(rows)
+	switch action {
+	case "facets.down":
+		cursor = min(cursor+max(1, count), len(rows)-1)
+	case "facets.up":
+		cursor = max(cursor-max(1, count), 0)
+	case "facets.select":
+		row := rows[cursor]
+		if row.isVal {
+			return m, m.filterOnFacet(row, false)
+		}
+		if fv.expanded == row.field {
+			fv.expanded = ""
+		} else {
+			fv.expanded = row.field
+		}
+	case "facets.include", "facets.exclude":
+		if row := rows[cursor]; row.isVal {
+			return m, m.filterOnFacet(row, action == "facets.exclude")
+		}
+		m.setStatusMessage("Expand a field with Enter and select one of its values")
+	}
+
+	row := rows[cursor]
+	fv.field, fv.value, fv.isVal = row.field, row.value, row.isVal
+	return m, nil
+}
+
+// filterOnFacet adds field:=value, or NOT field:=value, to the current
+// filter
+func (m *Model) filterOnFacet(row facetRow, exclude bool) tea.Cmd {
+	term := filter.FieldTerm(parsedFieldPath(row.field), row.value)
+	if exclude {
+		term = "NOT " + term
+	}
+	return m.applyFilterTerm(term)
+}
+
+// renderFacets renders the sidebar at the given height
+func (m *Model) renderFacets(height int) string {
+	fv := m.facets
+	style := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#00ff00"))
+	headerStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#ffffff")).
+		Background(lipgloss.Color("#333333")).
+		Padding(0, 1)
+
+	scope := "all lines"
+	if fv.buffer == m.filteredBuffer {
+		scope = "filtered"
+	}
+	header := headerStyle.Render(fmt.Sprintf("Fields: %d %s", fv.counter.Lines(), scope))
+
+	rows := m.facetRows()
+	visible := max(1, height-3)
+	cursor := m.facetCursor(rows)
+	if cursor < fv.scroll {
+		fv.scroll = cursor
+	}
+	if cursor >= fv.scroll+visible {
+		fv.scroll = cursor - visible + 1
+	}
+
+	width := facetsWidth - 4
+	content := make([]string, 0, visible)
+	for i := fv.scroll; i < len(rows) && len(content) < visible; i++ {
+		prefix := "  "
+		if i == cursor {
+			prefix = "> "
+		}
+		text := prefix + rows[i].text
+		if runes := []rune(text); len(runes) > width {
+			text = string(runes[:width-3]) + "..."
+		}
+		content = append(content, text)
+	}
+	if len(rows) == 0 {
+		content = append(content, "No parsed fields")
+	}
+	for len(content) < visible {
+		content = append(content, "")
+	}
+
+	return style.Width(facetsWidth - 2).Height(height).Render(header + "\n" + strings.Join(content, "\n"))
+}
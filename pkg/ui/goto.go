@@ -0,0 +1,197 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// gotoScanWindow is how many lines on each side of the binary search result
+// are compared for the timestamp nearest to a goto time
+const gotoScanWindow = 2000
+
+// gotoUsage lists the targets of goto
+const gotoUsage = "goto <time|file:line|file@offset|@offset|line|bookmark>"
+
+// openGoto opens the palette on the goto command
+func (m *Model) openGoto() {
+	m.openPalette()
+	m.palette.input = "goto "
+}
+
+// gotoTarget moves the cursor of the active pane to the line nearest to a
+// bookmark name, a byte offset, a file line, a line of the pane or a time
+func (m *Model) gotoTarget(target string) error {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return fmt.Errorf("nothing to go to")
+	}
+	buffer := m.getActiveBuffer()
+	if buffer == nil || buffer.Size() == 0 {
+		return fmt.Errorf("no lines in this pane")
+	}
+
+	for _, bookmark := range m.bookmarks {
+		if strings.EqualFold(bookmark.Name, target) {
+			return m.gotoBookmark(buffer, bookmark)
+		}
+	}
+
+	if i := strings.LastIndexByte(target, '@'); i >= 0 {
+		if offset, err := strconv.ParseInt(target[i+1:], 10, 64); err == nil {
+			source, err := m.gotoSource(target[:i])
+			if err != nil {
+				return err
+			}
+			return m.gotoNearest(buffer, source, offset, func(line *models.LogLine) int64 { return line.Offset },
+				fmt.Sprintf("byte %d", offset))
+		}
+	}
+
+	if i := strings.LastIndexByte(target, ':'); i > 0 {
+		if n, err := strconv.Atoi(target[i+1:]); err == nil {
+			if source, ok := m.knownSource(target[:i]); ok {
+				return m.gotoNearest(buffer, source, int64(n), func(line *models.LogLine) int64 { return int64(line.LineNum) },
+					fmt.Sprintf("line %d", n))
+			}
+		}
+	}
+
+	if n, err := strconv.Atoi(target); err == nil && n > 0 {
+		m.goToLine(n)
+		m.setStatusMessage(fmt.Sprintf("Line %d of %d", min(n, buffer.Size()), buffer.Size()))
+		return nil
+	}
+
+	bound, err := filter.ParseTimeBound(target)
+	if err != nil {
+		return fmt.Errorf("not a time, file:line, offset or bookmark: %s", target)
+	}
+	at := bound.Resolve(time.Now())
+	if bound.Anchor == filter.AnchorToday {
+		// A time of day is taken on the last day of the lines rather than
+		// today, so times from an incident report work on older files
+		newest := buffer.Get(buffer.Size() - 1).Timestamp
+		if !newest.IsZero() {
+			at = bound.Resolve(newest)
+			if at.After(newest) {
+				at = at.AddDate(0, 0, -1)
+			}
+		}
+	}
+	return m.gotoTime(buffer, at)
+}
+
+// gotoTime moves to the line whose timestamp is nearest to t
+func (m *Model) gotoTime(buffer *CircularBuffer, t time.Time) error {
+	i := buffer.SearchTime(t, gotoScanWindow)
+	if i < 0 {
+		return fmt.Errorf("no line has a timestamp")
+	}
+	m.scrollToLine(i)
+
+	line := buffer.Get(i)
+	status := fmt.Sprintf("Line at %s", line.Timestamp.Local().Format("2006-01-02 15:04:05.000"))
+	if diff := line.Timestamp.Sub(t); diff >= time.Second || diff <= -time.Second {
+		status += fmt.Sprintf(", %s from %s", diff.Round(time.Second), t.Format("15:04:05"))
+	}
+	m.setStatusMessage(status)
+	return nil
+}
+
+// gotoBookmark moves to the line of a bookmark
+func (m *Model) gotoBookmark(buffer *CircularBuffer, bookmark models.Bookmark) error {
+	target, i := -1, 0
+	buffer.ForEach(func(line *models.LogLine) bool {
+		if line.ID == bookmark.LineID && line.Source == bookmark.Source {
+			target = i
+			return false
+		}
+		i++
+		return true
+	})
+	if target < 0 {
+		return fmt.Errorf("the line of %s is not in this pane", bookmark.Name)
+	}
+	m.scrollToLine(target)
+	m.setStatusMessage(fmt.Sprintf("Bookmark %s", bookmark.Name))
+	return nil
+}
+
+// gotoNearest moves to the line of a source whose position, a line number
+// or byte offset, is nearest to want without passing it; lines evicted or
+// filtered out land on the one before
+func (m *Model) gotoNearest(buffer *CircularBuffer, source string, want int64, position func(*models.LogLine) int64, what string) error {
+	target, after, i := -1, -1, 0
+	var best int64
+	buffer.ForEach(func(line *models.LogLine) bool {
+		if line.Source == source {
+			pos := position(line)
+			if pos <= want && (target < 0 || pos >= best) {
+				target, best = i, pos
+			}
+			if pos > want && after < 0 {
+				after = i
+			}
+		}
+		i++
+		return true
+	})
+	if target < 0 {
+		target = after // Earlier lines are gone; the first kept is nearest
+		best = -1
+	}
+	if target < 0 {
+		return fmt.Errorf("no line of %s in this pane", filepath.Base(source))
+	}
+
+	m.scrollToLine(target)
+	line := buffer.Get(target)
+	status := fmt.Sprintf("%s:%d at byte %d", filepath.Base(source), line.LineNum, line.Offset)
+	if best != want {
+		status += " (nearest to " + what + ")"
+	}
+	m.setStatusMessage(status)
+	return nil
+}
+
+// gotoSource returns the source an offset is in: the named one, or that of
+// the line under the cursor
+func (m *Model) gotoSource(name string) (string, error) {
+	if name != "" {
+		if source, ok := m.knownSource(name); ok {
+			return source, nil
+		}
+		return "", fmt.Errorf("no source named %s", name)
+	}
+
+	pane, buffer := m.getActivePane(), m.getActiveBuffer()
+	if line := buffer.Get(pane.scrollY + pane.cursorY); line != nil && line.Source != "" {
+		return line.Source, nil
+	}
+	return "", fmt.Errorf("no line selected to take the file from")
+}
+
+// knownSource resolves a file path or name to a source lines came from
+func (m *Model) knownSource(name string) (string, bool) {
+	sources := m.tailer.GetWatchedFiles()
+	for source := range m.sourceStats {
+		sources = append(sources, source)
+	}
+	for _, source := range sources {
+		if source == name {
+			return source, true
+		}
+	}
+	for _, source := range sources {
+		if filepath.Base(source) == name {
+			return source, true
+		}
+	}
+	return "", false
+}
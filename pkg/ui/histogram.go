@@ -0,0 +1,324 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/histogram"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// histogramBarRows is the height of the stacked bars
+const histogramBarRows = 2
+
+// barBlocks are the partial blocks of a bar, in eighths
+var barBlocks = []rune(" ▁▂▃▄▅▆▇█")
+
+// levelColors color the bar segments, indexed by histogram.Level
+var levelColors = []lipgloss.Color{"#f44747", "#dcdcaa", "#4ec9b0", "#808080"}
+
+// histogramView is the histogram of lines per time bucket drawn in the
+// persistent header: stacked bars of every line, a sparkline of the
+// filtered ones
+type histogramView struct {
+	visible  bool
+	all      *histogram.Histogram // nil until drawn at a width
+	filtered *histogram.Histogram
+	width    int
+	bars     []histogram.Bucket // Buckets of the last render
+	size     time.Duration
+	selected int // Index into bars, -1 for none
+	focused  bool
+	top      int // Screen row of the first bar row, for mouse clicks
+	left     int // Screen column of the first bar
+}
+
+// toggleHistogram shows or hides the histogram
+func (m *Model) toggleHistogram() {
+	hv := m.histogram
+	hv.visible = !hv.visible
+	hv.focused = false
+	if !hv.visible {
+		// Stop counting while hidden; lines are recounted when shown again
+		hv.all, hv.filtered, hv.width = nil, nil, 0
+	}
+}
+
+// focusHistogram lets the keys select a bar, starting at the latest one
+func (m *Model) focusHistogram() {
+	hv := m.histogram
+	if !hv.visible {
+		hv.visible = true
+	}
+	hv.focused = true
+	hv.selected = -1
+	m.setStatusMessage("Select a bar with h/l; Enter jumps to it, t filters on it, Esc returns")
+}
+
+// countHistogram counts the lines of a buffer at the histogram's width
+func (m *Model) countHistogram(buffer *CircularBuffer) *histogram.Histogram {
+	h := histogram.New(m.histogram.width)
+	buffer.ForEach(func(line *models.LogLine) bool {
+		h.Add(line)
+		return true
+	})
+	return h
+}
+
+// resetHistogram recounts the filtered lines after the filter changed
+func (m *Model) resetHistogram() {
+	if m.histogram.filtered != nil {
+		m.histogram.filtered = m.countHistogram(m.filteredBuffer)
+	}
+}
+
+// trackHistogram counts a line about to be added to the buffer of every
+// line or of the filtered lines, uncounting the line it will evict
+func (m *Model) trackHistogram(buffer *CircularBuffer, line *models.LogLine) {
+	var h *histogram.Histogram
+	switch buffer {
+	case m.allLinesBuffer:
+		h = m.histogram.all
+	case m.filteredBuffer:
+		h = m.histogram.filtered
+	}
+	if h == nil {
+		return
+	}
+	if buffer.Size() == buffer.capacity {
+		h.Remove(buffer.Get(0))
+	}
+	h.Add(line)
+}
+
+// histogramHeight returns the rows the histogram takes in the header
+func (m *Model) histogramHeight() int {
+	hv := m.histogram
+	if !hv.visible || hv.all == nil || hv.all.Len() == 0 {
+		return 0
+	}
+	height := histogramBarRows + 1 // Bars and the label row
+	if m.filter.HasFilter() {
+		height++ // Sparkline of the filtered lines
+	}
+	return height
+}
+
+// updateHistogram handles keys while a bar is being selected
+func (m *Model) updateHistogram(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	hv := m.histogram
+	if len(hv.bars) == 0 {
+		hv.focused = false
+		return m, nil
+	}
+	if hv.selected < 0 || hv.selected >= len(hv.bars) {
+		hv.selected = len(hv.bars) - 1
+	}
+
+	action, count := m.pressKey(modeHistogram, msg)
+	switch action {
+	case "histogram.close":
+		hv.focused = false
+	case "histogram.left":
+		hv.selected = max(0, hv.selected-max(1, count))
+	case "histogram.right":
+		hv.selected = min(len(hv.bars)-1, hv.selected+max(1, count))
+	case "histogram.first":
+		hv.selected = 0
+	case "histogram.last":
+		hv.selected = len(hv.bars) - 1
+	case "histogram.jump":
+		m.jumpToBar()
+	case "histogram.filter":
+		return m, m.filterOnBar()
+	}
+	return m, nil
+}
+
+// clickHistogram selects the bar under a left click and jumps to it
+func (m *Model) clickHistogram(msg tea.MouseMsg) {
+	hv := m.histogram
+	if msg.Action != tea.MouseActionPress || msg.Button != tea.MouseButtonLeft {
+		return
+	}
+	if m.histogramHeight() == 0 || msg.Y < hv.top || msg.Y >= hv.top+histogramBarRows {
+		return
+	}
+	if i := msg.X - hv.left; i >= 0 && i < len(hv.bars) {
+		hv.focused = true
+		hv.selected = i
+		m.jumpToBar()
+	}
+}
+
+// barRange returns the first and last instant of the selected bar
+func (m *Model) barRange() (time.Time, time.Time) {
+	start := m.histogram.bars[m.histogram.selected].Start.Local()
+	return start, start.Add(m.histogram.size - time.Nanosecond)
+}
+
+// jumpToBar moves the active pane to the first line at or after the start
+// of the selected bar
+func (m *Model) jumpToBar() {
+	start, _ := m.barRange()
+	buffer := m.getActiveBuffer()
+	target, i := -1, 0
+	buffer.ForEach(func(line *models.LogLine) bool {
+		if !line.Timestamp.IsZero() && !line.Timestamp.Before(start) {
+			target = i
+			return false
+		}
+		i++
+		return true
+	})
+	if target < 0 {
+		m.setStatusMessage(fmt.Sprintf("No lines at or after %s in this pane", start.Format("2006-01-02 15:04:05")))
+		return
+	}
+	m.scrollToLine(target)
+	m.setStatusMessage(fmt.Sprintf("Jumped to %s", start.Format("2006-01-02 15:04:05")))
+}
+
+// filterOnBar adds the time range of the selected bar to the filter
+func (m *Model) filterOnBar() tea.Cmd {
+	start, end := m.barRange()
+	m.histogram.focused = false
+	term := fmt.Sprintf("time:[%s TO %s]", start.Format("2006-01-02 15:04:05"), end.Format("2006-01-02 15:04:05.999999999"))
+	return m.applyFilterTerm(term)
+}
+
+// renderHistogram renders the bars of every line stacked by level, the
+// sparkline of the filtered lines and a label row, or "" when hidden
+func (m *Model) renderHistogram(width int) string {
+	hv := m.histogram
+	if !hv.visible || width < 10 {
+		return ""
+	}
+	if hv.width != width || hv.all == nil {
+		hv.width = width
+		hv.all = m.countHistogram(m.allLinesBuffer)
+		hv.filtered = m.countHistogram(m.filteredBuffer)
+	}
+	hv.bars = hv.all.Buckets()
+	hv.size = hv.all.BucketSize()
+	if len(hv.bars) == 0 {
+		return ""
+	}
+	if hv.selected >= len(hv.bars) {
+		hv.selected = len(hv.bars) - 1
+	}
+	if hv.focused && hv.selected < 0 {
+		hv.selected = len(hv.bars) - 1
+	}
+
+	rows := make([]string, 0, histogramBarRows+2)
+	for row := histogramBarRows - 1; row >= 0; row-- {
+		rows = append(rows, m.renderBarRow(row))
+	}
+	if m.filter.HasFilter() {
+		rows = append(rows, m.renderSparkline())
+	}
+	rows = append(rows, m.renderHistogramLabel(width))
+	return strings.Join(rows, "\n")
+}
+
+// renderBarRow renders one row of the stacked bars, 0 being the bottom;
+// each cell takes the color of the level filling most of it
+func (m *Model) renderBarRow(row int) string {
+	hv := m.histogram
+	peak := 1
+	for _, bar := range hv.bars {
+		peak = max(peak, bar.Total())
+	}
+
+	var b strings.Builder
+	eighths := histogramBarRows * 8
+	for i, bar := range hv.bars {
+		total := bar.Total()
+		height := 0
+		if total > 0 {
+			height = max(1, (total*eighths+peak-1)/peak)
+		}
+		fill := min(8, max(0, height-row*8))
+
+		// Find the level whose segment covers the middle of the cell
+		level := 0
+		if fill > 0 {
+			middle := float64(row*8) + float64(fill)/2
+			covered := 0
+			for l, count := range bar.Counts {
+				covered += count
+				if float64(covered*height)/float64(total) >= middle {
+					level = l
+					break
+				}
+			}
+		}
+
+		style := lipgloss.NewStyle().Foreground(levelColors[level])
+		if hv.focused && i == hv.selected {
+			style = style.Background(lipgloss.Color("#444444"))
+		}
+		b.WriteString(style.Render(string(barBlocks[fill])))
+	}
+	return b.String()
+}
+
+// renderSparkline renders the filtered lines per bar of the histogram
+func (m *Model) renderSparkline() string {
+	hv := m.histogram
+	counts := make([]int, len(hv.bars))
+	if hv.filtered != nil && len(hv.bars) > 0 {
+		first := hv.bars[0].Start
+		for _, bucket := range hv.filtered.Buckets() {
+			i := int(bucket.Start.Sub(first) / hv.size)
+			if i >= 0 && i < len(counts) {
+				counts[i] += bucket.Total()
+			}
+		}
+	}
+
+	peak := 1
+	for _, count := range counts {
+		peak = max(peak, count)
+	}
+	var b strings.Builder
+	for _, count := range counts {
+		fill := 0
+		if count > 0 {
+			fill = max(1, count*8/peak)
+		}
+		b.WriteRune(barBlocks[fill])
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("#00ffff")).Render(b.String())
+}
+
+// renderHistogramLabel describes the selected bar, or the span and bucket
+// size of the histogram
+func (m *Model) renderHistogramLabel(width int) string {
+	hv := m.histogram
+	first := hv.bars[0].Start.Local()
+	last := hv.bars[len(hv.bars)-1].Start.Local().Add(hv.size)
+	layout := "15:04:05"
+	if first.Year() != last.Year() {
+		layout = "2006-01-02"
+	} else if first.YearDay() != last.YearDay() {
+		layout = "01-02 15:04"
+	}
+
+	label := fmt.Sprintf("%s → %s  %s per bar  (h select)", first.Format(layout), last.Format(layout), hv.size)
+	if hv.focused && hv.selected >= 0 {
+		bar := hv.bars[hv.selected]
+		start, _ := m.barRange()
+		label = fmt.Sprintf("%s +%s: %d lines (%d error, %d warn, %d info)  Enter jump, t filter, Esc",
+			start.Format(layout), hv.size, bar.Total(),
+			bar.Counts[histogram.LevelError], bar.Counts[histogram.LevelWarn], bar.Counts[histogram.LevelInfo])
+	}
+	if runes := []rune(label); len(runes) > width {
+		label = string(runes[:width-3]) + "..."
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("#808080")).Render(label)
+}
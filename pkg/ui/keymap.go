@@ -0,0 +1,318 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/loganalyzer/traceace/pkg/keymap"
+)
+
+// Key binding groups, in the order of the help screen
+const (
+	groupNavigation = "NAVIGATION"
+	groupSearch     = "SEARCH & FILTER"
+	groupControls   = "CONTROLS"
+	groupDetail     = "DETAIL PANE (Enter)"
+	groupFacets     = "FIELDS SIDEBAR (f)"
+	groupHistogram  = "HISTOGRAM (h)"
+	groupSources    = "SOURCE MANAGER (s)"
+)
+
+// defaultBindings are the actions of the UI with their default keys; the
+// keybindings section of the config overrides them by action name
+var defaultBindings = []keymap.Binding{
+	{Action: "scroll_down", Keys: []string{"j", "down"}, Help: "Scroll down (10j: ten lines)", Group: groupNavigation},
+	{Action: "scroll_up", Keys: []string{"k", "up"}, Help: "Scroll up", Group: groupNavigation},
+	{Action: "page_down", Keys: []string{"ctrl+d"}, Help: "Page down", Group: groupNavigation},
+	{Action: "page_up", Keys: []string{"ctrl+u"}, Help: "Page up", Group: groupNavigation},
+	{Action: "goto_top", Keys: []string{"g"}, Help: "Go to top (10g: line 10)", Group: groupNavigation},
+	{Action: "goto_bottom", Keys: []string{"G"}, Help: "Go to bottom", Group: groupNavigation},
+	{Action: "goto", Keys: []string{"ctrl+g"}, Help: "Go to a time, file:line, byte offset or bookmark", Group: groupNavigation},
+
+	{Action: "search", Keys: []string{"/"}, Help: "Open search", Group: groupSearch},
+	{Action: "command_palette", Keys: []string{":"}, Help: "Command palette: actions and commands below", Group: groupSearch},
+	{Action: "next_match", Keys: []string{"n"}, Help: "Next match (each highlighted occurrence in turn)", Group: groupSearch},
+	{Action: "prev_match", Keys: []string{"N"}, Help: "Previous match", Group: groupSearch},
+	{Action: "clear_filter", Keys: []string{"c"}, Help: "Clear filter", Group: groupSearch},
+	{Action: "export", Keys: []string{"e"}, Help: "Export filtered lines or results to CSV", Group: groupSearch},
+	{Action: "same_field", Keys: []string{"="}, Help: "Show all lines with the same trace/request ID", Group: groupSearch},
+	{Action: "inspect", Keys: []string{"enter"}, Help: "Inspect the selected line: metadata and parsed fields", Group: groupSearch},
+	{Action: "merge_view", Keys: []string{"m"}, Help: "Merge all sources into one timeline by timestamp", Group: groupSearch},
+	{Action: "facets", Keys: []string{"f"}, Help: "Fields sidebar: values and counts of the lines in view", Group: groupSearch},
+	{Action: "more_context", Keys: []string{"+"}, Help: "More context lines around the selected match", Group: groupSearch},
+	{Action: "less_context", Keys: []string{"-"}, Help: "Less context lines around the selected match", Group: groupSearch},
+	{Action: "context", Keys: []string{"C"}, Help: "Turn context lines off and on (ui.context_lines, -C)", Group: groupSearch},
+	{Action: "histogram", Keys: []string{"h"}, Help: "Select a histogram bar; a click also jumps to its time", Group: groupSearch},
+	{Action: "toggle_histogram", Keys: []string{"H"}, Help: "Show/hide the histogram of lines per time bucket", Group: groupSearch},
+	{Action: "column_view", Keys: []string{"v"}, Help: "Column view of parsed fields", Group: groupSearch},
+	{Action: "column_prev", Keys: []string{"["}, Help: "Select the previous column", Group: groupSearch},
+	{Action: "column_next", Keys: []string{"]"}, Help: "Select the next column", Group: groupSearch},
+	{Action: "column_narrow", Keys: []string{"<"}, Help: "Narrow the selected column", Group: groupSearch},
+	{Action: "column_widen", Keys: []string{">"}, Help: "Widen the selected column", Group: groupSearch},
+	{Action: "column_sort", Keys: []string{"o"}, Help: "Sort the filtered lines by the selected column", Group: groupSearch},
+	{Action: "column_remove", Keys: []string{"D"}, Help: "Remove the selected column", Group: groupSearch},
+	{Action: "escape", Keys: []string{"esc"}, Help: "Close search/help, cancel filtering", Group: groupSearch},
+
+	{Action: "pause_resume", Keys: []string{"space"}, Help: "Pause/resume stream", Group: groupControls},
+	{Action: "toggle_view", Keys: []string{"t"}, Help: "Toggle active pane", Group: groupControls},
+	{Action: "next_tab", Keys: []string{"tab"}, Help: "Next tab: All, each file, query tabs", Group: groupControls},
+	{Action: "prev_tab", Keys: []string{"shift+tab"}, Help: "Previous tab", Group: groupControls},
+	{Action: "close_tab", Keys: []string{"x"}, Help: "Close the active query tab", Group: groupControls},
+	{Action: "sources", Keys: []string{"s"}, Help: "Source manager: add/remove files, pause a file, line rates", Group: groupControls},
+	{Action: "bookmark", Keys: []string{"b"}, Help: "Add bookmark", Group: groupControls},
+	{Action: "quit", Keys: []string{"q"}, Help: "Quit", Group: groupControls},
+	{Action: "help", Keys: []string{"?"}, Help: "Toggle help", Group: groupControls},
+
+	{Action: "detail.close", Keys: []string{"esc"}, Help: "Close", Group: groupDetail},
+	{Action: "detail.down", Keys: []string{"j", "down"}, Help: "Next row", Group: groupDetail},
+	{Action: "detail.up", Keys: []string{"k", "up"}, Help: "Previous row", Group: groupDetail},
+	{Action: "detail.top", Keys: []string{"g"}, Help: "First row", Group: groupDetail},
+	{Action: "detail.bottom", Keys: []string{"G"}, Help: "Last row", Group: groupDetail},
+	{Action: "detail.fold", Keys: []string{"enter", "space"}, Help: "Fold/unfold an object or array", Group: groupDetail},
+	{Action: "detail.expand", Keys: []string{"right", "l"}, Help: "Unfold", Group: groupDetail},
+	{Action: "detail.collapse", Keys: []string{"left", "h"}, Help: "Fold", Group: groupDetail},
+	{Action: "detail.include", Keys: []string{"+"}, Help: "Add field:=value to the filter", Group: groupDetail},
+	{Action: "detail.exclude", Keys: []string{"-"}, Help: "Add NOT field:=value to the filter", Group: groupDetail},
+	{Action: "detail.same", Keys: []string{"="}, Help: "Show all lines with the same value", Group: groupDetail},
+	{Action: "detail.add_column", Keys: []string{"c"}, Help: "Add the field as a column", Group: groupDetail},
+
+	{Action: "facets.close", Keys: []string{"esc", "f"}, Help: "Close", Group: groupFacets},
+	{Action: "facets.down", Keys: []string{"j", "down"}, Help: "Next row", Group: groupFacets},
+	{Action: "facets.up", Keys: []string{"k", "up"}, Help: "Previous row", Group: groupFacets},
+	{Action: "facets.select", Keys: []string{"enter", "space"}, Help: "Expand a field, include a value", Group: groupFacets},
+	{Action: "facets.include", Keys: []string{"+"}, Help: "Include the value", Group: groupFacets},
+	{Action: "facets.exclude", Keys: []string{"-"}, Help: "Exclude the value", Group: groupFacets},
+
+	{Action: "histogram.close", Keys: []string{"esc", "q"}, Help: "Return to the panes", Group: groupHistogram},
+	{Action: "histogram.left", Keys: []string{"h", "left"}, Help: "Previous bar", Group: groupHistogram},
+	{Action: "histogram.right", Keys: []string{"l", "right"}, Help: "Next bar", Group: groupHistogram},
+	{Action: "histogram.first", Keys: []string{"g", "home"}, Help: "First bar", Group: groupHistogram},
+	{Action: "histogram.last", Keys: []string{"G", "end"}, Help: "Last bar", Group: groupHistogram},
+	{Action: "histogram.jump", Keys: []string{"enter", "space"}, Help: "Jump to the time of the bar", Group: groupHistogram},
+	{Action: "histogram.filter", Keys: []string{"t"}, Help: "Filter on the time range of the bar", Group: groupHistogram},
+
+	{Action: "sources.close", Keys: []string{"esc", "s"}, Help: "Close", Group: groupSources},
+	{Action: "sources.down", Keys: []string{"j", "down"}, Help: "Next file", Group: groupSources},
+	{Action: "sources.up", Keys: []string{"k", "up"}, Help: "Previous file", Group: groupSources},
+	{Action: "sources.add", Keys: []string{"a"}, Help: "Tail another file", Group: groupSources},
+	{Action: "sources.pause", Keys: []string{"p"}, Help: "Pause/resume the file", Group: groupSources},
+	{Action: "sources.remove", Keys: []string{"d"}, Help: "Stop tailing the file", Group: groupSources},
+}
+
+// Key modes, the prefix of their actions
+const (
+	modeMain      = ""
+	modeDetail    = "detail"
+	modeFacets    = "facets"
+	modeHistogram = "histogram"
+	modeSources   = "sources"
+)
+
+// newKeymap builds the keymap from the config, with one message per
+// dropped or conflicting binding
+func newKeymap(overrides map[string]string) (*keymap.Keymap, []string) {
+	keys, errs := keymap.New(defaultBindings, overrides)
+	problems := make([]string, len(errs))
+	for i, err := range errs {
+		problems[i] = err.Error()
+	}
+	return keys, problems
+}
+
+// pressKey resolves a key of a mode to its action and count
+func (m *Model) pressKey(mode string, msg tea.KeyMsg) (string, int) {
+	return m.keys.Press(mode, msg.String())
+}
+
+// runAction runs an action of the main view, repeated or given a line
+// number by its count
+func (m *Model) runAction(action string, count int) tea.Cmd {
+	times := max(1, count)
+	switch action {
+	case "quit":
+		m.quitting = true
+		m.cancel()
+		return tea.Quit
+
+	case "search":
+		m.searchActive = true
+		m.searchInput = ""
+		m.searchCursor = 0
+
+	case "command_palette":
+		m.openPalette()
+
+	case "help":
+		m.showHelp = !m.showHelp
+
+	case "escape":
+		if m.showHelp {
+			m.showHelp = false
+		} else if m.explanation != "" {
+			m.explanation = ""
+		} else if m.searchActive {
+			m.searchActive = false
+		} else if m.cancelRefilter() {
+			m.setStatusMessage(fmt.Sprintf("Filtering cancelled after %d matches", m.filteredBuffer.Size()))
+		}
+
+	case "pause_resume":
+		m.isPaused = !m.isPaused
+		status := "Resumed"
+		if m.isPaused {
+			status = "Paused"
+		}
+		m.setStatusMessage(fmt.Sprintf("Stream %s", status))
+
+	case "toggle_view":
+		if m.activePane == PaneAllLogs {
+			m.activePane = PaneFiltered
+			m.allLogsPane.showCursor = false
+			m.filteredPane.showCursor = true
+		} else {
+			m.activePane = PaneAllLogs
+			m.allLogsPane.showCursor = true
+			m.filteredPane.showCursor = false
+		}
+
+	case "scroll_down":
+		for i := 0; i < times; i++ {
+			m.scrollDown()
+		}
+
+	case "scroll_up":
+		for i := 0; i < times; i++ {
+			m.scrollUp()
+		}
+
+	case "page_down":
+		for i := 0; i < times; i++ {
+			m.pageDown()
+		}
+
+	case "page_up":
+		for i := 0; i < times; i++ {
+			m.pageUp()
+		}
+
+	case "goto_top", "goto_bottom":
+		if count > 0 {
+			m.goToLine(count)
+		} else if action == "goto_top" {
+			m.goToTop()
+		} else {
+			m.goToBottom()
+		}
+
+	case "goto":
+		m.openGoto()
+
+	case "bookmark":
+		m.addBookmark()
+
+	case "clear_filter":
+		m.clearFilter()
+
+	case "export":
+		m.exportView()
+
+	case "same_field":
+		return m.showSameField()
+
+	case "inspect":
+		m.openDetail()
+
+	case "merge_view":
+		m.toggleTimeline()
+
+	case "column_view":
+		m.toggleColumns()
+
+	case "facets":
+		m.toggleFacets()
+
+	case "histogram":
+		m.focusHistogram()
+
+	case "toggle_histogram":
+		m.toggleHistogram()
+
+	case "context":
+		m.toggleContext()
+
+	case "more_context", "less_context":
+		for i := 0; i < times; i++ {
+			m.expandContext(action == "more_context")
+		}
+
+	case "column_prev", "column_next", "column_narrow", "column_widen", "column_sort", "column_remove":
+		return m.updateColumns(action)
+
+	case "next_tab", "prev_tab":
+		step := 1
+		if action == "prev_tab" {
+			step = -1
+		}
+		var cmd tea.Cmd
+		for i := 0; i < times; i++ {
+			cmd = m.cycleTab(step)
+		}
+		return cmd
+
+	case "close_tab":
+		return m.closeActiveTab()
+
+	case "sources":
+		m.showSources = true
+
+	case "next_match":
+		for i := 0; i < times; i++ {
+			m.nextMatch()
+		}
+
+	case "prev_match":
+		for i := 0; i < times; i++ {
+			m.previousMatch()
+		}
+	}
+	return nil
+}
+
+// goToLine moves the cursor of the active pane to a line, counted from 1
+func (m *Model) goToLine(n int) {
+	buffer := m.getActiveBuffer()
+	if buffer == nil || buffer.Size() == 0 {
+		return
+	}
+	m.scrollToLine(min(n, buffer.Size()) - 1)
+}
+
+// keyHint returns the first key of an action, for the hints of overlays
+func (m *Model) keyHint(action string) string {
+	keys, _, _ := strings.Cut(m.keys.Keys(action), ", ")
+	return keys
+}
+
+// renderKeyHelp renders the key bindings of the help screen, grouped
+func (m *Model) renderKeyHelp() string {
+	var b strings.Builder
+	group := ""
+	for _, binding := range m.keys.Bindings() {
+		if binding.Group != group {
+			if group != "" {
+				b.WriteString("\n")
+			}
+			group = binding.Group
+			b.WriteString(group + ":\n")
+		}
+		fmt.Fprintf(&b, "  %-10s %s\n", m.keys.Keys(binding.Action), binding.Help)
+	}
+	if len(m.keyProblems) > 0 {
+		b.WriteString("\nKEYBINDINGS NOT APPLIED:\n")
+		for _, problem := range m.keyProblems {
+			b.WriteString("  " + problem + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,339 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/export"
+)
+
+// paletteRows is how many matching entries the palette lists
+const paletteRows = 6
+
+// paletteCommand is a palette command taking arguments
+type paletteCommand struct {
+	name  string
+	usage string
+	help  string
+	run   func(m *Model, args string) (tea.Cmd, error)
+}
+
+// paletteCommands are the commands of the palette besides the actions
+var paletteCommands = []paletteCommand{
+	{"theme", "theme <dark|light|monochrome>", "Switch the color theme", (*Model).paletteTheme},
+	{"export", "export <csv|json|text|html> [path]", "Export the filtered lines or results", (*Model).paletteExport},
+	{"goto", gotoUsage, "Jump to the nearest line (14:32:10, -15m, app.log:1200, @52311)", (*Model).paletteGoto},
+	{"mark", "mark <name>", "Bookmark the selected line under a name, for goto", (*Model).paletteMark},
+	{"source", "source add|remove <path>", "Tail another file, or stop tailing one", (*Model).paletteSource},
+	{"filter", "filter <query>", "Apply a query, as typed in the search bar", (*Model).paletteFilter},
+	{"tab", "tab <query>", "Open a tab of lines matching a query", (*Model).paletteTab},
+	{"explain", "explain [query]", "Explain a query, or the current one", (*Model).paletteExplain},
+}
+
+// paletteEntry is a command or an action listed by the palette
+type paletteEntry struct {
+	name    string
+	hint    string // Usage of a command, keys of an action
+	help    string
+	command *paletteCommand
+	score   int
+}
+
+// paletteView is the : prompt with the entries matching what is typed
+type paletteView struct {
+	input    string
+	selected int
+}
+
+// openPalette opens the command palette
+func (m *Model) openPalette() {
+	m.showHelp = false
+	m.explanation = ""
+	m.palette = &paletteView{}
+}
+
+// paletteEntries returns the entries matching the first word typed, best
+// first
+func (m *Model) paletteEntries() []paletteEntry {
+	word, _, _ := strings.Cut(strings.TrimSpace(m.palette.input), " ")
+
+	var entries []paletteEntry
+	add := func(entry paletteEntry) {
+		if score, ok := fuzzyScore(word, entry.name); ok {
+			entry.score = score
+		} else if word != "" && strings.Contains(strings.ToLower(entry.help), strings.ToLower(word)) {
+			entry.score = 0
+		} else {
+			return
+		}
+		entries = append(entries, entry)
+	}
+	for i := range paletteCommands {
+		cmd := &paletteCommands[i]
+		add(paletteEntry{name: cmd.name, hint: cmd.usage, help: cmd.help, command: cmd})
+	}
+	for _, binding := range m.keys.Bindings() {
+		if binding.Mode() != modeMain || binding.Action == "command_palette" {
+			continue
+		}
+		add(paletteEntry{name: binding.Action, hint: m.keys.Keys(binding.Action), help: binding.Help})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].score > entries[j].score
+	})
+	return entries
+}
+
+// fuzzyScore matches a pattern as a subsequence of a name, scoring
+// prefixes, then substrings, then tight subsequences highest
+func fuzzyScore(pattern, name string) (int, bool) {
+	pattern, name = strings.ToLower(pattern), strings.ToLower(name)
+	switch {
+	case pattern == "":
+		return 1, true
+	case name == pattern:
+		return 1000, true
+	case strings.HasPrefix(name, pattern):
+		return 900 - len(name), true
+	case strings.Contains(name, pattern):
+		return 800 - strings.Index(name, pattern), true
+	}
+
+	pos, first, gaps := 0, -1, 0
+	for _, r := range pattern {
+		i := strings.IndexRune(name[pos:], r)
+		if i < 0 {
+			return 0, false
+		}
+		if first < 0 {
+			first = pos + i
+		} else {
+			gaps += i
+		}
+		pos += i + len(string(r))
+	}
+	return max(1, 500-10*gaps-first), true
+}
+
+// updatePalette handles keys while the palette is open
+func (m *Model) updatePalette(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	pv := m.palette
+	entries := m.paletteEntries()
+
+	switch key := msg.String(); key {
+	case "esc", "ctrl+c":
+		m.palette = nil
+	case "up", "ctrl+p", "shift+tab":
+		pv.selected = max(0, pv.selected-1)
+	case "down", "ctrl+n":
+		pv.selected = min(max(0, len(entries)-1), pv.selected+1)
+	case "tab":
+		if pv.selected < len(entries) {
+			pv.input = completeEntry(entries[pv.selected])
+		}
+	case "backspace":
+		if len(pv.input) > 0 {
+			pv.input = pv.input[:len(pv.input)-1]
+			pv.selected = 0
+		}
+	case "enter":
+		return m, m.runPalette(entries)
+	default:
+		if len(key) == 1 && key[0] >= 32 && key[0] <= 126 {
+			pv.input += key
+			pv.selected = 0
+		}
+	}
+	return m, nil
+}
+
+// completeEntry returns the input completing an entry
+func completeEntry(entry paletteEntry) string {
+	if entry.command != nil {
+		return entry.name + " "
+	}
+	return entry.name
+}
+
+// runPalette runs what was typed: a command or action by name, else the
+// selected entry. A count after an action repeats it, as typed before keys
+func (m *Model) runPalette(entries []paletteEntry) tea.Cmd {
+	pv := m.palette
+	name, args, _ := strings.Cut(strings.TrimSpace(pv.input), " ")
+	args = strings.TrimSpace(args)
+
+	var entry *paletteEntry
+	for i := range entries {
+		if entries[i].name == name {
+			entry = &entries[i]
+			break
+		}
+	}
+	if entry == nil {
+		if pv.selected >= len(entries) {
+			m.palette = nil
+			m.setStatusMessage(fmt.Sprintf("Unknown command: %s", name))
+			return nil
+		}
+		entry = &entries[pv.selected]
+		if entry.command != nil && args == "" && !strings.HasSuffix(entry.command.usage, "]") {
+			pv.input = completeEntry(*entry) // Arguments still to type
+			return nil
+		}
+	}
+
+	m.palette = nil
+	if entry.command == nil {
+		count, err := strconv.Atoi(args)
+		if args != "" && err != nil {
+			m.setStatusMessage(fmt.Sprintf("%s takes a count, not %q", entry.name, args))
+			return nil
+		}
+		return m.runAction(entry.name, count)
+	}
+	cmd, err := entry.command.run(m, args)
+	if err != nil {
+		m.setStatusMessage(fmt.Sprintf("%s: %s (usage: %s)", entry.name, err.Error(), entry.command.usage))
+	}
+	return cmd
+}
+
+// paletteTheme switches the color theme
+func (m *Model) paletteTheme(args string) (tea.Cmd, error) {
+	for _, theme := range m.GetAvailableThemes() {
+		if theme == args {
+			m.SetTheme(theme)
+			m.setStatusMessage("Theme " + theme)
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown theme %q", args)
+}
+
+// paletteExport exports the filtered lines or results in a format
+func (m *Model) paletteExport(args string) (tea.Cmd, error) {
+	fields := strings.Fields(args)
+	format, path := export.FormatCSV, ""
+	if len(fields) > 0 {
+		format = export.ExportFormat(strings.ToLower(fields[0]))
+	}
+	if len(fields) > 1 {
+		path = strings.Join(fields[1:], " ")
+	}
+	switch format {
+	case export.FormatCSV, export.FormatJSON, export.FormatText, export.FormatHTML:
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	m.exportAs(format, path)
+	return nil, nil
+}
+
+// paletteGoto moves the cursor of the active pane to a line
+func (m *Model) paletteGoto(args string) (tea.Cmd, error) {
+	return nil, m.gotoTarget(args)
+}
+
+// paletteMark bookmarks the selected line under a name
+func (m *Model) paletteMark(args string) (tea.Cmd, error) {
+	if args == "" {
+		return nil, fmt.Errorf("no name given")
+	}
+	n := len(m.bookmarks)
+	m.addBookmark()
+	if len(m.bookmarks) > n {
+		m.bookmarks[n].Name = args
+		m.setStatusMessage("Bookmarked as " + args)
+	}
+	return nil, nil
+}
+
+// paletteSource starts or stops tailing a file
+func (m *Model) paletteSource(args string) (tea.Cmd, error) {
+	verb, path, _ := strings.Cut(args, " ")
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("no path given")
+	}
+
+	switch verb {
+	case "add":
+		m.addSource(path)
+	case "remove", "rm":
+		for _, file := range m.tailer.GetWatchedFiles() {
+			if file == path || filepath.Base(file) == path {
+				cmd, _ := m.removeSource(file)
+				return cmd, nil
+			}
+		}
+		return nil, fmt.Errorf("not tailing %s", path)
+	default:
+		return nil, fmt.Errorf("unknown subcommand %q", verb)
+	}
+	return nil, nil
+}
+
+// paletteFilter applies a query
+func (m *Model) paletteFilter(args string) (tea.Cmd, error) {
+	m.searchInput = args
+	m.searchCursor = len(args)
+	return m.applySearch()
+}
+
+// paletteTab opens a query tab
+func (m *Model) paletteTab(args string) (tea.Cmd, error) {
+	return m.openQueryTab(tabCommand + " " + args)
+}
+
+// paletteExplain explains a query
+func (m *Model) paletteExplain(args string) (tea.Cmd, error) {
+	return nil, m.explainQuery(explainCommand + " " + args)
+}
+
+// paletteHeight returns the lines the palette takes from the panes
+func (m *Model) paletteHeight() int {
+	if m.palette == nil {
+		return 0
+	}
+	return 2 + max(1, min(paletteRows, len(m.paletteEntries())))
+}
+
+// renderPalette renders the prompt above the entries matching it
+func (m *Model) renderPalette() string {
+	pv := m.palette
+	style := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#ff00ff")).
+		Padding(0, 1)
+	hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#000000")).Background(lipgloss.Color("#ff00ff"))
+
+	entries := m.paletteEntries()
+	pv.selected = min(pv.selected, max(0, len(entries)-1))
+	first := max(0, pv.selected-paletteRows+1)
+
+	lines := []string{":" + pv.input + "█"}
+	width := m.width - 6
+	for i := first; i < len(entries) && i < first+paletteRows; i++ {
+		entry := entries[i]
+		text := fmt.Sprintf("%-18s %-36s %s", entry.name, entry.hint, entry.help)
+		if runes := []rune(text); len(runes) > width {
+			text = string(runes[:max(0, width-3)]) + "..."
+		}
+		if i == pv.selected {
+			lines = append(lines, selectedStyle.Render(text))
+		} else {
+			lines = append(lines, hintStyle.Render(text))
+		}
+	}
+	if len(entries) == 0 {
+		lines = append(lines, hintStyle.Render("No matching command"))
+	}
+	return style.Width(m.width - 2).Render(strings.Join(lines, "\n"))
+}
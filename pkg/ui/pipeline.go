@@ -0,0 +1,236 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/export"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
+)
+
+// pipelineRefreshInterval throttles re-running a pipeline while lines stream in
+const pipelineRefreshInterval = time.Second
+
+// maxColumnWidth caps the width of a table column
+const maxColumnWidth = 40
+
+// pipelineMsg carries the result of a pipeline run
+type pipelineMsg struct {
+	run    int
+	result *pipeline.Result
+	err    error
+}
+
+// setPipeline installs the stages of a query, or removes them when nil
+func (m *Model) setPipeline(pl *pipeline.Pipeline) tea.Cmd {
+	m.pipelineRun++ // Results of a running pipeline are stale
+	m.pipelineBusy = false
+	m.resultColumns = nil
+	if pl == nil || !pl.HasStages() {
+		m.pipeline = nil
+		m.resultBuffer = nil
+		m.filteredPane.title = "Filtered Logs"
+		return nil
+	}
+
+	m.pipeline = pl
+	m.resultBuffer = NewCircularBuffer(1)
+	m.filteredPane.title = "Results: " + pl.String()
+	m.filteredPane.scrollY = 0
+	m.filteredPane.cursorY = 0
+	return m.runPipeline()
+}
+
+// runPipeline evaluates the pipeline over the filtered lines, or over all
+// lines when the query has no filter expression. It runs on a snapshot of
+// the lines off the Update path; handlePipeline shows the result.
+func (m *Model) runPipeline() tea.Cmd {
+	if m.pipeline == nil {
+		return nil
+	}
+
+	source := m.allLinesBuffer
+	if m.filter.HasFilter() {
+		source = m.filteredBuffer
+	}
+
+	pl, f := m.pipeline, m.filter.Snapshot()
+	lines := source.GetRange(0, source.Size())
+	m.pipelineRun++
+	run := m.pipelineRun
+	m.pipelineBusy = true
+	m.pipelineDirty = false
+	m.lastPipelineRun = time.Now()
+	return func() tea.Msg {
+		result, err := pl.Run(lines, f)
+		return pipelineMsg{run: run, result: result, err: err}
+	}
+}
+
+// handlePipeline shows the result of the latest pipeline run
+func (m *Model) handlePipeline(msg pipelineMsg) {
+	if msg.run != m.pipelineRun || m.pipeline == nil {
+		return // A newer run or query replaced it
+	}
+	m.pipelineBusy = false
+	if msg.err != nil {
+		m.setStatusMessage(fmt.Sprintf("Pipeline error: %s", msg.err.Error()))
+		return
+	}
+
+	result := msg.result
+	capacity := len(result.Rows)
+	if capacity == 0 {
+		capacity = 1
+	}
+	m.resultBuffer = NewCircularBuffer(capacity)
+	for _, row := range result.Rows {
+		m.resultBuffer.Add(row)
+	}
+	m.resultColumns = result.Columns
+}
+
+// refreshPipeline re-runs a pipeline whose input changed, at most once per
+// refresh interval and never while a run is in progress
+func (m *Model) refreshPipeline() tea.Cmd {
+	if m.pipeline != nil && m.refilter == nil && !m.pipelineBusy && m.pipelineDirty && time.Since(m.lastPipelineRun) >= pipelineRefreshInterval {
+		return m.runPipeline()
+	}
+	return nil
+}
+
+// filteredView returns the buffer shown in the filtered pane: pipeline
+// results when a pipeline is active, the filtered lines otherwise
+func (m *Model) filteredView() *CircularBuffer {
+	if m.pipeline != nil && m.resultBuffer != nil {
+		return m.resultBuffer
+	}
+	if m.showsContext() {
+		return m.contextBuffer()
+	}
+	return m.filteredBuffer
+}
+
+// renderTableContent renders pipeline rows as aligned columns
+func (m *Model) renderTableContent(pane *LogPane, height int, buffer *CircularBuffer) string {
+	rows := buffer.GetRange(0, buffer.Size())
+
+	widths := make([]int, len(m.resultColumns))
+	cells := make([][]string, len(rows))
+	for i, column := range m.resultColumns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	for r, row := range rows {
+		cells[r] = make([]string, len(m.resultColumns))
+		for i, column := range m.resultColumns {
+			value := m.tableCell(row, column)
+			cells[r][i] = value
+			widths[i] = max(widths[i], utf8.RuneCountInString(value))
+		}
+	}
+	for i := range widths {
+		if widths[i] > maxColumnWidth {
+			widths[i] = maxColumnWidth
+		}
+	}
+
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#00ffff"))
+	content := []string{"  " + headerStyle.Render(formatTableRow(m.resultColumns, widths))}
+
+	startIdx := pane.scrollY
+	if startIdx > len(rows)-1 {
+		startIdx = len(rows) - 1
+	}
+	if startIdx < 0 {
+		startIdx = 0
+	}
+
+	for r := startIdx; r < len(rows) && len(content) < height; r++ {
+		prefix := "  "
+		if pane.showCursor && r == startIdx+pane.cursorY {
+			prefix = "> "
+		}
+		line := prefix + formatTableRow(cells[r], widths)
+		if maxWidth := pane.width - 4; maxWidth > 10 {
+			line = truncateText(line, maxWidth)
+		}
+		content = append(content, line)
+	}
+
+	if len(rows) == 0 {
+		content = append(content, "  No results")
+	}
+
+	for len(content) < height {
+		content = append(content, "")
+	}
+
+	return strings.Join(content, "\n")
+}
+
+// exportView writes the filtered pane, or the pipeline result with its
+// columns and any fields added by rex or eval, to a CSV file
+func (m *Model) exportView() {
+	m.exportAs(export.FormatCSV, "")
+}
+
+// exportAs writes what exportView exports in a format, to a timestamped
+// file in the working directory when no path is given
+func (m *Model) exportAs(format export.ExportFormat, path string) {
+	buffer := m.filteredView()
+	if buffer == m.matchContext.buffer {
+		buffer = m.filteredBuffer // Matches only, without context
+	}
+	if buffer == nil || buffer.Size() == 0 {
+		m.setStatusMessage("Nothing to export")
+		return
+	}
+
+	options := export.ExportOptions{
+		Format:        format,
+		OutputPath:    path,
+		Fields:        m.resultColumns,
+		IncludeParsed: len(m.resultColumns) == 0,
+	}
+	if options.OutputPath == "" {
+		options.OutputPath = fmt.Sprintf("traceace-export-%s.%s", time.Now().Format("20060102-150405"), format)
+	}
+	if err := export.New().ExportLines(buffer.GetRange(0, buffer.Size()), options); err != nil {
+		m.setStatusMessage(fmt.Sprintf("Export failed: %s", err.Error()))
+		return
+	}
+	m.setStatusMessage(fmt.Sprintf("Exported %d rows to %s", buffer.Size(), options.OutputPath))
+}
+
+// tableCell returns the display value of a column, joining multiple values
+func (m *Model) tableCell(row *models.LogLine, column string) string {
+	return strings.Join(m.filter.FieldValues(row, column), ";")
+}
+
+// formatTableRow pads and truncates cells to their column widths
+func formatTableRow(cells []string, widths []int) string {
+	parts := make([]string, len(cells))
+	for i, cell := range cells {
+		parts[i] = fmt.Sprintf("%-*s", widths[i], truncateText(cell, widths[i]))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// truncateText cuts text to at most width characters, ending it with "..."
+// when there is room for it. It counts runes, so multibyte characters are
+// never split.
+func truncateText(text string, width int) string {
+	runes := []rune(text)
+	switch {
+	case len(runes) <= width:
+		return text
+	case width > 3:
+		return string(runes[:width-3]) + "..."
+	}
+	return string(runes[:max(0, width)])
+}
@@ -0,0 +1,343 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/index"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// refilterChunkSize is the number of lines a worker filters at a time; the
+// job checks for cancellation between chunks
+const refilterChunkSize = 5000
+
+// progressBarWidth is the width of the re-filter progress bar
+const progressBarWidth = 20
+
+// refilterJob re-filters the buffered lines in the background, for the
+// filtered pane or a query tab
+type refilterJob struct {
+	id        int
+	tab       *logTab // Query tab being filled; nil for the filtered pane
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	updates   chan refilterMsg
+	total     int // Lines to check: every buffered line, or the index's candidates
+	buffered  int
+	indexed   bool
+	processed int
+	matches   int
+	started   time.Time
+	backlog   []*models.LogLine // Lines tailed while the job runs, filtered when it ends
+}
+
+// refilterMsg carries the matches of the next chunks, in buffer order. The
+// first message of a job reports the lines it checks once it has planned.
+type refilterMsg struct {
+	job       int
+	planned   bool
+	total     int
+	indexed   bool
+	lines     []*models.LogLine
+	processed int
+	finished  bool
+}
+
+// startRefilter cancels any running re-filter and starts a new one over
+// every buffered line. Matches stream into the filtered pane in order.
+func (m *Model) startRefilter() tea.Cmd {
+	m.cancelRefilter()
+
+	m.filteredBuffer.Clear()
+	m.filter.ResetState()
+	m.resetFiltered()
+	if !m.filter.HasFilter() {
+		return nil
+	}
+
+	m.refilter = m.runRefilter(m.filter, m.activeTab().scope())
+	return m.refilter.wait()
+}
+
+// runRefilter starts a job filtering the buffered lines of a source, or
+// of every source, through a snapshot of a filter. The job asks the search
+// index for candidates before filtering, off the Update path.
+func (m *Model) runRefilter(f *filter.FilterEngine, scope string) *refilterJob {
+	ctx, cancel := context.WithCancel(m.ctx)
+	m.refilterSeq++
+	lines := m.allLinesBuffer.GetRange(0, m.allLinesBuffer.Size())
+	job := &refilterJob{
+		id:       m.refilterSeq,
+		cancel:   cancel,
+		updates:  make(chan refilterMsg),
+		total:    len(lines),
+		buffered: len(lines),
+		started:  time.Now(),
+	}
+
+	// Workers use a snapshot so a new query can be set while they wind down
+	snapshot := f.Snapshot()
+	ix := m.searchIndex
+	oldest := ix.Next() - uint32(len(lines))
+	job.wg.Add(1)
+	go func() {
+		defer job.wg.Done()
+		defer close(job.updates)
+
+		lines, indexed := candidateLines(ix, snapshot.Expression(), lines, oldest)
+		if scope != "" {
+			inScope := make([]*models.LogLine, 0, len(lines))
+			for _, line := range lines {
+				if line != nil && line.Source == scope {
+					inScope = append(inScope, line)
+				}
+			}
+			lines = inScope
+		}
+		if !job.send(ctx, refilterMsg{planned: true, total: len(lines), indexed: indexed}) {
+			return
+		}
+
+		switch {
+		case len(lines) == 0:
+			job.send(ctx, refilterMsg{finished: true})
+		case snapshot.IsStateful():
+			job.runSequential(ctx, snapshot, lines)
+		default:
+			job.runParallel(ctx, snapshot, lines)
+		}
+	}()
+	return job
+}
+
+// wait returns a command that delivers the job's next update
+func (job *refilterJob) wait() tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-job.updates
+		if !ok {
+			return nil
+		}
+		return msg
+	}
+}
+
+// send delivers an update unless the job was cancelled
+func (job *refilterJob) send(ctx context.Context, msg refilterMsg) bool {
+	msg.job = job.id
+	select {
+	case job.updates <- msg:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// runParallel splits the lines into chunks filtered by GOMAXPROCS workers
+// and sends the results in order as soon as each prefix of chunks is done
+func (job *refilterJob) runParallel(ctx context.Context, f *filter.FilterEngine, lines []*models.LogLine) {
+	chunks := (len(lines) + refilterChunkSize - 1) / refilterChunkSize
+	results := make([][]*models.LogLine, chunks)
+	work := make(chan int, chunks)
+	done := make(chan int, chunks)
+	for i := 0; i < chunks; i++ {
+		work <- i
+	}
+	close(work)
+
+	var workers sync.WaitGroup
+	for w := 0; w < min(runtime.GOMAXPROCS(0), chunks); w++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for i := range work {
+				if ctx.Err() != nil {
+					return
+				}
+				end := min((i+1)*refilterChunkSize, len(lines))
+				var matched []*models.LogLine
+				for _, line := range lines[i*refilterChunkSize : end] {
+					if line != nil && f.Match(line) {
+						matched = append(matched, line)
+					}
+				}
+				results[i] = matched
+				done <- i
+			}
+		}()
+	}
+	defer workers.Wait()
+
+	ready := make([]bool, chunks)
+	next := 0
+	for next < chunks {
+		select {
+		case i := <-done:
+			ready[i] = true
+		case <-ctx.Done():
+			return
+		}
+
+		if !ready[next] {
+			continue
+		}
+		var matched []*models.LogLine
+		for next < chunks && ready[next] {
+			matched = append(matched, results[next]...)
+			results[next] = nil
+			next++
+		}
+
+		msg := refilterMsg{lines: matched, processed: min(next*refilterChunkSize, len(lines)), finished: next == chunks}
+		if !job.send(ctx, msg) {
+			return
+		}
+	}
+}
+
+// runSequential feeds the lines in order through a stateful filter, such
+// as a sequence query, whose matches depend on earlier lines
+func (job *refilterJob) runSequential(ctx context.Context, f *filter.FilterEngine, lines []*models.LogLine) {
+	for start := 0; start < len(lines); start += refilterChunkSize {
+		if ctx.Err() != nil {
+			return
+		}
+		end := min(start+refilterChunkSize, len(lines))
+		var matched []*models.LogLine
+		for _, line := range lines[start:end] {
+			if line != nil {
+				matched = append(matched, f.Feed(line)...)
+			}
+		}
+		if !job.send(ctx, refilterMsg{lines: matched, processed: end, finished: end == len(lines)}) {
+			return
+		}
+	}
+}
+
+// cancelRefilter stops a running re-filter and waits for its workers, so
+// the filter's state is not shared with a job still winding down
+func (m *Model) cancelRefilter() bool {
+	job := m.refilter
+	if job == nil {
+		return false
+	}
+	m.refilter = nil
+	job.cancel()
+	job.wg.Wait()
+	return true
+}
+
+// handleRefilter adds a job's matches to the filtered pane and finishes
+// the job after its last chunk
+func (m *Model) handleRefilter(msg refilterMsg) tea.Cmd {
+	if tab := m.fillingTab(msg.job); tab != nil {
+		return m.handleTabFill(tab, msg)
+	}
+	job := m.refilter
+	if job == nil || msg.job != job.id {
+		return nil // cancelled
+	}
+	if msg.planned {
+		job.total, job.indexed = msg.total, msg.indexed
+		return job.wait()
+	}
+
+	for _, line := range msg.lines {
+		m.addFiltered(line)
+	}
+	job.processed = msg.processed
+	job.matches += len(msg.lines)
+	m.autoScrollToBottom()
+
+	if !msg.finished {
+		return job.wait()
+	}
+
+	m.refilter = nil
+	job.wg.Wait()
+
+	// Sequences whose window has already passed are over, and lines that
+	// arrived meanwhile are filtered in order after the buffered ones
+	for _, line := range m.filter.Expire(time.Now()) {
+		m.addFiltered(line)
+		job.matches++
+	}
+	for _, line := range job.backlog {
+		if !m.inFilterScope(line) {
+			continue
+		}
+		for _, matched := range m.filter.Feed(line) {
+			m.addFiltered(matched)
+			job.matches++
+		}
+	}
+	cmd := m.runPipeline()
+
+	duration := time.Since(job.started)
+	switch {
+	case job.indexed:
+		m.setStatusMessage(fmt.Sprintf("⚡ Found %d/%d matches in %v via index (%d candidates)",
+			job.matches, job.buffered, duration.Round(time.Millisecond), job.total))
+	case duration.Milliseconds() > 0:
+		linesPerSec := int64(float64(job.total) / duration.Seconds())
+		m.setStatusMessage(fmt.Sprintf("⚡ Found %d/%d matches in %v (%dk lines/sec)",
+			job.matches, job.buffered, duration.Round(time.Millisecond), linesPerSec/1000))
+	default:
+		m.setStatusMessage(fmt.Sprintf("⚡ Found %d/%d matches instantly!", job.matches, job.buffered))
+	}
+	return cmd
+}
+
+// candidateLines returns the lines of a buffer snapshot a filter must
+// check: the ones the search index selects for it, or else every line.
+// oldest is the sequence number of the snapshot's first line.
+func candidateLines(ix *index.Index, expr filter.QueryExpression, lines []*models.LogLine, oldest uint32) ([]*models.LogLine, bool) {
+	seqs, ok := ix.Candidates(expr)
+	if !ok {
+		return lines, false
+	}
+
+	selected := make([]*models.LogLine, 0, len(seqs))
+	for _, seq := range seqs {
+		if seq >= oldest && int(seq-oldest) < len(lines) {
+			selected = append(selected, lines[seq-oldest])
+		}
+	}
+	return selected, true
+}
+
+// indexLine adds a buffered line to the search index, pruning lines the
+// buffer evicted once they make up a tenth of its capacity
+func (m *Model) indexLine(line *models.LogLine) {
+	ix := m.searchIndex
+	enabled := ix.Enabled()
+	line.Seq = ix.Add(line)
+	if enabled && !ix.Enabled() {
+		m.setStatusMessage("Search index exceeded max_index_size; searching by scan")
+		return
+	}
+
+	oldest := ix.Next() - uint32(m.allLinesBuffer.Size())
+	if ix.Enabled() && oldest-ix.First() > uint32(max(m.maxBufferSize/10, 1)) {
+		ix.Prune(oldest)
+	}
+}
+
+// renderRefilterProgress renders the progress bar of a running re-filter
+func (m *Model) renderRefilterProgress() string {
+	job := m.refilter
+	if job == nil || job.total == 0 {
+		return ""
+	}
+
+	filled := job.processed * progressBarWidth / job.total
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+	return fmt.Sprintf("Filtering %s %d%% (%d matches, esc to cancel)", bar, job.processed*100/job.total, job.matches)
+}
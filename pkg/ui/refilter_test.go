@@ -0,0 +1,136 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/loganalyzer/traceace/pkg/config"
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+)
+
+// refilterLines spans several chunks so workers finish out of order
+const refilterLines = 3*refilterChunkSize + 17
+
+// requestLine is line i of the test log; every 500th took 7ms
+func requestLine(i int) *models.LogLine {
+	return &models.LogLine{Raw: fmt.Sprintf("request req%d took %dms", i, i%500), Source: "app.log"}
+}
+
+// newTestModel returns a model holding the first n request lines
+func newTestModel(t *testing.T, n int) *Model {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	m, err := NewModel(config.DefaultConfig(), ctx)
+	if err != nil {
+		t.Fatalf("NewModel failed: %v", err)
+	}
+	for i := 0; i < n; i++ {
+		m.addLogLine(requestLine(i))
+	}
+	m.simpleBatcher.ForceBatch(m)
+	return m
+}
+
+// collect runs a job body and returns the lines it sends, in order
+func collect(run func(job *refilterJob)) []*models.LogLine {
+	job := &refilterJob{updates: make(chan refilterMsg)}
+	go func() {
+		defer close(job.updates)
+		run(job)
+	}()
+
+	var lines []*models.LogLine
+	for msg := range job.updates {
+		lines = append(lines, msg.lines...)
+	}
+	return lines
+}
+
+// step handles the next update of the running re-filter
+func step(t *testing.T, m *Model) {
+	t.Helper()
+	msg, ok := <-m.refilter.updates
+	if !ok {
+		t.Fatal("Re-filter ended without finishing")
+	}
+	m.handleRefilter(msg)
+}
+
+func TestRefilterParallelMatchesSequential(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	p := parser.New()
+	var lines []*models.LogLine
+	for i := 0; i < refilterLines; i++ {
+		line := requestLine(i)
+		p.ParseLogLine(line)
+		lines = append(lines, line)
+	}
+	f := filter.New(p)
+	if err := f.SetFilter(models.FilterOptions{Query: `"took 7ms"`}); err != nil {
+		t.Fatalf("SetFilter failed: %v", err)
+	}
+
+	ctx := context.Background()
+	parallel := collect(func(job *refilterJob) { job.runParallel(ctx, f, lines) })
+	sequential := collect(func(job *refilterJob) { job.runSequential(ctx, f, lines) })
+
+	want := refilterLines/500 + 1
+	if len(parallel) != want || len(sequential) != want {
+		t.Fatalf("Expected %d matches, got %d parallel and %d sequential", want, len(parallel), len(sequential))
+	}
+	for i := range parallel {
+		if parallel[i] != sequential[i] || parallel[i] != lines[i*500+7] {
+			t.Fatalf("Match %d differs: %q parallel, %q sequential", i, parallel[i].Raw, sequential[i].Raw)
+		}
+	}
+}
+
+func TestRefilterKeepsLinesTailedMidJob(t *testing.T) {
+	// An indexed phrase and a regex the index cannot narrow
+	for _, query := range []string{`"took 7ms"`, `~"took 7ms$"`} {
+		m := newTestModel(t, refilterLines)
+		m.searchInput = query
+		if _, err := m.applySearch(); err != nil {
+			t.Fatalf("%s: applySearch failed: %v", query, err)
+		}
+
+		// Lines tailed after the job's snapshot wait in its backlog, both
+		// before it sends matches and while it streams them
+		added := refilterLines
+		tail := func(n int) {
+			for end := added + n; added < end; added++ {
+				m.addLogLine(requestLine(added))
+			}
+			m.simpleBatcher.ForceBatch(m)
+		}
+		step(t, m)
+		tail(600)
+		if step(t, m); m.refilter != nil {
+			tail(400)
+		}
+		if m.refilter != nil && len(m.refilter.backlog) == 0 {
+			t.Fatalf("%s: expected tailed lines in the backlog", query)
+		}
+		for m.refilter != nil {
+			step(t, m)
+		}
+
+		got := m.filteredBuffer.GetRange(0, m.filteredBuffer.Size())
+		want := (added - 7 + 499) / 500
+		if len(got) != want {
+			t.Fatalf("%s: expected %d matches, got %d", query, want, len(got))
+		}
+		for i, line := range got {
+			if expected := fmt.Sprintf("request req%d took 7ms", i*500+7); line.Raw != expected {
+				t.Fatalf("%s: match %d is %q, expected %q", query, i, line.Raw, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// rateSampleInterval is how often per-source line rates are recomputed
+const rateSampleInterval = time.Second
+
+// sourceStats counts what happened to one file
+type sourceStats struct {
+	lines       int
+	errors      int
+	rotations   int
+	lastEvent   string
+	rate        float64 // Lines per second over the last sample
+	sampleLines int
+}
+
+// sourceManager is the overlay listing watched files
+type sourceManager struct {
+	cursor   int
+	adding   bool // Typing the path of a file to add
+	input    string
+	lastRate time.Time
+}
+
+// statsFor returns the stats of a source, creating them on first use
+func (m *Model) statsFor(source string) *sourceStats {
+	stats, ok := m.sourceStats[source]
+	if !ok {
+		stats = &sourceStats{}
+		m.sourceStats[source] = stats
+	}
+	return stats
+}
+
+// recordSourceEvent updates the stats of the file a tailer event is about
+func (m *Model) recordSourceEvent(event models.TailerEvent) {
+	if event.Source == "" {
+		return
+	}
+	stats := m.statsFor(event.Source)
+	at := time.Now().Format("15:04:05")
+	switch event.Type {
+	case models.EventNewLine:
+		stats.lines++
+	case models.EventFileError:
+		stats.errors++
+		stats.lastEvent = fmt.Sprintf("%s error: %s", at, event.Message)
+		if event.Error != nil {
+			stats.lastEvent = fmt.Sprintf("%s error: %v", at, event.Error)
+		}
+	case models.EventFileRotated:
+		stats.rotations++
+		stats.lastEvent = at + " rotated"
+	}
+}
+
+// sampleSourceRates recomputes the line rate of each source once per
+// sample interval
+func (m *Model) sampleSourceRates() {
+	elapsed := time.Since(m.sources.lastRate)
+	if elapsed < rateSampleInterval {
+		return
+	}
+	for _, stats := range m.sourceStats {
+		if !m.sources.lastRate.IsZero() {
+			stats.rate = float64(stats.lines-stats.sampleLines) / elapsed.Seconds()
+		}
+		stats.sampleLines = stats.lines
+	}
+	m.sources.lastRate = time.Now()
+}
+
+// updateSourceManager handles keys while the source manager is open
+func (m *Model) updateSourceManager(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	sm := &m.sources
+	files := m.tailer.GetWatchedFiles()
+
+	if sm.adding {
+		switch key {
+		case "enter":
+			sm.adding = false
+			if path := strings.TrimSpace(sm.input); path != "" {
+				m.addSource(path)
+			}
+		case "esc":
+			sm.adding = false
+		case "backspace":
+			if len(sm.input) > 0 {
+				sm.input = sm.input[:len(sm.input)-1]
+			}
+		default:
+			if len(key) == 1 && key[0] >= 32 && key[0] <= 126 {
+				sm.input += key
+			}
+		}
+		return m, nil
+	}
+
+	action, count := m.pressKey(modeSources, msg)
+	switch action {
+	case "sources.close":
+		m.showSources = false
+	case "sources.down":
+		sm.cursor = max(0, min(sm.cursor+max(1, count), len(files)-1))
+	case "sources.up":
+		sm.cursor = max(sm.cursor-max(1, count), 0)
+	case "sources.add":
+		sm.adding = true
+		sm.input = ""
+	case "sources.pause":
+		if sm.cursor < len(files) {
+			file := files[sm.cursor]
+			paused := !m.tailer.IsPaused(file)
+			if err := m.tailer.SetPaused(file, paused); err != nil {
+				m.setStatusMessage(err.Error())
+			} else if paused {
+				m.setStatusMessage("Paused " + file)
+			} else {
+				m.setStatusMessage("Resumed " + file)
+			}
+		}
+	case "sources.remove":
+		if sm.cursor < len(files) {
+			cmd, ok := m.removeSource(files[sm.cursor])
+			if ok {
+				sm.cursor = max(0, min(sm.cursor, len(files)-2))
+			}
+			return m, cmd
+		}
+	}
+	return m, nil
+}
+
+// addSource starts tailing a file in a tab of its own
+func (m *Model) addSource(path string) bool {
+	if err := m.tailer.AddFile(path); err != nil {
+		m.setStatusMessage(fmt.Sprintf("Add failed: %s", err.Error()))
+		return false
+	}
+	m.openSourceTab(path)
+	m.setStatusMessage("Tailing " + path)
+	return true
+}
+
+// removeSource stops tailing a file and closes its tab, returning the
+// re-filter of the tab shown instead
+func (m *Model) removeSource(file string) (tea.Cmd, bool) {
+	if err := m.tailer.RemoveFile(file); err != nil {
+		m.setStatusMessage(err.Error())
+		return nil, false
+	}
+	cmd := m.closeSourceTab(file)
+	m.setStatusMessage(fmt.Sprintf("Stopped tailing %s; its lines stay in All", file))
+	return cmd, true
+}
+
+// renderSourceManager renders the watched files with their state and stats
+func (m *Model) renderSourceManager() string {
+	var b strings.Builder
+	b.WriteString("Sources\n\n")
+
+	files := m.tailer.GetWatchedFiles()
+	if len(files) == 0 {
+		b.WriteString("  No files are being tailed.\n")
+	}
+
+	nameWidth := len("FILE")
+	for _, file := range files {
+		nameWidth = max(nameWidth, len(filepath.Base(file)))
+	}
+	nameWidth = min(nameWidth, maxColumnWidth)
+
+	fmt.Fprintf(&b, "    %-*s  %-7s  %9s  %8s  %6s  %9s  %s\n", nameWidth, "FILE", "STATE", "LINES", "LINES/S", "ERRORS", "ROTATIONS", "LAST EVENT")
+	for i, file := range files {
+		stats := m.statsFor(file)
+		state := "tailing"
+		if m.tailer.IsPaused(file) {
+			state = "paused"
+		}
+		cursor := "  "
+		if i == m.sources.cursor {
+			cursor = "> "
+		}
+
+		name := filepath.Base(file)
+		if len(name) > nameWidth {
+			name = name[:nameWidth-3] + "..."
+		}
+		marker := lipgloss.NewStyle().Foreground(m.sourceColor(file)).Render("■")
+		fmt.Fprintf(&b, "%s%s %-*s  %-7s  %9d  %8.1f  %6d  %9d  %s\n",
+			cursor, marker, nameWidth, name, state, stats.lines, stats.rate, stats.errors, stats.rotations, stats.lastEvent)
+	}
+
+	b.WriteString("\n")
+	if m.sources.adding {
+		fmt.Fprintf(&b, "Add file: %s█\n\nEnter to tail it, Esc to cancel.\n", m.sources.input)
+	} else {
+		fmt.Fprintf(&b, "%s add file   %s pause/resume   %s stop tailing   %s/%s select   %s close\n",
+			m.keyHint("sources.add"), m.keyHint("sources.pause"), m.keyHint("sources.remove"),
+			m.keyHint("sources.down"), m.keyHint("sources.up"), m.keyHint("sources.close"))
+	}
+
+	style := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#00ffff")).
+		Padding(1, 2)
+
+	return style.Width(m.width - 4).Height(m.height - 4).Render(b.String())
+}
@@ -0,0 +1,322 @@
+package ui
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/filter"
+	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
+)
+
+// tabCommand is typed in the search bar to open a query tab
+const tabCommand = ":tab"
+
+// maxQueryTabs caps the query tabs open at once, since every tailed line
+// is matched against each of them
+const maxQueryTabs = 8
+
+// tabKind is what a tab shows in the top pane
+type tabKind int
+
+const (
+	tabAll    tabKind = iota // Every line, or the merged timeline
+	tabSource                // Lines of one file
+	tabQuery                 // Lines matching the tab's own query
+)
+
+// logTab is a view in the top pane with its own lines, scroll state and
+// filter of the filtered pane
+type logTab struct {
+	kind   tabKind
+	name   string
+	source string
+	filter *filter.FilterEngine // Query tabs only
+	buffer *CircularBuffer      // Source and query tabs; the All tab shows allView
+	pane   *LogPane
+	query  string       // Filter applied while the tab is active
+	fill   *refilterJob // Query tabs, while their buffered lines are filtered
+}
+
+// scope returns the source a tab's filter is limited to, "" for every line
+func (t *logTab) scope() string {
+	if t.kind == tabSource {
+		return t.source
+	}
+	return ""
+}
+
+// isTabCommand reports whether search input opens a query tab
+func isTabCommand(input string) bool {
+	input = strings.TrimSpace(input)
+	return input == tabCommand || strings.HasPrefix(input, tabCommand+" ")
+}
+
+// activeTab returns the tab shown in the top pane
+func (m *Model) activeTab() *logTab {
+	return m.tabs[m.tabIndex]
+}
+
+// inFilterScope reports whether a line is filtered into the filtered pane:
+// a source tab filters the lines of its file only
+func (m *Model) inFilterScope(line *models.LogLine) bool {
+	scope := m.activeTab().scope()
+	return scope == "" || line.Source == scope
+}
+
+// openSourceTab adds a tab for a file unless it has one
+func (m *Model) openSourceTab(source string) *logTab {
+	for _, tab := range m.tabs {
+		if tab.kind == tabSource && tab.source == source {
+			return tab
+		}
+	}
+
+	tab := &logTab{
+		kind:   tabSource,
+		name:   filepath.Base(source),
+		source: source,
+		buffer: NewCircularBuffer(m.maxBufferSize),
+		pane:   &LogPane{title: source},
+	}
+	m.tabs = append(m.tabs, tab)
+	return tab
+}
+
+// openQueryTab adds a tab showing the lines that match a query, starting
+// with the buffered ones
+func (m *Model) openQueryTab(input string) (tea.Cmd, error) {
+	query := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(input), tabCommand))
+	if query == "" {
+		query = m.filter.GetLastOptions().Query
+	} else {
+		query = m.expandShortcuts(query)
+	}
+	if query == "" {
+		return nil, fmt.Errorf("usage: %s <query>", tabCommand)
+	}
+	if m.queryTabs() >= maxQueryTabs {
+		return nil, fmt.Errorf("at most %d query tabs can be open; close one with x", maxQueryTabs)
+	}
+
+	pl, err := pipeline.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if pl.HasStages() {
+		return nil, fmt.Errorf("tabs show lines; pipeline stages are not supported")
+	}
+
+	f := filter.New(m.parser)
+	if err := f.SetFilter(models.FilterOptions{Query: query}); err != nil {
+		return nil, err
+	}
+
+	m.simpleBatcher.ForceBatch(m)
+	tab := &logTab{
+		kind:   tabQuery,
+		name:   query,
+		filter: f,
+		buffer: NewCircularBuffer(m.maxBufferSize),
+		pane:   &LogPane{title: "Query: " + query},
+	}
+	tab.fill = m.runRefilter(f, "")
+	tab.fill.tab = tab
+
+	m.tabs = append(m.tabs, tab)
+	cmd := m.switchTab(len(m.tabs) - 1)
+	m.setStatusMessage(fmt.Sprintf("Opening tab %q...", query))
+	return tea.Batch(cmd, tab.fill.wait()), nil
+}
+
+// queryTabs returns the number of query tabs open
+func (m *Model) queryTabs() int {
+	n := 0
+	for _, tab := range m.tabs {
+		if tab.kind == tabQuery {
+			n++
+		}
+	}
+	return n
+}
+
+// fillingTab returns the query tab a re-filter job fills, if any
+func (m *Model) fillingTab(job int) *logTab {
+	for _, tab := range m.tabs {
+		if tab.fill != nil && tab.fill.id == job {
+			return tab
+		}
+	}
+	return nil
+}
+
+// handleTabFill adds a job's matches to the query tab it fills, then the
+// lines that arrived meanwhile
+func (m *Model) handleTabFill(tab *logTab, msg refilterMsg) tea.Cmd {
+	job := tab.fill
+	for _, line := range msg.lines {
+		tab.buffer.Add(line)
+	}
+	if !msg.finished {
+		return job.wait()
+	}
+
+	tab.fill = nil
+	job.wg.Wait()
+	for _, line := range tab.filter.Expire(time.Now()) {
+		tab.buffer.Add(line)
+	}
+	for _, line := range job.backlog {
+		for _, matched := range tab.filter.Feed(line) {
+			tab.buffer.Add(matched)
+		}
+	}
+
+	if tab == m.activeTab() && !tab.pane.userScrolled && tab.pane.height > 0 {
+		tab.pane.scrollY = max(0, tab.buffer.Size()-m.getContentHeight(tab.pane))
+	}
+	m.setStatusMessage(fmt.Sprintf("Opened tab %q with %d lines", tab.name, tab.buffer.Size()))
+	return nil
+}
+
+// routeToTabs adds a new line to the source and query tabs it belongs to,
+// opening a tab for a file seen for the first time. Lines still coming in
+// from a removed file do not open its tab again.
+func (m *Model) routeToTabs(line *models.LogLine) {
+	found := false
+	for _, tab := range m.tabs {
+		switch tab.kind {
+		case tabSource:
+			if tab.source == line.Source {
+				tab.buffer.Add(line)
+				found = true
+			}
+		case tabQuery:
+			if tab.fill != nil {
+				tab.fill.backlog = append(tab.fill.backlog, line)
+				continue
+			}
+			for _, matched := range tab.filter.Feed(line) {
+				tab.buffer.Add(matched)
+			}
+		}
+	}
+	if !found && m.tailer.IsWatched(line.Source) {
+		m.openSourceTab(line.Source).buffer.Add(line)
+	}
+}
+
+// closeSourceTab removes the tab of a file that is no longer watched
+func (m *Model) closeSourceTab(source string) tea.Cmd {
+	for i, tab := range m.tabs {
+		if tab.kind == tabSource && tab.source == source {
+			return m.removeTab(i)
+		}
+	}
+	return nil
+}
+
+// closeActiveTab closes the active query tab
+func (m *Model) closeActiveTab() tea.Cmd {
+	tab := m.activeTab()
+	if tab.kind != tabQuery {
+		m.setStatusMessage("Only query tabs can be closed; remove files in the source manager (s)")
+		return nil
+	}
+	cmd := m.removeTab(m.tabIndex)
+	m.setStatusMessage(fmt.Sprintf("Closed tab %q", tab.name))
+	return cmd
+}
+
+// removeTab drops a tab, showing the one before it if it was active
+func (m *Model) removeTab(i int) tea.Cmd {
+	removed := m.tabs[i]
+	if job := removed.fill; job != nil {
+		removed.fill = nil
+		job.cancel()
+		job.wg.Wait()
+	}
+	m.tabs = append(m.tabs[:i], m.tabs[i+1:]...)
+	switch {
+	case m.tabIndex == i:
+		m.tabIndex = i - 1
+		return m.showTab(removed)
+	case m.tabIndex > i:
+		m.tabIndex--
+	}
+	return nil
+}
+
+// cycleTab moves to the next or previous tab, wrapping around
+func (m *Model) cycleTab(step int) tea.Cmd {
+	return m.switchTab((m.tabIndex + step + len(m.tabs)) % len(m.tabs))
+}
+
+// switchTab shows a tab in the top pane with the scroll state and filter
+// it was left with
+func (m *Model) switchTab(i int) tea.Cmd {
+	previous := m.activeTab()
+	m.tabIndex = i
+	return m.showTab(previous)
+}
+
+// showTab shows the active tab after the previous one, applying its filter
+// when it differs
+func (m *Model) showTab(previous *logTab) tea.Cmd {
+	showCursor := m.allLogsPane.showCursor
+	m.allLogsPane = m.activeTab().pane
+	m.allLogsPane.showCursor = showCursor
+
+	// A source tab shows the columns saved for its file
+	if tab := m.activeTab(); m.columns != nil && tab.kind == tabSource {
+		m.loadColumns(tab.source)
+	}
+
+	// Follow the tail unless the tab was scrolled away from it
+	if !m.allLogsPane.userScrolled && m.allLogsPane.height > 0 {
+		m.allLogsPane.scrollY = max(0, m.allView().Size()-m.getContentHeight(m.allLogsPane))
+	}
+
+	tab := m.activeTab()
+	if tab.query == previous.query && (tab.query == "" || tab.scope() == previous.scope()) {
+		return nil
+	}
+	m.searchInput = tab.query
+	m.searchCursor = len(tab.query)
+	cmd, err := m.applySearch()
+	if err != nil {
+		m.setStatusMessage(fmt.Sprintf("Filter error: %s", err.Error()))
+	}
+	return cmd
+}
+
+// renderTabBar renders the tab names with the active one highlighted
+func (m *Model) renderTabBar() string {
+	active := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#000000")).
+		Background(lipgloss.Color("#00ff00")).
+		Bold(true).
+		Padding(0, 1)
+	inactive := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#cccccc")).
+		Background(lipgloss.Color("#333333")).
+		Padding(0, 1)
+
+	parts := make([]string, len(m.tabs))
+	for i, tab := range m.tabs {
+		name := truncateText(tab.name, maxColumnWidth)
+		if tab.kind == tabSource && m.tailer.IsPaused(tab.source) {
+			name += " ⏸"
+		}
+		if i == m.tabIndex {
+			parts[i] = active.Render(name)
+		} else {
+			parts[i] = inactive.Render(name)
+		}
+	}
+	return strings.Join(parts, " ")
+}
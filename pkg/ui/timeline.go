@@ -0,0 +1,225 @@
+package ui
+
+import (
+	"container/heap"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+// sourcePalette colors the source gutter, in order of first appearance
+var sourcePalette = []string{"#ff8700", "#00afff", "#87d700", "#d787ff", "#ffd700", "#ff5f87", "#5fd7d7", "#afafaf"}
+
+// timelineEntry is a line waiting to be merged, with its arrival time
+type timelineEntry struct {
+	line    *models.LogLine
+	arrived time.Time
+}
+
+// timelineSource queues the lines of one source in file order
+type timelineSource struct {
+	name    string
+	queue   []timelineEntry
+	arrived time.Time // Arrival of the last line, or when the source was expected
+}
+
+// sourceHeap orders the sources with queued lines by their next timestamp
+type sourceHeap []*timelineSource
+
+func (h sourceHeap) Len() int { return len(h) }
+
+func (h sourceHeap) Less(i, j int) bool {
+	a, b := h[i].queue[0], h[j].queue[0]
+	if a.line.Timestamp.Equal(b.line.Timestamp) {
+		return a.arrived.Before(b.arrived)
+	}
+	return a.line.Timestamp.Before(b.line.Timestamp)
+}
+
+func (h sourceHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *sourceHeap) Push(x interface{}) { *h = append(*h, x.(*timelineSource)) }
+
+func (h *sourceHeap) Pop() interface{} {
+	old := *h
+	src := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return src
+}
+
+// timelineMerger merges the lines of several sources into timestamp order
+// with a k-way merge. While tailing, a line is held until every other source
+// has a line queued or has gone quiet for the reordering window, so late
+// lines from slower sources still land in place.
+type timelineMerger struct {
+	window  time.Duration
+	sources map[string]*timelineSource
+	heads   sourceHeap
+}
+
+// newTimelineMerger creates a merger with the given reordering window
+func newTimelineMerger(window time.Duration) *timelineMerger {
+	return &timelineMerger{
+		window:  window,
+		sources: make(map[string]*timelineSource),
+	}
+}
+
+// expect registers a source before its first line, so lines of other
+// sources wait for it until it goes quiet
+func (t *timelineMerger) expect(source string, now time.Time) *timelineSource {
+	src, ok := t.sources[source]
+	if !ok {
+		src = &timelineSource{name: source, arrived: now}
+		t.sources[source] = src
+	}
+	return src
+}
+
+// push queues a line that arrived at the given time
+func (t *timelineMerger) push(line *models.LogLine, arrived time.Time) {
+	src := t.expect(line.Source, arrived)
+	src.arrived = arrived
+
+	src.queue = append(src.queue, timelineEntry{line: line, arrived: arrived})
+	if len(src.queue) == 1 {
+		heap.Push(&t.heads, src)
+	}
+}
+
+// waiting reports whether a source with no line queued may still send one:
+// it sent a line, or was expected, within the reordering window
+func (t *timelineMerger) waiting(now time.Time) bool {
+	for _, src := range t.sources {
+		if len(src.queue) == 0 && now.Sub(src.arrived) < t.window {
+			return true
+		}
+	}
+	return false
+}
+
+// release returns, in timestamp order, the queued lines that no later line
+// is expected to precede
+func (t *timelineMerger) release(now time.Time) []*models.LogLine {
+	var out []*models.LogLine
+	for len(t.heads) > 0 {
+		// With a line queued from every source that is not quiet the
+		// oldest head is safe
+		if len(t.heads) < len(t.sources) && t.waiting(now) {
+			break
+		}
+
+		src := t.heads[0]
+		head := src.queue[0]
+
+		out = append(out, head.line)
+		src.queue[0] = timelineEntry{}
+		src.queue = src.queue[1:]
+		if len(src.queue) == 0 {
+			heap.Pop(&t.heads)
+		} else {
+			heap.Fix(&t.heads, 0)
+		}
+	}
+	return out
+}
+
+// inheritTimestamp gives a line the parser found no timestamp in the last
+// timestamp of its source, so continuation lines such as stack traces stay
+// with the entry they belong to. Lines before any timestamp take their
+// arrival time. last holds the last timestamp of each source.
+func inheritTimestamp(last map[string]time.Time, line *models.LogLine) {
+	if !line.Timestamp.IsZero() {
+		last[line.Source] = line.Timestamp
+		return
+	}
+	if previous, ok := last[line.Source]; ok {
+		line.Timestamp = previous
+	} else {
+		line.Timestamp = line.Arrived
+	}
+}
+
+// toggleTimeline switches the all logs pane between arrival order and a
+// timeline merged by timestamp across sources
+func (m *Model) toggleTimeline() {
+	if m.timeline != nil {
+		m.timeline = nil
+		m.timelineBuffer = nil
+		m.tabs[0].pane.title = "All Logs"
+		m.setStatusMessage("Showing lines in arrival order")
+		return
+	}
+
+	m.simpleBatcher.ForceBatch(m)
+	m.timeline = newTimelineMerger(time.Duration(m.config.General.MergeWindow) * time.Millisecond)
+	m.timelineBuffer = NewCircularBuffer(m.maxBufferSize)
+	m.tabs[0].pane.title = "Timeline"
+
+	// Buffered lines already arrived; only the newest window is held back
+	// for lines that may still come in from other sources
+	now := time.Now()
+	for _, file := range m.tailer.GetWatchedFiles() {
+		m.timeline.expect(file, now)
+	}
+	m.allLinesBuffer.ForEach(func(line *models.LogLine) bool {
+		m.timeline.push(line, now)
+		return true
+	})
+	m.releaseTimeline()
+
+	m.allLogsPane.userScrolled = false
+	m.autoScrollToBottom()
+	m.setStatusMessage(fmt.Sprintf("Merged timeline of %d sources", len(m.timeline.sources)))
+}
+
+// releaseTimeline moves the lines the merger no longer holds back into the
+// timeline
+func (m *Model) releaseTimeline() {
+	if m.timeline == nil {
+		return
+	}
+	for _, line := range m.timeline.release(time.Now()) {
+		m.timelineBuffer.Add(line)
+	}
+}
+
+// allView returns the buffer of the active tab shown in the top pane
+func (m *Model) allView() *CircularBuffer {
+	if tab := m.activeTab(); tab.kind != tabAll {
+		return tab.buffer
+	}
+	if m.timeline != nil {
+		return m.timelineBuffer
+	}
+	return m.allLinesBuffer
+}
+
+// sourceColor returns the gutter color of a source
+func (m *Model) sourceColor(source string) lipgloss.Color {
+	color, ok := m.sourceColors[source]
+	if !ok {
+		color = lipgloss.Color(sourcePalette[len(m.sourceColors)%len(sourcePalette)])
+		m.sourceColors[source] = color
+	}
+	return color
+}
+
+// sourceGutter renders the colored marker of a line's source
+func (m *Model) sourceGutter(line *models.LogLine) string {
+	return lipgloss.NewStyle().Foreground(m.sourceColor(line.Source)).Render("▌") + " "
+}
+
+// renderSourceLegend renders each watched file after a marker in its
+// gutter color, on the header's colors
+func (m *Model) renderSourceLegend(files []string, base lipgloss.Style) string {
+	parts := make([]string, len(files))
+	for i, file := range files {
+		marker := base.Copy().Foreground(m.sourceColor(file)).Render("■")
+		parts[i] = marker + base.Render(" "+file)
+	}
+	return strings.Join(parts, base.Render("  "))
+}
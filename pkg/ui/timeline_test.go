@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loganalyzer/traceace/pkg/models"
+)
+
+var epoch = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+// logLine returns a line of a source logged some seconds after epoch
+func logLine(source string, secs int, raw string) *models.LogLine {
+	return &models.LogLine{Source: source, Raw: raw, Timestamp: epoch.Add(time.Duration(secs) * time.Second)}
+}
+
+// raws returns the text of lines, to compare their order
+func raws(lines []*models.LogLine) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = line.Raw
+	}
+	return out
+}
+
+func assertOrder(t *testing.T, what string, lines []*models.LogLine, want ...string) {
+	t.Helper()
+	got := raws(lines)
+	if len(got) != len(want) {
+		t.Fatalf("%s: expected %v, got %v", what, want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%s: expected %v, got %v", what, want, got)
+		}
+	}
+}
+
+func TestTimelineMergesAcrossSources(t *testing.T) {
+	now := time.Now()
+	tm := newTimelineMerger(time.Second)
+	tm.push(logLine("app.log", 1, "app 1"), now)
+	tm.push(logLine("app.log", 3, "app 3"), now)
+	tm.push(logLine("db.log", 2, "db 2"), now)
+
+	// app 3 waits: db may still send a line logged before it
+	assertOrder(t, "first release", tm.release(now), "app 1", "db 2")
+
+	tm.push(logLine("db.log", 2, "db 2b"), now.Add(100*time.Millisecond))
+	assertOrder(t, "late line", tm.release(now.Add(200*time.Millisecond)), "db 2b")
+
+	// Once db is quiet for the window, app 3 is released on a tick
+	assertOrder(t, "before the window", tm.release(now.Add(500*time.Millisecond)))
+	assertOrder(t, "after the window", tm.release(now.Add(1200*time.Millisecond)), "app 3")
+}
+
+func TestTimelineWaitsForQuietSource(t *testing.T) {
+	now := time.Now()
+	tm := newTimelineMerger(time.Second)
+	tm.expect("db.log", now)
+	tm.push(logLine("app.log", 5, "app 5"), now)
+
+	// db is tailed but has not sent a line yet
+	assertOrder(t, "expected source", tm.release(now))
+
+	tm.push(logLine("db.log", 4, "db 4"), now.Add(300*time.Millisecond))
+	assertOrder(t, "source arrived", tm.release(now.Add(300*time.Millisecond)), "db 4")
+	assertOrder(t, "source went quiet", tm.release(now.Add(2*time.Second)), "app 5")
+}
+
+func TestTimelineLinesWithoutTimestamps(t *testing.T) {
+	now := time.Now()
+	last := make(map[string]time.Time)
+	tm := newTimelineMerger(time.Second)
+
+	lines := []*models.LogLine{
+		{Source: "app.log", Raw: "banner", Arrived: epoch},
+		logLine("app.log", 1, "app error"),
+		{Source: "app.log", Raw: "  at frame 1"},
+		{Source: "app.log", Raw: "  at frame 2"},
+		logLine("db.log", 2, "db 2"),
+		logLine("app.log", 3, "app 3"),
+	}
+	for _, line := range lines {
+		inheritTimestamp(last, line)
+		tm.push(line, now)
+	}
+
+	// The stack trace stays with its entry, ahead of the db line
+	if !lines[0].Timestamp.Equal(epoch) || !lines[3].Timestamp.Equal(lines[1].Timestamp) {
+		t.Fatalf("Unexpected inherited timestamps %v, %v", lines[0].Timestamp, lines[3].Timestamp)
+	}
+	assertOrder(t, "merged", tm.release(now.Add(2*time.Second)),
+		"banner", "app error", "  at frame 1", "  at frame 2", "db 2", "app 3")
+}
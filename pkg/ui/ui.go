@@ -11,8 +11,11 @@ import (
 	"github.com/loganalyzer/traceace/pkg/config"
 	"github.com/loganalyzer/traceace/pkg/filter"
 	"github.com/loganalyzer/traceace/pkg/highlighter"
+	"github.com/loganalyzer/traceace/pkg/index"
+	"github.com/loganalyzer/traceace/pkg/keymap"
 	"github.com/loganalyzer/traceace/pkg/models"
 	"github.com/loganalyzer/traceace/pkg/parser"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
 	"github.com/loganalyzer/traceace/pkg/tailer"
 )
 
@@ -31,7 +34,7 @@ type Model struct {
 	ready           bool
 	quitting        bool
 	
-	// Panes
+	// Panes; allLogsPane is the pane of the active tab
 	allLogsPane     *LogPane
 	filteredPane    *LogPane
 	activePane      PaneType
@@ -41,9 +44,43 @@ type Model struct {
 	searchActive    bool
 	searchCursor    int
 	
+	// Keys resolved to actions from the config, and bindings not applied
+	keys            *keymap.Keymap
+	keyProblems     []string
+	
+	// Command palette, nil when closed
+	palette         *paletteView
+	
+	// Tabs shown in the top pane: All, one per file, and query tabs
+	tabs            []*logTab
+	tabIndex        int
+	
+	// Source manager
+	showSources     bool
+	sources         sourceManager
+	sourceStats     map[string]*sourceStats
+	
 	// Help
 	showHelp        bool
 	
+	// Explain report of a query, shown until closed
+	explanation     string
+	
+	// Detail pane of the selected line, shown in place of the filtered pane
+	detail          *detailView
+	
+	// Column view of structured lines, nil for raw lines
+	columns         *columnView
+	
+	// Field facets sidebar, nil when closed
+	facets          *facetsView
+	
+	// Histogram of lines per time bucket in the persistent header
+	histogram       *histogramView
+	
+	// Context lines around the matches of the filtered pane
+	matchContext    *contextView
+	
 	// Status
 	statusMessage   string
 	statusTimeout   time.Time
@@ -51,15 +88,36 @@ type Model struct {
 	// Performance
 	lastRender      time.Time
 	batchedUpdates  int
+	lastRelativeRefresh time.Time
 	
 	// Data
 	allLinesBuffer    *CircularBuffer
 	filteredBuffer    *CircularBuffer
 	objectPool        *ObjectPool
 	simpleBatcher     *SimpleBatcher
+	searchIndex       *index.Index
 	maxBufferSize     int
 	isPaused          bool
 	
+	// Pipeline (| stats, top, sort, ...) results shown in the filtered pane
+	pipeline        *pipeline.Pipeline
+	resultBuffer    *CircularBuffer
+	resultColumns   []string
+	pipelineDirty   bool
+	lastPipelineRun time.Time
+	pipelineRun     int  // Sequence number of the latest run
+	pipelineBusy    bool // The latest run has not finished
+	
+	// Chronological merge of all sources shown in the all logs pane
+	timeline        *timelineMerger
+	timelineBuffer  *CircularBuffer
+	lastTimestamps  map[string]time.Time // Last parsed timestamp per source
+	sourceColors    map[string]lipgloss.Color
+	
+	// Background re-filter of the buffered lines
+	refilter        *refilterJob
+	refilterSeq     int
+	
 	// Bookmarks
 	bookmarks       []models.Bookmark
 	
@@ -85,6 +143,13 @@ type LogPane struct {
 	title          string
 	showCursor     bool
 	userScrolled   bool  // Track if user has manually scrolled
+	match          *matchFocus // Match n/N moved to, nil before the first
+}
+
+// matchFocus is the match n/N moved to
+type matchFocus struct {
+	line int // Buffer index of the line
+	span int // Index among the line's match spans
 }
 
 // NewModel creates a new TUI model
@@ -93,9 +158,11 @@ func NewModel(cfg *config.Config, ctx context.Context) (*Model, error) {
 	
 	// Initialize components
 	parser := parser.New()
+	parser.SetMaxDecodeDepth(cfg.DecodeDepth())
 	filterEngine := filter.New(parser)
 	highlighter := highlighter.New(cfg)
 	tailer := tailer.New(ctx)
+	keys, keyProblems := newKeymap(cfg.Keybindings)
 	
 	model := &Model{
 		config:         cfg,
@@ -103,6 +170,8 @@ func NewModel(cfg *config.Config, ctx context.Context) (*Model, error) {
 		parser:         parser,
 		filter:         filterEngine,
 		highlighter:    highlighter,
+		keys:           keys,
+		keyProblems:    keyProblems,
 		ctx:            ctx,
 		cancel:         cancel,
 		maxBufferSize:  cfg.UI.MaxBufferLines,
@@ -110,7 +179,13 @@ func NewModel(cfg *config.Config, ctx context.Context) (*Model, error) {
 		allLinesBuffer: NewCircularBuffer(cfg.UI.MaxBufferLines),
 		filteredBuffer: NewCircularBuffer(cfg.UI.MaxBufferLines),
 		objectPool:     NewObjectPool(),
+		searchIndex:    index.New(cfg.General.MaxIndexSize, cfg.General.IndexNGrams),
+		lastTimestamps: make(map[string]time.Time),
+		sourceColors:   make(map[string]lipgloss.Color),
+		sourceStats:    make(map[string]*sourceStats),
 		bookmarks:      make([]models.Bookmark, 0),
+		histogram:      &histogramView{visible: cfg.UI.ShowHistogram, selected: -1},
+		matchContext:   newContextView(cfg.UI.ContextLines),
 	}
 	
 	// Initialize panes
@@ -118,6 +193,7 @@ func NewModel(cfg *config.Config, ctx context.Context) (*Model, error) {
 		title:      "All Logs",
 		showCursor: true,
 	}
+	model.tabs = []*logTab{{kind: tabAll, name: "All", pane: model.allLogsPane}}
 	model.filteredPane = &LogPane{
 		title:      "Filtered Logs", 
 		showCursor: false,
@@ -126,6 +202,10 @@ func NewModel(cfg *config.Config, ctx context.Context) (*Model, error) {
 	// Ensure filtered buffer starts empty
 	model.filteredBuffer.Clear()
 	
+	if len(keyProblems) > 0 {
+		model.setStatusMessage(fmt.Sprintf("Keybindings: %s (see help)", keyProblems[0]))
+	}
+	
 	// Initialize simple batcher
 	model.simpleBatcher = NewSimpleBatcher()
 	
@@ -151,102 +231,54 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.ready = true
 		m.updatePaneSizes()
 		
+	case tea.MouseMsg:
+		if !m.showHelp && m.explanation == "" && !m.showSources {
+			m.clickHistogram(msg)
+		}
+		return m, nil
+		
 	case tea.KeyMsg:
 		if m.searchActive {
 			return m.updateSearch(msg)
 		}
+		if m.palette != nil {
+			return m.updatePalette(msg)
+		}
+		if m.showSources && msg.String() != "ctrl+c" {
+			return m.updateSourceManager(msg)
+		}
+		if m.detail != nil && !m.showHelp && m.explanation == "" && msg.String() != "ctrl+c" {
+			return m.updateDetail(msg)
+		}
+		if m.histogram.focused && !m.showHelp && m.explanation == "" && msg.String() != "ctrl+c" {
+			return m.updateHistogram(msg)
+		}
+		if m.facets != nil && !m.showHelp && m.explanation == "" && msg.String() != "ctrl+c" {
+			return m.updateFacets(msg)
+		}
 		
-		switch key := msg.String(); key {
-		case "ctrl+c", "q":
-			m.quitting = true
-			m.cancel()
-			return m, tea.Quit
-			
-		case "/":
-			m.searchActive = true
-			m.searchInput = ""
-			m.searchCursor = 0
-			return m, nil
-			
-		case "?":
-			m.showHelp = !m.showHelp
-			return m, nil
-			
-		case "esc":
-			if m.showHelp {
-				m.showHelp = false
-			} else if m.searchActive {
-				m.searchActive = false
-			}
-			return m, nil
-			
-		case " ":
-			m.isPaused = !m.isPaused
-			status := "Resumed"
-			if m.isPaused {
-				status = "Paused"
-			}
-			m.setStatusMessage(fmt.Sprintf("Stream %s", status))
-			return m, nil
-			
-		case "t":
-			if m.activePane == PaneAllLogs {
-				m.activePane = PaneFiltered
-				m.allLogsPane.showCursor = false
-				m.filteredPane.showCursor = true
-			} else {
-				m.activePane = PaneAllLogs
-				m.allLogsPane.showCursor = true
-				m.filteredPane.showCursor = false
-			}
-			return m, nil
-			
-		case "j", "down":
-			m.scrollDown()
-			return m, nil
-			
-		case "k", "up":
-			m.scrollUp()
-			return m, nil
-			
-		case "ctrl+d":
-			m.pageDown()
-			return m, nil
-			
-		case "ctrl+u":
-			m.pageUp()
-			return m, nil
-			
-		case "g":
-			m.goToTop()
-			return m, nil
-			
-		case "G":
-			m.goToBottom()
-			return m, nil
-			
-		case "b":
-			m.addBookmark()
-			return m, nil
-			
-		case "c":
-			m.clearFilter()
-			return m, nil
-			
-		case "n":
-			m.nextMatch()
-			return m, nil
-			
-		case "N":
-			m.previousMatch()
-			return m, nil
+		if msg.String() == "ctrl+c" {
+			return m, m.runAction("quit", 0)
 		}
+		return m, m.runAction(m.pressKey(modeMain, msg))
 		
 	case TailerEventMsg:
 		return m.handleTailerEvent(msg.Event)
 		
+	case refilterMsg:
+		return m, m.handleRefilter(msg)
+		
+	case pipelineMsg:
+		m.handlePipeline(msg)
+		
 	case tickMsg:
-		return m, m.tick()
+		cmd := m.refreshRelativeFilter()
+		m.expireSequences()
+		m.releaseTimeline()
+		m.sampleSourceRates()
+		pipelineCmd := m.refreshPipeline()
+		m.refreshContext()
+		return m, tea.Batch(m.tick(), cmd, pipelineCmd)
 	}
 	
 	return m, tea.Batch(cmds...)
@@ -266,6 +298,14 @@ func (m *Model) View() string {
 		return m.renderHelp()
 	}
 	
+	if m.explanation != "" {
+		return m.renderExplanation()
+	}
+	
+	if m.showSources {
+		return m.renderSourceManager()
+	}
+	
 	// Main layout: two panes + search bar + footer
 	var sections []string
 	
@@ -277,6 +317,8 @@ func (m *Model) View() string {
 	if m.searchActive {
 		searchView := m.renderSearchBar()
 		sections = append(sections, searchView)
+	} else if m.palette != nil {
+		sections = append(sections, m.renderPalette())
 	}
 	
 	// Footer
@@ -295,26 +337,49 @@ func (m *Model) renderPanes() string {
 	searchHeight := 0
 	if m.searchActive {
 		searchHeight = 2
+	} else if m.palette != nil {
+		searchHeight = m.paletteHeight()
 	}
 	
 	footerHeight := 2
 	availableHeight := m.height - searchHeight - footerHeight - 2 // -2 for pane borders
 	
+	var tabBar string
+	if len(m.tabs) > 1 {
+		tabBar = m.renderTabBar() + "\n"
+		availableHeight--
+	}
+	
 	// Split height between two panes (60/40 split)
 	allLogsHeight := availableHeight * 6 / 10
 	filteredHeight := availableHeight - allLogsHeight
 	
+	// The facets sidebar takes its width from the panes
+	paneWidth := m.width
+	if m.facets != nil {
+		paneWidth -= facetsWidth
+	}
+	
 	// Render all logs pane
 	m.allLogsPane.height = allLogsHeight
-	m.allLogsPane.width = m.width
-	allLogsView := m.renderLogPane(m.allLogsPane, m.activePane == PaneAllLogs, m.allLinesBuffer)
+	m.allLogsPane.width = paneWidth
+	allLogsView := m.renderLogPane(m.allLogsPane, m.activePane == PaneAllLogs, m.allView())
 	
-	// Render filtered logs pane
+	// Render filtered logs pane, or the detail pane of the selected line
 	m.filteredPane.height = filteredHeight
-	m.filteredPane.width = m.width
-	filteredView := m.renderLogPane(m.filteredPane, m.activePane == PaneFiltered, m.filteredBuffer)
+	m.filteredPane.width = paneWidth
+	var filteredView string
+	if m.detail != nil {
+		filteredView = m.renderDetail(paneWidth, filteredHeight)
+	} else {
+		filteredView = m.renderLogPane(m.filteredPane, m.activePane == PaneFiltered, m.filteredView())
+	}
 	
-	return allLogsView + "\n" + filteredView
+	panes := allLogsView + "\n" + filteredView
+	if m.facets != nil {
+		panes = lipgloss.JoinHorizontal(lipgloss.Top, m.renderFacets(allLogsHeight+filteredHeight+2), panes)
+	}
+	return tabBar + panes
 }
 
 // renderLogPane renders a single log pane
@@ -338,8 +403,10 @@ func (m *Model) renderLogPane(pane *LogPane, isActive bool, buffer *CircularBuff
 		header = fmt.Sprintf("%s (%d lines)", pane.title, buffer.Size())
 	} else {
 		// For filtered pane, only show count if filter is active
-		if m.filter.HasFilter() {
-			header = fmt.Sprintf("%s (%d/%d lines)", pane.title, buffer.Size(), m.allLinesBuffer.Size())
+		if m.pipeline != nil {
+			header = fmt.Sprintf("%s (%d rows)", pane.title, buffer.Size())
+		} else if m.filter.HasFilter() {
+			header = fmt.Sprintf("%s (%d/%d lines)", pane.title, m.filteredBuffer.Size(), m.allLinesBuffer.Size())
 		} else {
 			header = fmt.Sprintf("%s (no filter active)", pane.title)
 		}
@@ -355,12 +422,22 @@ func (m *Model) renderLogPane(pane *LogPane, isActive bool, buffer *CircularBuff
 	var persistentHeader string
 	if pane == m.allLogsPane {
 		persistentHeader = m.renderPersistentHeader()
+		
+		// Record where the histogram bars are drawn for mouse clicks
+		m.histogram.top = 1 + lipgloss.Height(headerView) + lipgloss.Height(persistentHeader) - m.histogramHeight()
+		if len(m.tabs) > 1 {
+			m.histogram.top++
+		}
+		m.histogram.left = 1
+		if m.facets != nil {
+			m.histogram.left += facetsWidth
+		}
 	}
 	
 	// Content
 	contentHeight := pane.height - 3 // -3 for border and header
 	if pane == m.allLogsPane && persistentHeader != "" {
-		contentHeight -= 2 // -2 for persistent header
+		contentHeight -= 2 + m.histogramHeight() // -2 for persistent header
 	}
 	if contentHeight < 1 {
 		contentHeight = 1
@@ -381,6 +458,13 @@ func (m *Model) renderLogPane(pane *LogPane, isActive bool, buffer *CircularBuff
 
 // renderPaneContent renders the content of a pane
 func (m *Model) renderPaneContent(pane *LogPane, height int, buffer *CircularBuffer) string {
+	if buffer == m.resultBuffer && len(m.resultColumns) > 0 {
+		return m.renderTableContent(pane, height, buffer)
+	}
+	if m.showsColumns(pane) && buffer.Size() > 0 {
+		return m.renderColumnContent(pane, height, buffer)
+	}
+	
 	totalLines := buffer.Size()
 	if totalLines == 0 {
 		if pane == m.filteredPane && !m.filter.HasFilter() {
@@ -413,8 +497,31 @@ func (m *Model) renderPaneContent(pane *LogPane, height int, buffer *CircularBuf
 			continue
 		}
 		
-		// Lazy highlight the line (only when actually visible)
-		highlighted := m.highlighter.Highlight(line)
+		// Context lines are dimmed and groups separated
+		if buffer == m.matchContext.buffer {
+			if row, ok := m.renderContextRow(line, pane.width-4); ok {
+				prefix := "  "
+				if pane.showCursor && i == startIdx+pane.cursorY {
+					prefix = "> "
+				}
+				content = append(content, prefix+row)
+				continue
+			}
+		}
+		
+		// Lazy highlight the line (only when actually visible), marking
+		// what the filter matched
+		spans := m.matchSpans(line)
+		current := -1
+		if pane.match != nil && pane.match.line == i {
+			current = pane.match.span
+		}
+		highlighted := m.highlighter.HighlightMatches(line, spans, current)
+		
+		// Mark each line's source in the merged timeline
+		if buffer == m.timelineBuffer {
+			highlighted = m.sourceGutter(line) + highlighted
+		}
 		
 		// Add cursor indicator
 		if pane.showCursor && i == startIdx+pane.cursorY {
@@ -425,6 +532,9 @@ func (m *Model) renderPaneContent(pane *LogPane, height int, buffer *CircularBuf
 		
 		// Truncate if too long (account for ANSI escape codes)
 		maxWidth := pane.width - 4
+		if buffer == m.timelineBuffer {
+			maxWidth -= 2 // Source gutter
+		}
 		if maxWidth > 10 { // Ensure we have reasonable minimum width
 			// Simple approach: only truncate if the raw text (without ANSI codes) is too long
 			if len(line.Raw) > maxWidth {
@@ -440,7 +550,15 @@ func (m *Model) renderPaneContent(pane *LogPane, height int, buffer *CircularBuf
 					Tokens:    line.Tokens,
 					Offset:    line.Offset,
 				}
-				highlighted = m.highlighter.Highlight(truncatedLine)
+				// Keep the marks off the ellipsis
+				clipped := make([]models.Span, len(spans))
+				for j, span := range spans {
+					clipped[j] = models.Span{Start: min(span.Start, maxWidth-3), End: min(span.End, maxWidth-3)}
+				}
+				highlighted = m.highlighter.HighlightMatches(truncatedLine, clipped, current)
+				if buffer == m.timelineBuffer {
+					highlighted = m.sourceGutter(line) + highlighted
+				}
 			}
 		}
 		
@@ -500,14 +618,20 @@ func (m *Model) renderFooter() string {
 		leftParts = append(leftParts, fmt.Sprintf("Filter: %s", filterSummary))
 	}
 	
-	if m.statusMessage != "" && time.Now().Before(m.statusTimeout) {
+	if progress := m.renderRefilterProgress(); progress != "" {
+		leftParts = append(leftParts, progress)
+	} else if m.statusMessage != "" && time.Now().Before(m.statusTimeout) {
 		leftParts = append(leftParts, m.statusMessage)
 	}
 	
+	if pending := m.keys.Pending(); pending != "" {
+		leftParts = append(leftParts, pending)
+	}
+	
 	leftSide := strings.Join(leftParts, " | ")
 	
 	// Right side - help and examples  
-	rightSide := "⚡ Fast Filter | ? help | / search | Examples: level:ERROR AND status:>400"
+	rightSide := "⚡ Fast Filter | ? help | / search | : commands | Examples: level:ERROR AND status:>400"
 	
 	// Calculate spacing
 	totalUsed := len(leftSide) + len(rightSide)
@@ -523,49 +647,55 @@ func (m *Model) renderFooter() string {
 
 // renderHelp renders the help screen
 func (m *Model) renderHelp() string {
-	helpContent := `
-TraceAce - Help
-
-NAVIGATION:
-  j, ↓       Scroll down
-  k, ↑       Scroll up  
-  Ctrl+d     Page down
-  Ctrl+u     Page up
-  g          Go to top
-  G          Go to bottom
-
-SEARCH & FILTER:
-  /          Open search
-  n          Next match
-  N          Previous match
-  c          Clear filter
-  Esc        Close search/help
-
-CONTROLS:
-  Space      Pause/resume stream
-  t          Toggle active pane
-  b          Add bookmark
-  q          Quit
-  ?          Toggle help
-
-ADVANCED SEARCH SYNTAX:
-  Simple:
-    error                    Text search
+	var commands strings.Builder
+	for _, cmd := range paletteCommands {
+		fmt.Fprintf(&commands, "  :%-35s %s\n", cmd.usage, cmd.help)
+	}
+	
+	helpContent := "\nTraceAce - Help\n\n" + m.renderKeyHelp() + "\nCOMMANDS (: palette; :tab and :explain also work in the search bar):\n" + commands.String() + `
+SEARCH SYNTAX:
+  Terms:
+    error                    Text search (case-insensitive)
+    "Connection lost"        Exact phrase (case-sensitive)
+    ~"time(out|d out)"       Regex
     level:ERROR              Field equals
-    level:~(ERROR|WARN)      Field regex
+    path:/api/*              Field wildcard (* and ?)
+    level:(ERROR|WARN)       Field is any of (also status:IN(500,503))
+    ip:10.0.0.0/8            Address in CIDR network (IPv4/IPv6)
+    user_id:*                Field exists (also _exists_:user_id)
+    level:~"^(E|W)"          Field regex
     level:!=INFO             Field not equals
     status:>200              Numeric greater than
+    status:[400 TO 499]      Range
     time:[14:30:00 TO 15:00:00]  Time range
+    time:>-15m  @today       Relative time (also since:/until:)
+    latency:>250ms size:>10MB  Duration and size units
+    sequence by order_id [event:authorized] [event:captured] within 5m
+    missing by order_id [event:authorized] [event:captured] within 5m
   
-  Complex (Logical Operators):
-    level:ERROR AND status:>400           Multiple conditions
+  Logical Operators (NOT > AND > OR):
+    level:ERROR status:>400               Adjacent terms are ANDed
     ip:192.168.1.1 OR ip:10.0.0.1        Alternative conditions  
-    level:ERROR AND NOT source:test.log   Exclusion
+    level:ERROR AND NOT source:test.log   Exclusion (also !term)
     (level:ERROR OR level:WARN) AND status:>400  Grouping
   
+  Pipelines (results replace the filtered pane):
+    level:ERROR | stats count() by service
+    * | stats avg(latency), p95(latency) by host
+    | top 10 path                         Most common values
+    | sort -latency | fields ts,service,msg
+    | dedup trace_id                      First line per value
+    | rex "user=(?P<user>\w+)"            Extract named captures
+    | eval secs = ms / 1000               Compute fields (if, coalesce, ...)
+    | where secs:>1                       Filter on any field, old or new
+    | transaction trace_id maxspan=30s    Group lines into transactions
+  
   Supported Fields:
     level, source, message, timestamp, status, ip, user, method, url
     Plus any JSON/YAML field (e.g., user.id, response.time)
+    errors[0].code           Array index
+    tags.*:prod              Any element/key matches
+    "http.status_code":500   Quoted key containing dots
   
 Press Esc or ? to close help.
 `
@@ -601,6 +731,8 @@ func (m *Model) tick() tea.Cmd {
 
 // handleTailerEvent processes tailer events
 func (m *Model) handleTailerEvent(event models.TailerEvent) (tea.Model, tea.Cmd) {
+	m.recordSourceEvent(event)
+	
 	switch event.Type {
 	case models.EventNewLine:
 		if event.Line != nil && !m.isPaused {
@@ -640,8 +772,15 @@ func (m *Model) renderPersistentHeader() string {
 		Padding(0, 1).
 		Width(m.width - 6) // Account for padding and borders
 	
-	// Build file info string
+	// Build file info string; the timeline shows which color marks each file
 	fileInfo := strings.Join(watchedFiles, ", ")
+	if m.timeline != nil {
+		legendStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#000000")).
+			Background(lipgloss.Color("#80c0ff")).
+			Bold(true)
+		fileInfo = m.renderSourceLegend(watchedFiles, legendStyle)
+	}
 	
 	// Add more file stats if available
 	var extraInfo string
@@ -667,8 +806,11 @@ func (m *Model) renderPersistentHeader() string {
 		}
 	}
 	
-	// Combine all info and render
+	// Combine all info and render, with the histogram below
 	headerText := "Files: " + fileInfo + extraInfo
+	if bars := m.renderHistogram(m.allLogsPane.width - 4); bars != "" {
+		return headerStyle.Render(headerText) + "\n" + bars
+	}
 	return headerStyle.Render(headerText)
 }
 
@@ -1,22 +1,34 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbletea"
+	"github.com/loganalyzer/traceace/pkg/filter"
 	"github.com/loganalyzer/traceace/pkg/models"
+	"github.com/loganalyzer/traceace/pkg/parser"
+	"github.com/loganalyzer/traceace/pkg/pipeline"
 )
 
 // addLogLine adds a new log line using simple batching
 func (m *Model) addLogLine(line *models.LogLine) {
 	// Parse the line first
 	m.parser.ParseLogLine(line)
+	inheritTimestamp(m.lastTimestamps, line)
 	
 	// Use simple batcher to process in 1000-line chunks
 	m.simpleBatcher.AddLine(line, m)
 	
+	if m.pipeline != nil {
+		m.pipelineDirty = true
+	}
+	if m.filter.HasFilter() {
+		m.matchContext.dirty = true // Later lines may be context of a match
+	}
+	
 	// Batch updates for performance - only auto-scroll every 10 lines or 100ms
 	m.batchedUpdates++
 	now := time.Now()
@@ -33,12 +45,34 @@ func (m *Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	
 	switch key {
 	case "enter":
-		// Apply the search
 		m.searchActive = false
-		if err := m.applySearch(); err != nil {
+		if isExplain(m.searchInput) {
+			if err := m.explainQuery(m.searchInput); err != nil {
+				m.setStatusMessage(fmt.Sprintf("Explain error: %s", err.Error()))
+			}
+			return m, nil
+		}
+		if isTabCommand(m.searchInput) {
+			cmd, err := m.openQueryTab(m.searchInput)
+			if err != nil {
+				m.setStatusMessage(fmt.Sprintf("Tab error: %s", err.Error()))
+			}
+			return m, cmd
+		}
+		
+		// Apply the search
+		cmd, err := m.applySearch()
+		if err != nil {
 			m.setStatusMessage(fmt.Sprintf("Search error: %s", err.Error()))
+			
+			// Keep editing with the cursor on the offending position
+			var queryErr *filter.QueryError
+			if errors.As(err, &queryErr) && strings.HasPrefix(m.searchInput, queryErr.Query) {
+				m.searchActive = true
+				m.searchCursor = queryErr.Pos
+			}
 		}
-		return m, nil
+		return m, cmd
 		
 	case "esc":
 		// Cancel search
@@ -87,54 +121,46 @@ func (m *Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
-// applySearch applies the current search input as a filter
-func (m *Model) applySearch() error {
+// applySearch applies the current search input as a filter and starts
+// re-filtering the buffered lines in the background
+func (m *Model) applySearch() (tea.Cmd, error) {
+	m.activeTab().query = m.searchInput
 	if m.searchInput == "" {
+		m.cancelRefilter()
 		m.filter.Clear()
 		m.filteredBuffer.Clear()
+		m.resetFiltered()
+		m.setPipeline(nil)
 		m.setStatusMessage("Filter cleared")
-		return nil
+		return nil, nil
 	}
 	
 	// Check for predefined shortcuts
 	actualQuery := m.expandShortcuts(m.searchInput)
 	
-	// Check if this is an advanced query (contains logical operators or complex syntax)
-	isAdvanced := m.isAdvancedQuery(actualQuery)
+	// Stages after a top-level '|' run over the filter's matches
+	pl, err := pipeline.Parse(actualQuery)
+	if err != nil {
+		return nil, err
+	}
 	
-	if isAdvanced {
-		// Use advanced filtering
-		if err := m.filter.SetAdvancedFilter(actualQuery); err != nil {
-			return err
-		}
-	} else {
-		// Use simple filtering for backward compatibility
-		isRegex := strings.ContainsAny(actualQuery, ".*+?^${}[]|()")
-		
-		if err := m.filter.ValidateQuery(actualQuery, isRegex); err != nil {
-			return err
-		}
-		
-		options := models.FilterOptions{
-			Query:         actualQuery,
-			IsRegex:       isRegex,
-			CaseSensitive: false,
-		}
-		
-		if err := m.filter.SetFilter(options); err != nil {
-			return err
-		}
+	options := models.FilterOptions{
+		Query:         pl.Filter,
+		CaseSensitive: false,
+	}
+	
+	// Stop the previous re-filter before its filter is replaced
+	m.cancelRefilter()
+	if err := m.filter.SetFilter(options); err != nil {
+		return nil, err
 	}
 	
 	// Force flush any pending batch
 	m.simpleBatcher.ForceBatch(m)
 	
-	// Process all existing lines in 1000-line batches
-	if err := m.ProcessAllExistingLines(); err != nil {
-		return err
-	}
+	cmd := m.setPipeline(pl)
 	
-	return nil
+	return tea.Batch(cmd, m.startRefilter()), nil
 }
 
 // expandShortcuts expands common search shortcuts
@@ -149,12 +175,9 @@ func (m *Model) expandShortcuts(query string) string {
 		"3xx":        "status:>=300 AND status:<400",
 		"2xx":        "status:>=200 AND status:<300",
 		"slow":       "response_time:>1000",
-		"today":      fmt.Sprintf("time:[%s TO %s]", 
-			time.Now().Format("2006-01-02")+" 00:00:00",
-			time.Now().Format("2006-01-02")+" 23:59:59"),
-		"last_hour":  fmt.Sprintf("time:[%s TO %s]",
-			time.Now().Add(-time.Hour).Format("15:04:05"),
-			time.Now().Format("15:04:05")),
+		"today":      "@today",
+		"yesterday":  "@yesterday",
+		"last_hour":  "time:>=-1h",
 	}
 	
 	if expanded, exists := shortcuts[strings.ToLower(query)]; exists {
@@ -163,36 +186,44 @@ func (m *Model) expandShortcuts(query string) string {
 	return query
 }
 
-// isAdvancedQuery determines if a query uses advanced syntax
-func (m *Model) isAdvancedQuery(query string) bool {
-	return strings.Contains(query, " AND ") ||
-		   strings.Contains(query, " OR ") ||
-		   strings.Contains(query, " NOT ") ||
-		   strings.Contains(query, "time:[") ||
-		   strings.Contains(query, ":>") ||
-		   strings.Contains(query, ":<") ||
-		   strings.Contains(query, ":!=") ||
-		   strings.Contains(query, ":~") ||
-		   strings.Count(query, ":") > 1 // Multiple field queries
+// relativeRefreshInterval is how often filters with relative times such as
+// time:>-15m are re-evaluated against lines already in the buffer
+const relativeRefreshInterval = 5 * time.Second
+
+// refreshRelativeFilter re-filters existing lines when the filter moves
+// with the clock, so lines drop out of (or into) a sliding window
+func (m *Model) refreshRelativeFilter() tea.Cmd {
+	if m.refilter != nil || !m.filter.IsRelative() || time.Since(m.lastRelativeRefresh) < relativeRefreshInterval {
+		return nil
+	}
+	m.lastRelativeRefresh = time.Now()
+	return m.startRefilter()
 }
 
-// rebuildFilteredLines rebuilds the filtered lines based on current filter
-func (m *Model) rebuildFilteredLines() {
-	m.filteredBuffer.Clear()
-	
-	m.allLinesBuffer.ForEach(func(line *models.LogLine) bool {
-		if m.filter.Match(line) {
-			m.filteredBuffer.Add(line)
+// expireSequences adds the lines of sequences whose window has closed, so
+// a missing query reports them even when no further lines arrive
+func (m *Model) expireSequences() {
+	if m.refilter != nil {
+		return // the re-filter owns the sequence state until it finishes
+	}
+	for _, line := range m.filter.Expire(time.Now()) {
+		m.addFiltered(line)
+		if m.pipeline != nil {
+			m.pipelineDirty = true
 		}
-		return true
-	})
+	}
 }
 
 // getContentHeight returns the available height for content in a pane
 func (m *Model) getContentHeight(pane *LogPane) int {
 	baseHeight := pane.height - 3 // -3 for border and header
 	if pane == m.allLogsPane {
-		baseHeight -= 1 // -1 additional for persistent header (reduced from 2)
+		baseHeight -= 1 + m.histogramHeight() // -1 additional for persistent header (reduced from 2)
+	}
+	if pane == m.filteredPane && m.pipeline != nil && len(m.resultColumns) > 0 {
+		baseHeight -= 1 // -1 for the table header row
+	} else if m.showsColumns(pane) {
+		baseHeight -= 1 // -1 for the column header row
 	}
 	if baseHeight < 1 {
 		baseHeight = 1
@@ -301,9 +332,9 @@ func (m *Model) goToBottom() {
 // autoScrollToBottom automatically scrolls to bottom if already at bottom
 func (m *Model) autoScrollToBottom() {
 	// Auto-scroll all logs pane if at bottom and user hasn't manually scrolled
-	if m.allLogsPane != nil && m.allLinesBuffer.Size() > 0 && !m.allLogsPane.userScrolled {
+	if m.allLogsPane != nil && m.allView().Size() > 0 && !m.allLogsPane.userScrolled {
 		pageSize := m.getContentHeight(m.allLogsPane)
-		maxScroll := m.allLinesBuffer.Size() - pageSize
+		maxScroll := m.allView().Size() - pageSize
 		if maxScroll < 0 {
 			maxScroll = 0
 		}
@@ -315,9 +346,9 @@ func (m *Model) autoScrollToBottom() {
 	}
 	
 	// Auto-scroll filtered pane if at bottom and user hasn't manually scrolled
-	if m.filteredPane != nil && m.filteredBuffer.Size() > 0 && !m.filteredPane.userScrolled {
+	if m.filteredPane != nil && m.pipeline == nil && m.filteredView().Size() > 0 && !m.filteredPane.userScrolled {
 		pageSize := m.getContentHeight(m.filteredPane)
-		maxScroll := m.filteredBuffer.Size() - pageSize
+		maxScroll := m.filteredView().Size() - pageSize
 		if maxScroll < 0 {
 			maxScroll = 0
 		}
@@ -329,8 +360,21 @@ func (m *Model) autoScrollToBottom() {
 	}
 }
 
-// nextMatch moves to the next search match
+// nextMatch moves to the next match in the active pane: the next one on
+// the same line, else the first on the next matching line
 func (m *Model) nextMatch() {
+	m.stepMatch(1)
+}
+
+// previousMatch moves to the previous match in the active pane
+func (m *Model) previousMatch() {
+	m.stepMatch(-1)
+}
+
+// stepMatch moves one match forward or back, wrapping around the buffer.
+// A matching line whose terms have no place in its text, such as a time
+// range, counts as one match.
+func (m *Model) stepMatch(dir int) {
 	if !m.filter.HasFilter() {
 		m.setStatusMessage("No search filter active")
 		return
@@ -342,63 +386,64 @@ func (m *Model) nextMatch() {
 		return
 	}
 	
-	// Find next match starting from current position
 	currentPos := activePane.scrollY + activePane.cursorY
 	
-	for i := currentPos + 1; i < buffer.Size(); i++ {
-		line := buffer.Get(i)
-		if line != nil && m.filter.Match(line) {
-			m.scrollToLine(i)
+	// Move within the current line first
+	if line := buffer.Get(currentPos); line != nil && line != contextSeparator {
+		spans := m.filter.MatchSpans(line)
+		next := 0
+		if activePane.match != nil && activePane.match.line == currentPos {
+			next = activePane.match.span + dir
+		} else if dir < 0 {
+			next = -1 // The cursor sits before the line's matches
+		}
+		if next >= 0 && next < len(spans) {
+			activePane.match = &matchFocus{line: currentPos, span: next}
+			m.reportMatch(spans, next)
 			return
 		}
 	}
 	
-	// Wrap around to beginning
-	for i := 0; i <= currentPos; i++ {
+	size := buffer.Size()
+	for step := 1; step <= size; step++ {
+		i := ((currentPos+dir*step)%size + size) % size
 		line := buffer.Get(i)
-		if line != nil && m.filter.Match(line) {
-			m.scrollToLine(i)
-			return
+		if line == nil || line == contextSeparator || !m.filter.Match(line) {
+			continue
+		}
+		
+		spans := m.filter.MatchSpans(line)
+		span := 0
+		if dir < 0 && len(spans) > 0 {
+			span = len(spans) - 1
 		}
+		m.scrollToLine(i)
+		activePane.match = &matchFocus{line: i, span: span}
+		m.reportMatch(spans, span)
+		if (dir > 0 && i <= currentPos) || (dir < 0 && i >= currentPos) {
+			m.setStatusMessage(m.statusMessage + " (wrapped)")
+		}
+		return
 	}
 	
 	m.setStatusMessage("No more matches")
 }
 
-// previousMatch moves to the previous search match
-func (m *Model) previousMatch() {
-	if !m.filter.HasFilter() {
-		m.setStatusMessage("No search filter active")
+// reportMatch shows which match of the line the cursor moved to
+func (m *Model) reportMatch(spans []models.Span, span int) {
+	if len(spans) == 0 {
+		m.setStatusMessage("Matching line")
 		return
 	}
-	
-	activePane := m.getActivePane()
-	buffer := m.getActiveBuffer()
-	if activePane == nil || buffer == nil || buffer.Size() == 0 {
-		return
-	}
-	
-	// Find previous match starting from current position
-	currentPos := activePane.scrollY + activePane.cursorY
-	
-	for i := currentPos - 1; i >= 0; i-- {
-		line := buffer.Get(i)
-		if line != nil && m.filter.Match(line) {
-			m.scrollToLine(i)
-			return
-		}
-	}
-	
-	// Wrap around to end
-	for i := buffer.Size() - 1; i >= currentPos; i-- {
-		line := buffer.Get(i)
-		if line != nil && m.filter.Match(line) {
-			m.scrollToLine(i)
-			return
-		}
+	m.setStatusMessage(fmt.Sprintf("Match %d/%d on this line, column %d", span+1, len(spans), spans[span].Start+1))
+}
+
+// matchSpans returns where the filter matched a line, nil without a filter
+func (m *Model) matchSpans(line *models.LogLine) []models.Span {
+	if !m.filter.HasFilter() {
+		return nil
 	}
-	
-	m.setStatusMessage("No more matches")
+	return m.filter.MatchSpans(line)
 }
 
 // scrollToLine scrolls the active pane to show a specific line
@@ -473,10 +518,63 @@ func (m *Model) addBookmark() {
 	m.setStatusMessage(fmt.Sprintf("Bookmarked line %d", currentLineIndex+1))
 }
 
+// showSameField filters to every line sharing the selected line's trace or
+// request ID, in time order across all sources. On a results table the
+// first column is used instead; the detail pane picks any field.
+func (m *Model) showSameField() tea.Cmd {
+	activePane := m.getActivePane()
+	buffer := m.getActiveBuffer()
+	if activePane == nil || buffer == nil || buffer.Size() == 0 {
+		m.setStatusMessage("No line selected")
+		return nil
+	}
+	
+	line := buffer.Get(activePane.scrollY + activePane.cursorY)
+	if line == nil {
+		return nil
+	}
+	
+	fields := m.config.General.CorrelationFields
+	if buffer == m.resultBuffer && len(m.resultColumns) > 0 {
+		fields = []string{parser.AppendKey("", m.resultColumns[0])}
+	}
+	
+	for _, field := range fields {
+		if value := m.filter.FieldValue(line, field); value != "" {
+			return m.showLinesWith(field, value)
+		}
+	}
+	
+	m.setStatusMessage(fmt.Sprintf("Line has none of: %s", strings.Join(fields, ", ")))
+	return nil
+}
+
+// showLinesWith replaces the filter with every line whose field has a
+// value, in time order
+func (m *Model) showLinesWith(field, value string) tea.Cmd {
+	m.searchInput = filter.FieldTerm(field, value) + " | sort timestamp"
+	m.searchCursor = len(m.searchInput)
+	cmd, err := m.applySearch()
+	if err != nil {
+		m.setStatusMessage(fmt.Sprintf("Filter error: %s", err.Error()))
+		return nil
+	}
+	m.detail = nil
+	m.activePane = PaneFiltered
+	m.allLogsPane.showCursor = false
+	m.filteredPane.showCursor = true
+	m.setStatusMessage(fmt.Sprintf("Showing lines with %s=%s", field, value))
+	return cmd
+}
+
 // clearFilter clears the current filter
 func (m *Model) clearFilter() {
+	m.activeTab().query = ""
+	m.cancelRefilter()
 	m.filter.Clear()
 	m.filteredBuffer.Clear() // Explicitly clear the filtered buffer
+	m.resetFiltered()
+	m.setPipeline(nil)
 	
 	// Force flush any remaining batch
 	m.simpleBatcher.ForceBatch(m)
@@ -484,6 +582,14 @@ func (m *Model) clearFilter() {
 	m.setStatusMessage("Filter cleared")
 }
 
+// resetFiltered resets what is derived from the filtered lines after they
+// were cleared for a new filter
+func (m *Model) resetFiltered() {
+	m.resetFacets()
+	m.resetHistogram()
+	m.resetContext()
+}
+
 // setStatusMessage sets a temporary status message
 func (m *Model) setStatusMessage(message string) {
 	m.statusMessage = message
@@ -516,11 +622,11 @@ func (m *Model) getActivePane() *LogPane {
 func (m *Model) getActiveBuffer() *CircularBuffer {
 	switch m.activePane {
 	case PaneAllLogs:
-		return m.allLinesBuffer
+		return m.allView()
 	case PaneFiltered:
-		return m.filteredBuffer
+		return m.filteredView()
 	default:
-		return m.allLinesBuffer
+		return m.allView()
 	}
 }
 
@@ -529,6 +635,12 @@ func (m *Model) AddFile(filePath string) error {
 	return m.tailer.AddFile(filePath)
 }
 
+// ReadDelimited parses a file as delimited rows under a header line, for
+// files whose extension does not reveal it
+func (m *Model) ReadDelimited(filePath string, delimiter rune) {
+	m.parser.RegisterDelimitedSource(filePath, delimiter)
+}
+
 // TailFromStart starts tailing a file from the beginning
 func (m *Model) TailFromStart(filePath string) error {
 	return m.tailer.TailFromStart(filePath)
@@ -559,6 +671,8 @@ func (m *Model) GetStats() map[string]interface{} {
 		"has_filter":      m.filter.HasFilter(),
 		"bookmark_count":  len(m.bookmarks),
 		"watched_files":   m.tailer.GetWatchedFiles(),
+		"index_enabled":   m.searchIndex.Enabled(),
+		"index_bytes":     m.searchIndex.Size(),
 	}
 }
 